@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/url"
 )
 
 // Healthcheck represents an Healthcheck attached to an IP
@@ -158,6 +159,21 @@ func (UpdateIPAddress) AsyncResponse() interface{} {
 	return new(IPAddress)
 }
 
+func (req UpdateIPAddress) onBeforeSend(_ url.Values) error {
+	// The healthcheck values set are checked beforehand, the other ones being left as is
+	return Healthcheck{
+		Interval:      req.HealthcheckInterval,
+		Mode:          req.HealthcheckMode,
+		Path:          req.HealthcheckPath,
+		Port:          req.HealthcheckPort,
+		StrikesFail:   req.HealthcheckStrikesFail,
+		StrikesOk:     req.HealthcheckStrikesOk,
+		Timeout:       req.HealthcheckTimeout,
+		TLSSNI:        req.HealthcheckTLSSNI,
+		TLSSkipVerify: req.HealthcheckTLSSkipVerify,
+	}.validateFields()
+}
+
 //go:generate go run generate/main.go -interface=Listable ListPublicIPAddresses
 
 // ListPublicIPAddresses represents a search for public IP addresses
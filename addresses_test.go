@@ -1,7 +1,10 @@
 package egoscale
 
 import (
+	"net/url"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestIPAddress(t *testing.T) {
@@ -33,3 +36,24 @@ func TestUpdateIPAddress(t *testing.T) {
 	_ = req.Response().(*AsyncJobResult)
 	_ = req.AsyncResponse().(*IPAddress)
 }
+
+func TestUpdateIPAddressOnBeforeSendHealthcheck(t *testing.T) {
+	req := &UpdateIPAddress{Description: "test"}
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	// Partial updates are merged with the current healthcheck
+	req = &UpdateIPAddress{ID: MustParseUUID("7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a99"), HealthcheckPort: 8080}
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	req.HealthcheckMode = "http"
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	req.HealthcheckTimeout = 15
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	req.HealthcheckInterval = 10
+	require.IsType(t, &ValidationError{}, req.onBeforeSend(url.Values{}))
+
+	req = &UpdateIPAddress{HealthcheckMode: "udp"}
+	require.IsType(t, &ValidationError{}, req.onBeforeSend(url.Values{}))
+}
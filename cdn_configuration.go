@@ -0,0 +1,126 @@
+package egoscale
+
+import (
+	"context"
+	"net/http"
+
+	apiv2 "github.com/exoscale/egoscale/api/v2"
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// CDNConfiguration represents a CDN configuration of a Storage (SOS) bucket.
+type CDNConfiguration struct {
+	Bucket string
+	FQDN   string
+	Status string
+}
+
+func cdnConfigurationFromAPI(cfg *v2.CdnConfiguration) *CDNConfiguration {
+	return &CDNConfiguration{
+		Bucket: optionalString(cfg.Bucket),
+		FQDN:   optionalString(cfg.Fqdn),
+		Status: optionalString(cfg.Status),
+	}
+}
+
+// CreateCDNConfiguration enables the CDN for the specified Storage bucket in the specified zone.
+// If a CDN configuration already exists for this bucket, ErrAlreadyExists is returned.
+func (c *Client) CreateCDNConfiguration(ctx context.Context, zone, bucket string) (*CDNConfiguration, error) {
+	resp, err := c.V2.CreateCdnConfigurationWithResponse(
+		apiv2.WithZone(ctx, zone),
+		v2.CreateCdnConfigurationJSONRequestBody{Bucket: &bucket})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	_, err = v2.NewPoller().
+		WithTimeout(c.Timeout).
+		Poll(ctx, c.V2.OperationPoller(zone, *resp.JSON200.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetCDNConfiguration(ctx, zone, bucket)
+}
+
+// ListCDNConfigurations returns the list of existing CDN configurations in the specified zone.
+func (c *Client) ListCDNConfigurations(ctx context.Context, zone string) ([]*CDNConfiguration, error) {
+	var list = make([]*CDNConfiguration, 0)
+
+	resp, err := c.V2.ListCdnConfigurationsWithResponse(apiv2.WithZone(ctx, zone))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	if resp.JSON200.CdnConfigurations != nil {
+		for i := range *resp.JSON200.CdnConfigurations {
+			list = append(list, cdnConfigurationFromAPI(&(*resp.JSON200.CdnConfigurations)[i]))
+		}
+	}
+
+	return list, nil
+}
+
+// GetCDNConfiguration returns the CDN configuration of the specified Storage bucket in the
+// specified zone, or ErrNotFound if the CDN is not enabled for this bucket.
+func (c *Client) GetCDNConfiguration(ctx context.Context, zone, bucket string) (*CDNConfiguration, error) {
+	list, err := c.ListCDNConfigurations(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cfg := range list {
+		if cfg.Bucket == bucket {
+			return cfg, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// DeleteCDNConfiguration disables the CDN for the specified Storage bucket in the specified zone.
+func (c *Client) DeleteCDNConfiguration(ctx context.Context, zone, bucket string) error {
+	resp, err := c.V2.DeleteCdnConfigurationWithResponse(apiv2.WithZone(ctx, zone), bucket)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	_, err = v2.NewPoller().
+		WithTimeout(c.Timeout).
+		Poll(ctx, c.V2.OperationPoller(zone, *resp.JSON200.Id))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EnsureCDN enables the CDN for the specified Storage bucket in the specified zone if not already
+// enabled, and returns the bucket CDN configuration. It is safe to call EnsureCDN several times
+// for the same bucket.
+func (c *Client) EnsureCDN(ctx context.Context, zone, bucket string) (*CDNConfiguration, error) {
+	cfg, err := c.GetCDNConfiguration(ctx, zone, bucket)
+	if err == nil {
+		return cfg, nil
+	}
+	if err != ErrNotFound {
+		return nil, err
+	}
+
+	cfg, err = c.CreateCDNConfiguration(ctx, zone, bucket)
+	if err == ErrAlreadyExists {
+		// The CDN has been enabled concurrently since we checked.
+		return c.GetCDNConfiguration(ctx, zone, bucket)
+	}
+
+	return cfg, err
+}
@@ -0,0 +1,179 @@
+package egoscale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+var (
+	testCDNConfigurationBucket = "test-bucket"
+	testCDNConfigurationFQDN   = "test-bucket.cdn.exoscale.com"
+	testCDNConfigurationStatus = "enabled"
+)
+
+// registerTestCDNConfigurationsResponder registers a mock HTTP responder listing the CDN
+// configurations, returning the test CDN configuration only if enabled returns true.
+func registerTestCDNConfigurationsResponder(t *testing.T, mockClient *v2.MockClient, enabled func() bool) {
+	mockClient.RegisterResponder("GET", "/cdn-configuration",
+		func(req *http.Request) (*http.Response, error) {
+			list := make([]v2.CdnConfiguration, 0)
+			if enabled() {
+				list = append(list, v2.CdnConfiguration{
+					Bucket: &testCDNConfigurationBucket,
+					Fqdn:   &testCDNConfigurationFQDN,
+					Status: &testCDNConfigurationStatus,
+				})
+			}
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				CdnConfigurations *[]v2.CdnConfiguration `json:"cdn-configurations,omitempty"`
+			}{
+				CdnConfigurations: &list,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+}
+
+func TestClient_CreateCDNConfiguration(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("POST", "/cdn-configuration",
+		httpmock.NewStringResponder(http.StatusConflict, `{"message":"CDN already enabled"}`))
+
+	_, err = client.CreateCDNConfiguration(context.Background(), testZone, testCDNConfigurationBucket)
+	require.Equal(t, ErrAlreadyExists, err)
+}
+
+func TestClient_ListCDNConfigurations(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	registerTestCDNConfigurationsResponder(t, mockClient, func() bool { return true })
+
+	expected := []*CDNConfiguration{{
+		Bucket: testCDNConfigurationBucket,
+		FQDN:   testCDNConfigurationFQDN,
+		Status: testCDNConfigurationStatus,
+	}}
+
+	actual, err := client.ListCDNConfigurations(context.Background(), testZone)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestClient_GetCDNConfiguration(t *testing.T) {
+	var (
+		enabled bool
+		err     error
+	)
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	registerTestCDNConfigurationsResponder(t, mockClient, func() bool { return enabled })
+
+	_, err = client.GetCDNConfiguration(context.Background(), testZone, testCDNConfigurationBucket)
+	require.Equal(t, ErrNotFound, err)
+
+	enabled = true
+	actual, err := client.GetCDNConfiguration(context.Background(), testZone, testCDNConfigurationBucket)
+	require.NoError(t, err)
+	require.Equal(t, &CDNConfiguration{
+		Bucket: testCDNConfigurationBucket,
+		FQDN:   testCDNConfigurationFQDN,
+		Status: testCDNConfigurationStatus,
+	}, actual)
+}
+
+func TestClient_DeleteCDNConfiguration(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("DELETE", "/cdn-configuration/"+testCDNConfigurationBucket,
+		httpmock.NewStringResponder(http.StatusNotFound, `{"message":"not found"}`))
+
+	err = client.DeleteCDNConfiguration(context.Background(), testZone, testCDNConfigurationBucket)
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestClient_EnsureCDN(t *testing.T) {
+	var (
+		testOperationID    = "8c8d8a9a-2c36-4c7b-b9e2-5f0b0d0cb48d"
+		testOperationState = "success"
+		enabled            bool
+		created            int
+		err                error
+	)
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	registerTestCDNConfigurationsResponder(t, mockClient, func() bool { return enabled })
+
+	mockClient.RegisterResponder("POST", "/cdn-configuration",
+		func(req *http.Request) (*http.Response, error) {
+			created++
+			enabled = true
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:    &testOperationID,
+				State: &testOperationState,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/operation/"+testOperationID,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:    &testOperationID,
+				State: &testOperationState,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	expected := &CDNConfiguration{
+		Bucket: testCDNConfigurationBucket,
+		FQDN:   testCDNConfigurationFQDN,
+		Status: testCDNConfigurationStatus,
+	}
+
+	// The first call must enable the CDN, the second one must be a no-op
+	for i := 0; i < 2; i++ {
+		actual, err := client.EnsureCDN(context.Background(), testZone, testCDNConfigurationBucket)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+		require.Equal(t, 1, created)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net"
+	"sort"
+	"strings"
 )
 
 // CIDR represents a nicely JSON serializable net.IPNet
@@ -60,3 +63,294 @@ func MustParseCIDR(s string) *CIDR {
 func (cidr CIDR) Equal(c CIDR) bool {
 	return (cidr.IPNet.IP.Equal(c.IPNet.IP) && bytes.Equal(cidr.IPNet.Mask, c.IPNet.Mask))
 }
+
+// maxCIDRSubnets is the maximum number of subnets returned by CIDR.Subnets.
+const maxCIDRSubnets = 1 << 16
+
+// ContainsCIDR returns true if the c network is entirely included in the CIDR. The inclusion of
+// a single IP address is given by the Contains method of net.IPNet.
+func (cidr CIDR) ContainsCIDR(c CIDR) bool {
+	r, cr := cidr.ipRange(), c.ipRange()
+	return r.bits == cr.bits && r.first.Cmp(cr.first) <= 0 && r.last.Cmp(cr.last) >= 0
+}
+
+// Overlaps returns true if the two CIDR have at least one IP address in common.
+func (cidr CIDR) Overlaps(c CIDR) bool {
+	r, cr := cidr.ipRange(), c.ipRange()
+	return r.bits == cr.bits && r.first.Cmp(cr.last) <= 0 && cr.first.Cmp(r.last) <= 0
+}
+
+// Subnets returns the subnets of the CIDR having the specified prefix length, e.g. the four /26
+// subnets of a /24.
+func (cidr CIDR) Subnets(newPrefix int) ([]CIDR, error) {
+	ones, bits := cidr.Mask.Size()
+	if newPrefix < ones || newPrefix > bits {
+		return nil, fmt.Errorf("invalid prefix length %d, must be between %d and %d", newPrefix, ones, bits)
+	}
+	if newPrefix-ones > 16 {
+		return nil, fmt.Errorf("too many subnets, at most %d can be returned", maxCIDRSubnets)
+	}
+
+	var (
+		r       = cidr.ipRange()
+		step    = new(big.Int).Lsh(big.NewInt(1), uint(bits-newPrefix))
+		subnets = make([]CIDR, 0, 1<<uint(newPrefix-ones))
+	)
+	for n := r.first; n.Cmp(r.last) <= 0; n = new(big.Int).Add(n, step) {
+		subnets = append(subnets, CIDR{net.IPNet{IP: intToIP(n, bits), Mask: net.CIDRMask(newPrefix, bits)}})
+	}
+
+	return subnets, nil
+}
+
+// Supernet returns the network having the specified prefix length which includes the CIDR, e.g.
+// 10.0.0.0/16 for 10.0.42.0/24 and a prefix length of 16.
+func (cidr CIDR) Supernet(newPrefix int) (*CIDR, error) {
+	ones, bits := cidr.Mask.Size()
+	if newPrefix < 0 || newPrefix > ones {
+		return nil, fmt.Errorf("invalid prefix length %d, must be between 0 and %d", newPrefix, ones)
+	}
+
+	mask := net.CIDRMask(newPrefix, bits)
+	return &CIDR{net.IPNet{IP: cidr.ip().Mask(mask), Mask: mask}}, nil
+}
+
+// FirstIP returns the first IP address of the CIDR usable by a host, i.e. excluding the network
+// address of the IPv4 networks larger than /31.
+func (cidr CIDR) FirstIP() net.IP {
+	r := cidr.ipRange()
+	if ones, bits := cidr.Mask.Size(); bits == 8*net.IPv4len && ones < 31 {
+		return intToIP(new(big.Int).Add(r.first, big.NewInt(1)), bits)
+	}
+
+	return intToIP(r.first, r.bits)
+}
+
+// LastIP returns the last IP address of the CIDR usable by a host, i.e. excluding the broadcast
+// address of the IPv4 networks larger than /31.
+func (cidr CIDR) LastIP() net.IP {
+	r := cidr.ipRange()
+	if ones, bits := cidr.Mask.Size(); bits == 8*net.IPv4len && ones < 31 {
+		return intToIP(new(big.Int).Sub(r.last, big.NewInt(1)), bits)
+	}
+
+	return intToIP(r.last, r.bits)
+}
+
+// BroadcastIP returns the broadcast address of an IPv4 CIDR, or nil for IPv6 which has no
+// broadcast address.
+func (cidr CIDR) BroadcastIP() net.IP {
+	r := cidr.ipRange()
+	if r.bits != 8*net.IPv4len {
+		return nil
+	}
+
+	return intToIP(r.last, r.bits)
+}
+
+// HostCount returns the number of IP addresses of the CIDR usable by a host, from FirstIP to
+// LastIP. A big.Int is returned as IPv6 networks can exceed the capacity of integer types.
+func (cidr CIDR) HostCount() *big.Int {
+	first, last := ipToInt(cidr.FirstIP()), ipToInt(cidr.LastIP())
+	return first.Sub(last, first).Add(first, big.NewInt(1))
+}
+
+// RangeToCIDRs returns the smallest list of CIDR covering exactly the IP addresses from start to
+// end included, both being either IPv4 or IPv6 addresses.
+func RangeToCIDRs(start, end net.IP) ([]CIDR, error) {
+	bits := ipBits(start)
+	if bits == 0 || bits != ipBits(end) {
+		return nil, fmt.Errorf("invalid range %s-%s, both ends must be of the same IP version", start, end)
+	}
+
+	first, last := ipToInt(start), ipToInt(end)
+	if first.Cmp(last) > 0 {
+		return nil, fmt.Errorf("invalid range %s-%s, the start is greater than the end", start, end)
+	}
+
+	return ipRange{first: first, last: last, bits: bits}.cidrs(), nil
+}
+
+// CIDRSet represents a set of IP addresses, which can be expressed as the smallest list of CIDR
+// covering them. The zero value is an empty set ready to use.
+type CIDRSet struct {
+	// ranges lists the disjoint IP ranges of the set, IPv4 first, in ascending order
+	ranges []ipRange
+}
+
+// NewCIDRSet returns a set of the IP addresses of the specified CIDR.
+func NewCIDRSet(cidrs ...CIDR) *CIDRSet {
+	set := &CIDRSet{}
+	set.Add(cidrs...)
+
+	return set
+}
+
+// Add adds the IP addresses of the specified CIDR to the set, merging the overlapping and
+// adjacent networks.
+func (set *CIDRSet) Add(cidrs ...CIDR) {
+	for _, cidr := range cidrs {
+		set.ranges = append(set.ranges, cidr.ipRange())
+	}
+
+	sort.Slice(set.ranges, func(i, j int) bool {
+		if set.ranges[i].bits != set.ranges[j].bits {
+			return set.ranges[i].bits < set.ranges[j].bits
+		}
+		return set.ranges[i].first.Cmp(set.ranges[j].first) < 0
+	})
+
+	merged := make([]ipRange, 0, len(set.ranges))
+	for _, r := range set.ranges {
+		if n := len(merged); n > 0 && merged[n-1].bits == r.bits &&
+			new(big.Int).Add(merged[n-1].last, big.NewInt(1)).Cmp(r.first) >= 0 {
+			if r.last.Cmp(merged[n-1].last) > 0 {
+				merged[n-1].last = r.last
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	set.ranges = merged
+}
+
+// Subtract removes the IP addresses of the specified CIDR from the set.
+func (set *CIDRSet) Subtract(cidrs ...CIDR) {
+	for _, cidr := range cidrs {
+		sub := cidr.ipRange()
+
+		ranges := make([]ipRange, 0, len(set.ranges)+1)
+		for _, r := range set.ranges {
+			if r.bits != sub.bits || r.last.Cmp(sub.first) < 0 || r.first.Cmp(sub.last) > 0 {
+				ranges = append(ranges, r)
+				continue
+			}
+
+			if r.first.Cmp(sub.first) < 0 {
+				ranges = append(ranges, ipRange{first: r.first, last: new(big.Int).Sub(sub.first, big.NewInt(1)), bits: r.bits})
+			}
+			if r.last.Cmp(sub.last) > 0 {
+				ranges = append(ranges, ipRange{first: new(big.Int).Add(sub.last, big.NewInt(1)), last: r.last, bits: r.bits})
+			}
+		}
+		set.ranges = ranges
+	}
+}
+
+// Contains returns true if the IP address belongs to the set.
+func (set *CIDRSet) Contains(ip net.IP) bool {
+	bits := ipBits(ip)
+	n := ipToInt(ip)
+
+	for _, r := range set.ranges {
+		if r.bits == bits && r.first.Cmp(n) <= 0 && r.last.Cmp(n) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CIDRs returns the smallest list of CIDR covering the set, IPv4 first, in ascending order.
+func (set *CIDRSet) CIDRs() []CIDR {
+	cidrs := make([]CIDR, 0, len(set.ranges))
+	for _, r := range set.ranges {
+		cidrs = append(cidrs, r.cidrs()...)
+	}
+
+	return cidrs
+}
+
+// String returns the comma-separated list of the CIDR covering the set.
+func (set *CIDRSet) String() string {
+	cidrs := set.CIDRs()
+
+	s := make([]string, len(cidrs))
+	for i := range cidrs {
+		s[i] = cidrs[i].String()
+	}
+
+	return strings.Join(s, ",")
+}
+
+// ipRange represents a range of IP addresses of the same version as integers.
+type ipRange struct {
+	first, last *big.Int
+	bits        int
+}
+
+// cidrs returns the smallest list of CIDR covering the range.
+func (r ipRange) cidrs() []CIDR {
+	cidrs := make([]CIDR, 0)
+
+	for first := r.first; first.Cmp(r.last) <= 0; {
+		size := r.bits
+		if first.Sign() != 0 && int(first.TrailingZeroBits()) < size {
+			size = int(first.TrailingZeroBits())
+		}
+
+		var last *big.Int
+		for ; ; size-- {
+			last = new(big.Int).Lsh(big.NewInt(1), uint(size))
+			last.Add(last, first).Sub(last, big.NewInt(1))
+			if last.Cmp(r.last) <= 0 {
+				break
+			}
+		}
+
+		cidrs = append(cidrs, CIDR{net.IPNet{IP: intToIP(first, r.bits), Mask: net.CIDRMask(r.bits-size, r.bits)}})
+		first = last.Add(last, big.NewInt(1))
+	}
+
+	return cidrs
+}
+
+// ip returns the network IP address of the CIDR, having the length of its mask.
+func (cidr CIDR) ip() net.IP {
+	if len(cidr.Mask) == net.IPv4len {
+		return cidr.IP.To4()
+	}
+
+	return cidr.IP.To16()
+}
+
+// ipRange returns the range of IP addresses of the CIDR.
+func (cidr CIDR) ipRange() ipRange {
+	ones, bits := cidr.Mask.Size()
+	first := ipToInt(cidr.ip().Mask(cidr.Mask))
+	last := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last.Add(last, first).Sub(last, big.NewInt(1))
+
+	return ipRange{first: first, last: last, bits: bits}
+}
+
+// ipBits returns the number of bits of an IP address: 32 for IPv4, 128 for IPv6, 0 if invalid.
+func ipBits(ip net.IP) int {
+	if ip.To4() != nil {
+		return 8 * net.IPv4len
+	}
+	if ip.To16() != nil {
+		return 8 * net.IPv6len
+	}
+
+	return 0
+}
+
+// ipToInt returns the integer representation of an IP address.
+func ipToInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4)
+	}
+
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// intToIP returns the IP address of an integer representation, having the specified number of
+// bits.
+func intToIP(n *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	b := n.Bytes()
+	copy(ip[len(ip)-len(b):], b)
+
+	return ip
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestCIDRMustParse(t *testing.T) {
@@ -54,3 +56,125 @@ func TestCIDREqual(t *testing.T) {
 		t.Errorf("the two CIDR should have been equal, want: %v, got: %v", expected, ip4CIDR)
 	}
 }
+
+func TestCIDRContainsCIDR(t *testing.T) {
+	cidr := MustParseCIDR("10.0.0.0/16")
+
+	require.True(t, cidr.ContainsCIDR(*MustParseCIDR("10.0.42.0/24")))
+	require.True(t, cidr.ContainsCIDR(*cidr))
+	require.False(t, cidr.ContainsCIDR(*MustParseCIDR("10.0.0.0/8")))
+	require.False(t, cidr.ContainsCIDR(*MustParseCIDR("::/0")))
+	require.True(t, cidr.Contains(net.ParseIP("10.0.1.2")))
+}
+
+func TestCIDROverlaps(t *testing.T) {
+	cidr := MustParseCIDR("10.0.0.0/16")
+
+	require.True(t, cidr.Overlaps(*MustParseCIDR("10.0.255.0/24")))
+	require.True(t, cidr.Overlaps(*MustParseCIDR("10.0.0.0/8")))
+	require.False(t, cidr.Overlaps(*MustParseCIDR("10.1.0.0/16")))
+	require.False(t, MustParseCIDR("::/0").Overlaps(*cidr))
+	require.True(t, MustParseCIDR("2001:db8::/32").Overlaps(*MustParseCIDR("2001:db8:1::/48")))
+}
+
+func TestCIDRSubnets(t *testing.T) {
+	subnets, err := MustParseCIDR("10.0.0.0/24").Subnets(26)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}, cidrStrings(subnets))
+
+	subnets, err = MustParseCIDR("2001:db8::/32").Subnets(34)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2001:db8::/34", "2001:db8:4000::/34", "2001:db8:8000::/34", "2001:db8:c000::/34"}, cidrStrings(subnets))
+
+	_, err = MustParseCIDR("10.0.0.0/24").Subnets(23)
+	require.EqualError(t, err, "invalid prefix length 23, must be between 24 and 32")
+	_, err = MustParseCIDR("::/0").Subnets(64)
+	require.Error(t, err)
+}
+
+func TestCIDRSupernet(t *testing.T) {
+	supernet, err := MustParseCIDR("10.0.42.0/24").Supernet(16)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.0/16", supernet.String())
+
+	supernet, err = MustParseCIDR("2001:db8:1::/48").Supernet(32)
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8::/32", supernet.String())
+
+	_, err = MustParseCIDR("10.0.42.0/24").Supernet(25)
+	require.EqualError(t, err, "invalid prefix length 25, must be between 0 and 24")
+}
+
+func TestCIDRAddresses(t *testing.T) {
+	for _, tc := range []struct {
+		cidr                   string
+		first, last, broadcast string
+		hosts                  string
+	}{
+		{"10.0.0.0/24", "10.0.0.1", "10.0.0.254", "10.0.0.255", "254"},
+		{"10.0.0.0/31", "10.0.0.0", "10.0.0.1", "10.0.0.1", "2"},
+		{"10.0.0.1/32", "10.0.0.1", "10.0.0.1", "10.0.0.1", "1"},
+		{"2001:db8::/126", "2001:db8::", "2001:db8::3", "<nil>", "4"},
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "<nil>", "340282366920938463463374607431768211456"},
+	} {
+		cidr := MustParseCIDR(tc.cidr)
+		require.Equal(t, tc.first, cidr.FirstIP().String(), tc.cidr)
+		require.Equal(t, tc.last, cidr.LastIP().String(), tc.cidr)
+		require.Equal(t, tc.broadcast, cidr.BroadcastIP().String(), tc.cidr)
+		require.Equal(t, tc.hosts, cidr.HostCount().String(), tc.cidr)
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	cidrs, err := RangeToCIDRs(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.10"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/31", "10.0.0.10/32"}, cidrStrings(cidrs))
+
+	cidrs, err = RangeToCIDRs(net.ParseIP("0.0.0.0"), net.ParseIP("255.255.255.255"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"0.0.0.0/0"}, cidrStrings(cidrs))
+
+	cidrs, err = RangeToCIDRs(net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::2"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"2001:db8::/127", "2001:db8::2/128"}, cidrStrings(cidrs))
+
+	_, err = RangeToCIDRs(net.ParseIP("10.0.0.10"), net.ParseIP("10.0.0.1"))
+	require.EqualError(t, err, "invalid range 10.0.0.10-10.0.0.1, the start is greater than the end")
+	_, err = RangeToCIDRs(net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::"))
+	require.Error(t, err)
+}
+
+func TestCIDRSet(t *testing.T) {
+	set := NewCIDRSet(
+		*MustParseCIDR("10.0.1.0/24"),
+		*MustParseCIDR("2001:db8::/33"),
+		*MustParseCIDR("10.0.0.0/24"),
+		*MustParseCIDR("10.0.0.128/25"),
+		*MustParseCIDR("2001:db8:8000::/33"),
+		*MustParseCIDR("10.0.0.0/24"),
+	)
+	require.Equal(t, "10.0.0.0/23,2001:db8::/32", set.String())
+	require.True(t, set.Contains(net.ParseIP("10.0.1.42")))
+	require.False(t, set.Contains(net.ParseIP("10.0.2.42")))
+
+	set.Subtract(*MustParseCIDR("10.0.0.64/26"), *MustParseCIDR("2001:db8::/32"), *MustParseCIDR("192.168.0.0/16"))
+	require.Equal(t, "10.0.0.0/26,10.0.0.128/25,10.0.1.0/24", set.String())
+	require.False(t, set.Contains(net.ParseIP("10.0.0.100")))
+
+	set.Add(*MustParseCIDR("10.0.0.64/26"))
+	require.Equal(t, []string{"10.0.0.0/23"}, cidrStrings(set.CIDRs()))
+
+	var empty CIDRSet
+	require.Empty(t, empty.CIDRs())
+	empty.Subtract(*MustParseCIDR("10.0.0.0/8"))
+	require.Equal(t, "", empty.String())
+}
+
+func cidrStrings(cidrs []CIDR) []string {
+	s := make([]string, len(cidrs))
+	for i := range cidrs {
+		s[i] = cidrs[i].String()
+	}
+
+	return s
+}
@@ -174,6 +174,8 @@ Elastic IPs
 
 An Elastic IP is a way to attach an IP address to many Virtual Machines. The API side of the story configures the external environment, like the routing. Some work is required within the machine to properly configure the interfaces.
 
+AllocateElasticIP, AttachElasticIP, DetachElasticIP and MoveElasticIP manage Elastic IPs by Virtual Machine ID, taking care of the underlying NIC secondary IPs.
+
 See: https://community.exoscale.com/documentation/compute/eip/
 
 */
@@ -0,0 +1,248 @@
+package egoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	healthcheckMinInterval     = 5
+	healthcheckDefaultInterval = 10
+	healthcheckDefaultTimeout  = 2
+)
+
+// Validate checks the healthcheck against its documented constraints: a mode of "tcp", "http"
+// or "https" and a port are required to define a healthcheck, a path is required for the "http"
+// and "https" modes, the interval must be at least 5 seconds, and the timeout cannot be greater
+// than the interval. Unset (zero) values stand for the API defaults of a new healthcheck.
+func (hc Healthcheck) Validate() error {
+	if hc.Mode == "" {
+		return &ValidationError{Field: "Mode", Reason: "required"}
+	}
+
+	if err := hc.validateFields(); err != nil {
+		return err
+	}
+
+	if hc.Port == 0 {
+		return &ValidationError{Field: "Port", Reason: "must be between 1 and 65535"}
+	}
+
+	if hc.Mode != "tcp" && hc.Path == "" {
+		return &ValidationError{Field: "Path", Reason: fmt.Sprintf("required for the %s mode", hc.Mode)}
+	}
+
+	interval, timeout := hc.Interval, hc.Timeout
+	if interval == 0 {
+		interval = healthcheckDefaultInterval
+	}
+	if timeout == 0 {
+		timeout = healthcheckDefaultTimeout
+	}
+	if timeout > interval {
+		return &ValidationError{Field: "Timeout", Reason: fmt.Sprintf("cannot be greater than the interval (%ds)", interval)}
+	}
+
+	return nil
+}
+
+// validateFields checks the values set of the healthcheck, leaving the unset (zero) ones out:
+// unlike Validate, it accepts the partial healthcheck of an update, which values are merged by
+// the API with the current ones.
+func (hc Healthcheck) validateFields() error {
+	switch hc.Mode {
+	case "", "tcp", "http", "https":
+	default:
+		return &ValidationError{Field: "Mode", Reason: fmt.Sprintf("unsupported mode %q, must be tcp, http or https", hc.Mode)}
+	}
+
+	if hc.Port < 0 || hc.Port > 65535 {
+		return &ValidationError{Field: "Port", Reason: "must be between 1 and 65535"}
+	}
+
+	if hc.Mode != "" && hc.Mode != "https" && (hc.TLSSNI != "" || hc.TLSSkipVerify) {
+		return &ValidationError{Field: "TLSSNI", Reason: "TLS settings are only supported by the https mode"}
+	}
+
+	switch {
+	case hc.Interval != 0 && hc.Interval < healthcheckMinInterval:
+		return &ValidationError{Field: "Interval", Reason: fmt.Sprintf("must be at least %d seconds", healthcheckMinInterval)}
+	case hc.Timeout < 0:
+		return &ValidationError{Field: "Timeout", Reason: "must be positive"}
+	case hc.Interval != 0 && hc.Timeout > hc.Interval:
+		return &ValidationError{Field: "Timeout", Reason: fmt.Sprintf("cannot be greater than the interval (%ds)", hc.Interval)}
+	case hc.StrikesFail < 0:
+		return &ValidationError{Field: "StrikesFail", Reason: "must be positive"}
+	case hc.StrikesOk < 0:
+		return &ValidationError{Field: "StrikesOk", Reason: "must be positive"}
+	}
+
+	return nil
+}
+
+// ElasticIPAttachment represents the virtual machines an Elastic IP is attached to.
+type ElasticIPAttachment struct {
+	ElasticIP       IPAddress
+	VirtualMachines []VirtualMachine
+}
+
+// AllocateElasticIP allocates an Elastic IP in the specified zone, managed by the healthcheck
+// if not nil. A *ValidationError is returned if the healthcheck is invalid (see
+// Healthcheck.Validate).
+func (client *Client) AllocateElasticIP(ctx context.Context, zoneID *UUID, description string, healthcheck *Healthcheck) (*IPAddress, error) {
+	req := &AssociateIPAddress{ZoneID: zoneID, Description: description}
+
+	if healthcheck != nil {
+		if err := healthcheck.Validate(); err != nil {
+			return nil, err
+		}
+
+		req.HealthcheckMode = healthcheck.Mode
+		req.HealthcheckPort = healthcheck.Port
+		req.HealthcheckPath = healthcheck.Path
+		req.HealthcheckInterval = healthcheck.Interval
+		req.HealthcheckTimeout = healthcheck.Timeout
+		req.HealthcheckStrikesFail = healthcheck.StrikesFail
+		req.HealthcheckStrikesOk = healthcheck.StrikesOk
+		req.HealthcheckTLSSNI = healthcheck.TLSSNI
+		req.HealthcheckTLSSkipVerify = healthcheck.TLSSkipVerify
+	}
+
+	resp, err := client.RequestWithContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*IPAddress), nil
+}
+
+// AttachElasticIP attaches the Elastic IP to the default NIC of the virtual machine. Attaching an
+// Elastic IP already attached to the virtual machine is a no-op.
+func (client *Client) AttachElasticIP(ctx context.Context, eipID, vmID *UUID) (*NicSecondaryIP, error) {
+	eip, vm, err := client.elasticIPAndVirtualMachine(ctx, eipID, vmID)
+	if err != nil {
+		return nil, err
+	}
+
+	if secondaryIP := elasticIPSecondaryIP(eip, vm); secondaryIP != nil {
+		return secondaryIP, nil
+	}
+
+	nic := vm.DefaultNic()
+	if nic == nil {
+		return nil, fmt.Errorf("virtual machine %s has no default NIC", vm.ID)
+	}
+
+	resp, err := client.RequestWithContext(ctx, &AddIPToNic{NicID: nic.ID, IPAddress: eip.IPAddress})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*NicSecondaryIP), nil
+}
+
+// DetachElasticIP detaches the Elastic IP from the virtual machine. ErrNotFound is returned if the
+// Elastic IP is not attached to the virtual machine.
+func (client *Client) DetachElasticIP(ctx context.Context, eipID, vmID *UUID) error {
+	eip, vm, err := client.elasticIPAndVirtualMachine(ctx, eipID, vmID)
+	if err != nil {
+		return err
+	}
+
+	secondaryIP := elasticIPSecondaryIP(eip, vm)
+	if secondaryIP == nil {
+		return ErrNotFound
+	}
+
+	return client.BooleanRequestWithContext(ctx, &RemoveIPFromNic{ID: secondaryIP.ID})
+}
+
+// MoveElasticIP moves the Elastic IP from a virtual machine to another: it is attached to the
+// new virtual machine first, then detached from the previous one, so that the Elastic IP remains
+// attached to the previous virtual machine if the attachment fails. An error is returned if the
+// detachment fails, the Elastic IP being then attached to both virtual machines.
+func (client *Client) MoveElasticIP(ctx context.Context, eipID, fromVMID, toVMID *UUID) (*NicSecondaryIP, error) {
+	if fromVMID == nil || toVMID == nil {
+		return nil, &ValidationError{Field: "VirtualMachineID", Reason: "required"}
+	}
+	if fromVMID.Equal(*toVMID) {
+		return nil, errors.New("unable to move an Elastic IP to the virtual machine it is attached to")
+	}
+
+	secondaryIP, err := client.AttachElasticIP(ctx, eipID, toVMID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to attach Elastic IP to %s: %s", toVMID, err)
+	}
+
+	if err := client.DetachElasticIP(ctx, eipID, fromVMID); err != nil && err != ErrNotFound {
+		return secondaryIP, fmt.Errorf("unable to detach Elastic IP from %s: %s", fromVMID, err)
+	}
+
+	return secondaryIP, nil
+}
+
+// ListElasticIPAttachments returns the Elastic IPs of the specified zone, along with the virtual
+// machines they are attached to.
+func (client *Client) ListElasticIPAttachments(ctx context.Context, zoneID *UUID) ([]ElasticIPAttachment, error) {
+	eips, err := client.ListWithContext(ctx, &IPAddress{ZoneID: zoneID, IsElastic: true})
+	if err != nil {
+		return nil, err
+	}
+
+	vms, err := client.ListWithContext(ctx, &VirtualMachine{ZoneID: zoneID})
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := make([]ElasticIPAttachment, len(eips))
+	for i := range eips {
+		attachments[i].ElasticIP = *eips[i].(*IPAddress)
+
+		for _, item := range vms {
+			vm := item.(*VirtualMachine)
+			if elasticIPSecondaryIP(&attachments[i].ElasticIP, vm) != nil {
+				attachments[i].VirtualMachines = append(attachments[i].VirtualMachines, *vm)
+			}
+		}
+	}
+
+	return attachments, nil
+}
+
+// elasticIPAndVirtualMachine retrieves an Elastic IP and a virtual machine.
+func (client *Client) elasticIPAndVirtualMachine(ctx context.Context, eipID, vmID *UUID) (*IPAddress, *VirtualMachine, error) {
+	if eipID == nil {
+		return nil, nil, &ValidationError{Field: "ID", Reason: "required"}
+	}
+	if vmID == nil {
+		return nil, nil, &ValidationError{Field: "VirtualMachineID", Reason: "required"}
+	}
+
+	resp, err := client.GetWithContext(ctx, &IPAddress{ID: eipID, IsElastic: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve Elastic IP %s: %s", eipID, err)
+	}
+	eip := resp.(*IPAddress)
+
+	resp, err = client.GetWithContext(ctx, &VirtualMachine{ID: vmID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve virtual machine %s: %s", vmID, err)
+	}
+
+	return eip, resp.(*VirtualMachine), nil
+}
+
+// elasticIPSecondaryIP returns the NIC secondary IP binding the Elastic IP to the virtual
+// machine, or nil if it is not attached.
+func elasticIPSecondaryIP(eip *IPAddress, vm *VirtualMachine) *NicSecondaryIP {
+	for i := range vm.Nic {
+		for j := range vm.Nic[i].SecondaryIP {
+			if vm.Nic[i].SecondaryIP[j].IPAddress.Equal(eip.IPAddress) {
+				return &vm.Nic[i].SecondaryIP[j]
+			}
+		}
+	}
+
+	return nil
+}
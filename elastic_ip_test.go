@@ -0,0 +1,182 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthcheck_Validate(t *testing.T) {
+	require.NoError(t, Healthcheck{Mode: "tcp", Port: 22}.Validate())
+	require.NoError(t, Healthcheck{Mode: "http", Port: 80, Path: "/health", Interval: 5, Timeout: 5}.Validate())
+	require.NoError(t, Healthcheck{Mode: "https", Port: 443, Path: "/", TLSSNI: "example.net"}.Validate())
+
+	for _, tc := range []struct {
+		hc  Healthcheck
+		err string
+	}{
+		{Healthcheck{Port: 22}, "invalid Mode: required"},
+		{Healthcheck{Mode: "udp", Port: 22}, `invalid Mode: unsupported mode "udp", must be tcp, http or https`},
+		{Healthcheck{Mode: "tcp"}, "invalid Port: must be between 1 and 65535"},
+		{Healthcheck{Mode: "http", Port: 80}, "invalid Path: required for the http mode"},
+		{Healthcheck{Mode: "http", Port: 80, Path: "/", TLSSkipVerify: true}, "invalid TLSSNI: TLS settings are only supported by the https mode"},
+		{Healthcheck{Mode: "tcp", Port: 22, Interval: 4}, "invalid Interval: must be at least 5 seconds"},
+		{Healthcheck{Mode: "tcp", Port: 22, Timeout: 11}, "invalid Timeout: cannot be greater than the interval (10s)"},
+		{Healthcheck{Mode: "tcp", Port: 22, Interval: 5, Timeout: 6}, "invalid Timeout: cannot be greater than the interval (5s)"},
+		{Healthcheck{Mode: "tcp", Port: 22, StrikesFail: -1}, "invalid StrikesFail: must be positive"},
+	} {
+		require.EqualError(t, tc.hc.Validate(), tc.err)
+	}
+}
+
+func TestClient_AllocateElasticIP(t *testing.T) {
+	ts := newServer(response{200, jsonContentType, `
+{"associateipaddressresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"ipaddress": {
+		"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a99",
+		"ipaddress": "159.100.241.99",
+		"iselastic": true,
+		"healthcheck": {"mode": "http", "port": 80, "path": "/health"}
+	}},
+	"jobstatus": 1
+}}`})
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+	zoneID := MustParseUUID("1128bd56-b4d9-4ac6-a7b9-c715b187ce11")
+
+	eip, err := client.AllocateElasticIP(context.Background(), zoneID, "test", &Healthcheck{
+		Mode: "http",
+		Port: 80,
+		Path: "/health",
+	})
+	require.NoError(t, err)
+	require.True(t, eip.IsElastic)
+	require.Equal(t, "http", eip.Healthcheck.Mode)
+	require.Equal(t, 1, ts.lastResponse)
+
+	_, err = client.AllocateElasticIP(context.Background(), zoneID, "test", &Healthcheck{Mode: "http", Port: 80})
+	require.IsType(t, &ValidationError{}, err)
+	require.Equal(t, 1, ts.lastResponse)
+}
+
+// testElasticIPVirtualMachineResponse returns the listVirtualMachines response of the test
+// virtual machine n, with the Elastic IP attached to its default NIC if secondaryIPID is not empty.
+func testElasticIPVirtualMachineResponse(n int, secondaryIPID string) response {
+	secondaryIP := ""
+	if secondaryIPID != "" {
+		secondaryIP = fmt.Sprintf(`, "secondaryip": [{"id": %q, "ipaddress": "159.100.241.99"}]`, secondaryIPID)
+	}
+
+	return response{200, jsonContentType, fmt.Sprintf(`
+{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{
+		"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a0%d",
+		"nic": [{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0b0%d", "isdefault": true%s}]
+	}]
+}}`, n, n, secondaryIP)}
+}
+
+func TestClient_MoveElasticIP(t *testing.T) {
+	var (
+		eipID = MustParseUUID("7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a99")
+		vm1ID = MustParseUUID("7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a01")
+		vm2ID = MustParseUUID("7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a02")
+
+		eip = response{200, jsonContentType, `
+{"listpublicipaddressesresponse": {
+	"count": 1,
+	"publicipaddress": [{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a99", "ipaddress": "159.100.241.99", "iselastic": true}]
+}}`}
+		added = func(n int) response {
+			return response{200, jsonContentType, fmt.Sprintf(`
+{"addiptovmnicresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"nicsecondaryip": {
+		"id": "00000000-0000-0000-0000-00000000000%d",
+		"ipaddress": "159.100.241.99",
+		"nicid": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0b0%d"
+	}},
+	"jobstatus": 1
+}}`, n, n)}
+		}
+		removed = response{200, jsonContentType, `
+{"removeipfromnicresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880ce",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`}
+	)
+
+	ts := newServer(
+		// Attachment
+		eip,
+		testElasticIPVirtualMachineResponse(1, ""),
+		added(1),
+		// Attachment to the same virtual machine
+		eip,
+		testElasticIPVirtualMachineResponse(1, "00000000-0000-0000-0000-000000000001"),
+		// Move
+		eip,
+		testElasticIPVirtualMachineResponse(2, ""),
+		added(2),
+		eip,
+		testElasticIPVirtualMachineResponse(1, "00000000-0000-0000-0000-000000000001"),
+		removed,
+		// Attachments
+		eip,
+		response{200, jsonContentType, `
+{"listvirtualmachinesresponse": {
+	"count": 2,
+	"virtualmachine": [
+		{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a01", "nic": [{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0b01", "isdefault": true}]},
+		{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0a02", "nic": [{"id": "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0b02", "isdefault": true,
+			"secondaryip": [{"id": "00000000-0000-0000-0000-000000000002", "ipaddress": "159.100.241.99"}]}]}
+	]
+}}`},
+		// Detachments
+		eip,
+		testElasticIPVirtualMachineResponse(1, ""),
+		eip,
+		testElasticIPVirtualMachineResponse(2, "00000000-0000-0000-0000-000000000002"),
+		removed,
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	secondaryIP, err := client.AttachElasticIP(context.Background(), eipID, vm1ID)
+	require.NoError(t, err)
+	require.Equal(t, "159.100.241.99", secondaryIP.IPAddress.String())
+	require.Equal(t, 3, ts.lastResponse)
+
+	// Attaching again is a no-op
+	secondaryIP, err = client.AttachElasticIP(context.Background(), eipID, vm1ID)
+	require.NoError(t, err)
+	require.Equal(t, "00000000-0000-0000-0000-000000000001", secondaryIP.ID.String())
+	require.Equal(t, 5, ts.lastResponse)
+
+	secondaryIP, err = client.MoveElasticIP(context.Background(), eipID, vm1ID, vm2ID)
+	require.NoError(t, err)
+	require.Equal(t, "7c3b5f0e-2a9a-4b1e-8d6f-0b3c2d1e0b02", secondaryIP.NicID.String())
+	require.Equal(t, 11, ts.lastResponse)
+
+	attachments, err := client.ListElasticIPAttachments(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	require.Len(t, attachments[0].VirtualMachines, 1)
+	require.Equal(t, vm2ID, attachments[0].VirtualMachines[0].ID)
+	require.Equal(t, 13, ts.lastResponse)
+
+	require.Equal(t, ErrNotFound, client.DetachElasticIP(context.Background(), eipID, vm1ID))
+	require.NoError(t, client.DetachElasticIP(context.Background(), eipID, vm2ID))
+	require.Equal(t, 18, ts.lastResponse)
+
+	_, err = client.MoveElasticIP(context.Background(), eipID, vm1ID, vm1ID)
+	require.Error(t, err)
+	require.Equal(t, 18, ts.lastResponse)
+}
@@ -1,9 +1,40 @@
 package egoscale
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
 
 // ErrNotFound represents an error indicating a non-existent resource.
-var ErrNotFound = errors.New("resource not found")
+var ErrNotFound = v2.ErrNotFound
 
 // ErrTooManyFound represents an error indicating multiple results found for a single resource.
 var ErrTooManyFound = errors.New("multiple resources found")
+
+// ErrAlreadyExists represents an error indicating a resource conflicting with an existing one.
+var ErrAlreadyExists = v2.ErrAlreadyExists
+
+// ValidationError represents an invalid request parameter, detected before sending the request to the API.
+type ValidationError struct {
+	// Field is the name of the invalid parameter
+	Field string
+	// Reason describes why the parameter value is invalid
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// APIError represents an unexpected response returned by the Exoscale API V2.
+type APIError = v2.APIError
+
+// apiv2Error returns the error corresponding to a non-successful API V2 response: ErrNotFound
+// for a "404 Not Found" response, ErrAlreadyExists for a "409 Conflict" response, otherwise
+// an *APIError.
+func apiv2Error(statusCode int, status string, body []byte) error {
+	return v2.NewAPIError(statusCode, status, body)
+}
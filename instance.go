@@ -0,0 +1,199 @@
+package egoscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apiv2 "github.com/exoscale/egoscale/api/v2"
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// InstanceSpec represents the specification of a Compute instance to create.
+type InstanceSpec struct {
+	// Name is the name of the instance
+	Name string
+	// TemplateID is the ID of the template to create the instance from (required)
+	TemplateID string
+	// InstanceType is either an instance type ID, or a reference in the "<family>.<size>" form
+	// (e.g. "standard.medium", the family defaulting to "standard" if omitted) (required)
+	InstanceType string
+	// DiskSize is the size of the instance root disk in GiB (required)
+	DiskSize int64
+	// SSHKey is the name of the SSH key pair to deploy on the instance
+	SSHKey string
+	// SecurityGroupIDs is the list of Security Groups IDs to attach the instance to
+	SecurityGroupIDs []string
+	// AntiAffinityGroupIDs is the list of Anti-Affinity Groups IDs to attach the instance to
+	AntiAffinityGroupIDs []string
+	// UserData is the instance cloud-init user data, in clear form
+	UserData string
+	// IPv6Enabled enables IPv6 on the instance public network interface
+	IPv6Enabled bool
+	// NoStart prevents the instance from being started once created
+	NoStart bool
+}
+
+// Validate checks that the specification is complete and well-formed, returning a *ValidationError
+// describing the first problem found otherwise.
+func (s *InstanceSpec) Validate() error {
+	if s.TemplateID == "" {
+		return &ValidationError{Field: "TemplateID", Reason: "value is required"}
+	}
+	if _, err := ParseUUID(s.TemplateID); err != nil {
+		return &ValidationError{Field: "TemplateID", Reason: fmt.Sprintf("%q is not a valid ID", s.TemplateID)}
+	}
+
+	if s.InstanceType == "" {
+		return &ValidationError{Field: "InstanceType", Reason: "value is required"}
+	}
+
+	if s.DiskSize <= 0 {
+		return &ValidationError{Field: "DiskSize", Reason: "value must be greater than 0"}
+	}
+
+	for _, id := range s.SecurityGroupIDs {
+		if _, err := ParseUUID(id); err != nil {
+			return &ValidationError{Field: "SecurityGroupIDs", Reason: fmt.Sprintf("%q is not a valid ID", id)}
+		}
+	}
+
+	for _, id := range s.AntiAffinityGroupIDs {
+		if _, err := ParseUUID(id); err != nil {
+			return &ValidationError{Field: "AntiAffinityGroupIDs", Reason: fmt.Sprintf("%q is not a valid ID", id)}
+		}
+	}
+
+	return nil
+}
+
+// apiv2ResourceRef represents a reference to an API V2 resource in a request body.
+type apiv2ResourceRef struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// createInstanceRequest represents the body of an API V2 instance creation request. The generated
+// v2.CreateInstanceJSONRequestBody type only supports the instance name, so the request body is
+// built manually.
+type createInstanceRequest struct {
+	Name               string             `json:"name,omitempty"`
+	Template           apiv2ResourceRef   `json:"template"`
+	InstanceType       apiv2ResourceRef   `json:"instance-type"`
+	DiskSize           int64              `json:"disk-size"`
+	SSHKey             *apiv2ResourceRef  `json:"ssh-key,omitempty"`
+	SecurityGroups     []apiv2ResourceRef `json:"security-groups,omitempty"`
+	AntiAffinityGroups []apiv2ResourceRef `json:"anti-affinity-groups,omitempty"`
+	UserData           string             `json:"user-data,omitempty"`
+	IPv6Enabled        bool               `json:"ipv6-enabled,omitempty"`
+}
+
+// resolveInstanceType returns the ID of the instance type referenced by ref, either directly an
+// instance type ID or a "<family>.<size>" reference looked up in the specified zone.
+func (c *Client) resolveInstanceType(ctx context.Context, zone, ref string) (string, error) {
+	if _, err := ParseUUID(ref); err == nil {
+		return ref, nil
+	}
+
+	family, size := InstanceTypeFamilyStandard, ref
+	if parts := strings.SplitN(ref, ".", 2); len(parts) == 2 {
+		family, size = parts[0], parts[1]
+	}
+
+	instanceTypes, err := c.ListInstanceTypes(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range instanceTypes {
+		if strings.EqualFold(t.Family, family) && strings.EqualFold(t.Size, size) {
+			return t.ID, nil
+		}
+	}
+
+	return "", &ValidationError{
+		Field:  "InstanceType",
+		Reason: fmt.Sprintf("no instance type %q available in zone %s", ref, zone),
+	}
+}
+
+// CreateInstance creates a Compute instance in the specified zone according to the specified
+// specification, and returns the instance once the creation operation has completed. The
+// specification is validated before any API call, see InstanceSpec.Validate().
+func (c *Client) CreateInstance(ctx context.Context, zone string, spec *InstanceSpec) (*VirtualMachine, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	instanceTypeID, err := c.resolveInstanceType(ctx, zone, spec.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	req := createInstanceRequest{
+		Name:         spec.Name,
+		Template:     apiv2ResourceRef{ID: spec.TemplateID},
+		InstanceType: apiv2ResourceRef{ID: instanceTypeID},
+		DiskSize:     spec.DiskSize,
+		IPv6Enabled:  spec.IPv6Enabled,
+	}
+
+	if spec.SSHKey != "" {
+		req.SSHKey = &apiv2ResourceRef{Name: spec.SSHKey}
+	}
+
+	for _, id := range spec.SecurityGroupIDs {
+		req.SecurityGroups = append(req.SecurityGroups, apiv2ResourceRef{ID: id})
+	}
+
+	for _, id := range spec.AntiAffinityGroupIDs {
+		req.AntiAffinityGroups = append(req.AntiAffinityGroups, apiv2ResourceRef{ID: id})
+	}
+
+	if spec.UserData != "" {
+		req.UserData = base64.StdEncoding.EncodeToString([]byte(spec.UserData))
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := !spec.NoStart
+
+	resp, err := c.V2.CreateInstanceWithBodyWithResponse(
+		apiv2.WithZone(ctx, zone),
+		&v2.CreateInstanceParams{Start: &start},
+		"application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	res, err := v2.NewPoller().
+		WithTimeout(c.Timeout).
+		Poll(ctx, c.V2.OperationPoller(zone, *resp.JSON200.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := ParseUUID(*res.(*v2.Reference).Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The API V2 doesn't expose the instance details yet, so we retrieve them from the API V1.
+	vm, err := c.GetWithContext(ctx, &VirtualMachine{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.(*VirtualMachine), nil
+}
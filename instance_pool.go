@@ -1,5 +1,7 @@
 package egoscale
 
+import "net/url"
+
 // InstancePoolState represents the state of an Instance Pool.
 type InstancePoolState string
 
@@ -70,6 +72,10 @@ type CreateInstancePoolResponse struct {
 	State             InstancePoolState `json:"state"`
 }
 
+func (req CreateInstancePool) onBeforeSend(_ url.Values) error {
+	return validateUserDataSize(req.UserData)
+}
+
 // Response returns an empty structure to unmarshal an Instance Pool creation API response into.
 func (CreateInstancePool) Response() interface{} {
 	return new(CreateInstancePoolResponse)
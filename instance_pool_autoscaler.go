@@ -0,0 +1,386 @@
+package egoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Clock abstracts the passing of time, so that time dependent logic can be tested.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// InstancePoolMetricSource provides the metric driving an Instance Pool autoscaler.
+type InstancePoolMetricSource interface {
+	// Metric returns the current value of the metric for the Instance Pool, e.g. the average CPU
+	// usage of its members or the depth of the queue they consume.
+	Metric(ctx context.Context, pool *InstancePool) (float64, error)
+}
+
+// InstancePoolHealthSource reports the unhealthy members of an Instance Pool, which are evicted
+// first when scaling down.
+type InstancePoolHealthSource interface {
+	Unhealthy(ctx context.Context, pool *InstancePool) ([]UUID, error)
+}
+
+// AutoscalingMetricType represents how a metric relates to the size of an Instance Pool.
+type AutoscalingMetricType string
+
+const (
+	// AutoscalingMetricAverage is a metric averaged over the pool members (e.g. CPU usage): the
+	// desired size is ceil(size * value / target)
+	AutoscalingMetricAverage AutoscalingMetricType = "average"
+	// AutoscalingMetricTotal is a metric absorbed collectively by the pool members (e.g. a queue
+	// depth): the desired size is ceil(value / target)
+	AutoscalingMetricTotal AutoscalingMetricType = "total"
+)
+
+// InstancePoolAutoscalingPolicy represents a target tracking autoscaling policy: the pool size is
+// adjusted so that the metric value per member approaches the target value.
+type InstancePoolAutoscalingPolicy struct {
+	MinSize int
+	MaxSize int
+	// MetricType indicates how the metric relates to the pool size (default: average)
+	MetricType AutoscalingMetricType
+	// TargetValue is the metric value targeted per member
+	TargetValue float64
+	// Tolerance is the relative deviation from the target value below which the pool is not
+	// scaled (e.g. 0.1 for 10%)
+	Tolerance float64
+	// ScaleUpCooldown and ScaleDownCooldown are the minimum delays after a scaling action before
+	// the pool can respectively be scaled up or down again
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+	// MaxScaleUpStep and MaxScaleDownStep limit the number of members added or removed by a
+	// single scaling action (0 for no limit)
+	MaxScaleUpStep   int
+	MaxScaleDownStep int
+}
+
+// Validate checks that the policy is applicable.
+func (p InstancePoolAutoscalingPolicy) Validate() error {
+	switch {
+	case p.MinSize < 0:
+		return &ValidationError{Field: "MinSize", Reason: "must be positive"}
+	case p.MaxSize < 1 || p.MaxSize < p.MinSize:
+		return &ValidationError{Field: "MaxSize", Reason: "must be greater than 0 and MinSize"}
+	case p.TargetValue <= 0:
+		return &ValidationError{Field: "TargetValue", Reason: "must be greater than 0"}
+	case p.Tolerance < 0:
+		return &ValidationError{Field: "Tolerance", Reason: "must be positive"}
+	case p.ScaleUpCooldown < 0 || p.ScaleDownCooldown < 0:
+		return &ValidationError{Field: "Cooldown", Reason: "must be positive"}
+	case p.MaxScaleUpStep < 0 || p.MaxScaleDownStep < 0:
+		return &ValidationError{Field: "MaxScaleStep", Reason: "must be positive"}
+	}
+
+	switch p.MetricType {
+	case "", AutoscalingMetricAverage, AutoscalingMetricTotal:
+	default:
+		return &ValidationError{Field: "MetricType", Reason: fmt.Sprintf("unsupported type %q", p.MetricType)}
+	}
+
+	return nil
+}
+
+// DesiredSize returns the size an Instance Pool of the specified size should have for the metric
+// value, bounded by the policy size and step limits. Cooldowns are not taken into account.
+func (p InstancePoolAutoscalingPolicy) DesiredSize(size int, value float64) int {
+	desired := size
+
+	switch {
+	case p.MetricType == AutoscalingMetricTotal && size == 0:
+		desired = int(math.Ceil(value / p.TargetValue))
+
+	case p.MetricType == AutoscalingMetricTotal:
+		if ratio := value / (p.TargetValue * float64(size)); math.Abs(ratio-1) > p.Tolerance {
+			desired = int(math.Ceil(value / p.TargetValue))
+		}
+
+	case size == 0:
+		// An empty Instance Pool can't be scaled in proportion to the average metric value:
+		// it gets a first member if the value is above the target.
+		if value > p.TargetValue {
+			desired = 1
+		}
+
+	default:
+		if ratio := value / p.TargetValue; math.Abs(ratio-1) > p.Tolerance {
+			desired = int(math.Ceil(float64(size) * ratio))
+		}
+	}
+
+	if p.MaxScaleUpStep > 0 && desired > size+p.MaxScaleUpStep {
+		desired = size + p.MaxScaleUpStep
+	}
+	if p.MaxScaleDownStep > 0 && desired < size-p.MaxScaleDownStep {
+		desired = size - p.MaxScaleDownStep
+	}
+
+	if desired < p.MinSize {
+		desired = p.MinSize
+	}
+	if desired > p.MaxSize {
+		desired = p.MaxSize
+	}
+
+	return desired
+}
+
+// InstancePoolScalingAction represents the action decided by an Instance Pool autoscaler.
+type InstancePoolScalingAction string
+
+const (
+	// ScalingActionNone leaves the Instance Pool as is
+	ScalingActionNone InstancePoolScalingAction = "none"
+	// ScalingActionScaleUp adds members to the Instance Pool
+	ScalingActionScaleUp InstancePoolScalingAction = "scale-up"
+	// ScalingActionScaleDown evicts members from the Instance Pool
+	ScalingActionScaleDown InstancePoolScalingAction = "scale-down"
+)
+
+// InstancePoolScalingDecision represents a decision of an Instance Pool autoscaler.
+type InstancePoolScalingDecision struct {
+	Time        time.Time
+	PoolID      *UUID
+	Metric      float64
+	CurrentSize int
+	DesiredSize int
+	Action      InstancePoolScalingAction
+	// Reason explains why no action was taken
+	Reason string
+	// Evicted lists the members evicted when scaling down
+	Evicted []UUID
+	// Err is the error which prevented the decision or its application, if any
+	Err error
+}
+
+// String returns a human-readable description of the decision.
+func (d InstancePoolScalingDecision) String() string {
+	switch {
+	case d.Err != nil:
+		return fmt.Sprintf("%s: error: %s", d.PoolID, d.Err)
+	case d.Action == ScalingActionNone:
+		return fmt.Sprintf("%s: metric %g, size %d: no action (%s)", d.PoolID, d.Metric, d.CurrentSize, d.Reason)
+	}
+
+	return fmt.Sprintf("%s: metric %g: %s from %d to %d", d.PoolID, d.Metric, d.Action, d.CurrentSize, d.DesiredSize)
+}
+
+// InstancePoolAutoscaler scales an Instance Pool according to an autoscaling policy, evicting
+// the unhealthy members first, then the oldest ones, when scaling down.
+type InstancePoolAutoscaler struct {
+	ZoneID  *UUID
+	PoolID  *UUID
+	Policy  InstancePoolAutoscalingPolicy
+	Metrics InstancePoolMetricSource
+	// Health reports the unhealthy members (optional, members not Running are deemed unhealthy)
+	Health InstancePoolHealthSource
+	// Clock is the clock used to apply cooldowns and pace Run (default: system clock)
+	Clock Clock
+	// OnDecision is called with every decision, applied or not
+	OnDecision func(InstancePoolScalingDecision)
+	// DryRun decides without applying the decisions
+	DryRun bool
+
+	client      *Client
+	lastScaling time.Time
+}
+
+// NewInstancePoolAutoscaler creates an autoscaler for the specified Instance Pool.
+func NewInstancePoolAutoscaler(client *Client, zoneID, poolID *UUID, metrics InstancePoolMetricSource,
+	policy InstancePoolAutoscalingPolicy) (*InstancePoolAutoscaler, error) {
+	if zoneID == nil || poolID == nil {
+		return nil, &ValidationError{Field: "PoolID", Reason: "the Instance Pool ID and ZoneID are required"}
+	}
+	if metrics == nil {
+		return nil, &ValidationError{Field: "Metrics", Reason: "required"}
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &InstancePoolAutoscaler{
+		ZoneID:  zoneID,
+		PoolID:  poolID,
+		Policy:  policy,
+		Metrics: metrics,
+		Clock:   systemClock{},
+		client:  client,
+	}, nil
+}
+
+// Run evaluates the Instance Pool every interval until the context is done. Errors are reported
+// through OnDecision and do not stop the autoscaler. The interval must be positive.
+func (a *InstancePoolAutoscaler) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s, must be positive", interval)
+	}
+
+	for {
+		_, _ = a.Evaluate(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.Clock.After(interval):
+		}
+	}
+}
+
+// Evaluate performs a single autoscaling iteration: it retrieves the Instance Pool and the
+// metric, decides whether to scale the pool, and applies the decision. The decision is returned
+// (and reported through OnDecision) in all cases, along with the error which occurred if any.
+func (a *InstancePoolAutoscaler) Evaluate(ctx context.Context) (*InstancePoolScalingDecision, error) {
+	decision := &InstancePoolScalingDecision{
+		Time:   a.Clock.Now(),
+		PoolID: a.PoolID,
+		Action: ScalingActionNone,
+	}
+
+	decision.Err = a.evaluate(ctx, decision)
+	if a.OnDecision != nil {
+		a.OnDecision(*decision)
+	}
+
+	return decision, decision.Err
+}
+
+func (a *InstancePoolAutoscaler) evaluate(ctx context.Context, decision *InstancePoolScalingDecision) error {
+	resp, err := a.client.RequestWithContext(ctx, &GetInstancePool{ID: a.PoolID, ZoneID: a.ZoneID})
+	if err != nil {
+		return err
+	}
+	pools := resp.(*GetInstancePoolResponse).InstancePools
+	if len(pools) == 0 {
+		return ErrNotFound
+	}
+	pool := &pools[0]
+
+	decision.CurrentSize = pool.Size
+	decision.DesiredSize = pool.Size
+
+	if pool.State != InstancePoolRunning {
+		decision.Reason = fmt.Sprintf("pool is %s", pool.State)
+		return nil
+	}
+
+	if decision.Metric, err = a.Metrics.Metric(ctx, pool); err != nil {
+		return fmt.Errorf("unable to retrieve metric: %s", err)
+	}
+
+	desired := a.Policy.DesiredSize(pool.Size, decision.Metric)
+	outOfBounds := pool.Size < a.Policy.MinSize || pool.Size > a.Policy.MaxSize
+	elapsed := decision.Time.Sub(a.lastScaling)
+
+	switch {
+	case desired == pool.Size:
+		decision.Reason = "metric within target"
+		return nil
+
+	case outOfBounds:
+		// Size bounds are enforced regardless of the cooldowns
+
+	case desired > pool.Size && !a.lastScaling.IsZero() && elapsed < a.Policy.ScaleUpCooldown:
+		decision.Reason = fmt.Sprintf("scale up cooldown (%s remaining)", a.Policy.ScaleUpCooldown-elapsed)
+		return nil
+
+	case desired < pool.Size && !a.lastScaling.IsZero() && elapsed < a.Policy.ScaleDownCooldown:
+		decision.Reason = fmt.Sprintf("scale down cooldown (%s remaining)", a.Policy.ScaleDownCooldown-elapsed)
+		return nil
+	}
+
+	decision.DesiredSize = desired
+	if desired > pool.Size {
+		decision.Action = ScalingActionScaleUp
+	} else {
+		decision.Action = ScalingActionScaleDown
+		if decision.Evicted, err = a.evictionCandidates(ctx, pool, pool.Size-desired); err != nil {
+			return err
+		}
+	}
+
+	if a.DryRun {
+		return nil
+	}
+
+	if decision.Action == ScalingActionScaleUp {
+		err = a.client.BooleanRequestWithContext(ctx, &ScaleInstancePool{ID: a.PoolID, ZoneID: a.ZoneID, Size: desired})
+	} else {
+		err = a.client.BooleanRequestWithContext(ctx, &EvictInstancePoolMembers{
+			ID:        a.PoolID,
+			ZoneID:    a.ZoneID,
+			MemberIDs: decision.Evicted,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s Instance Pool: %s", decision.Action, err)
+	}
+	a.lastScaling = decision.Time
+
+	return nil
+}
+
+// evictionCandidates returns the n members to evict: the unhealthy ones first, then the oldest.
+func (a *InstancePoolAutoscaler) evictionCandidates(ctx context.Context, pool *InstancePool, n int) ([]UUID, error) {
+	unhealthy := make(map[string]bool)
+	if a.Health != nil {
+		ids, err := a.Health.Unhealthy(ctx, pool)
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve members health: %s", err)
+		}
+		for _, id := range ids {
+			unhealthy[id.String()] = true
+		}
+	}
+
+	members := make([]VirtualMachine, 0, len(pool.VirtualMachines))
+	for _, vm := range pool.VirtualMachines {
+		if vm.ID == nil {
+			continue
+		}
+		if vm.State != string(VirtualMachineRunning) {
+			unhealthy[vm.ID.String()] = true
+		}
+		members = append(members, vm)
+	}
+	if len(members) < n {
+		return nil, errors.New("not enough members to evict")
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		ui, uj := unhealthy[members[i].ID.String()], unhealthy[members[j].ID.String()]
+		if ui != uj {
+			return ui
+		}
+
+		ci, erri := members[i].CreatedAt()
+		cj, errj := members[j].CreatedAt()
+		if erri == nil && errj == nil && !ci.Equal(cj) {
+			return ci.Before(cj)
+		}
+
+		return members[i].ID.String() < members[j].ID.String()
+	})
+
+	evicted := make([]UUID, n)
+	for i := range evicted {
+		evicted[i] = *members[i].ID
+	}
+
+	return evicted, nil
+}
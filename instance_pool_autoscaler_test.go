@@ -0,0 +1,230 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a fake Clock, advancing by the duration waited for.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// testMetrics is a fake InstancePoolMetricSource returning a fixed value.
+type testMetrics struct {
+	value float64
+	err   error
+}
+
+func (m *testMetrics) Metric(_ context.Context, _ *InstancePool) (float64, error) {
+	return m.value, m.err
+}
+
+// testHealth is a fake InstancePoolHealthSource.
+type testHealth []UUID
+
+func (h testHealth) Unhealthy(_ context.Context, _ *InstancePool) ([]UUID, error) {
+	return h, nil
+}
+
+// testInstancePoolMember is a running Instance Pool member, identified by the n suffix of its ID.
+type testInstancePoolMember struct {
+	n       int
+	created string
+}
+
+// testGetInstancePoolResponse returns a getInstancePool response of the specified members.
+func testGetInstancePoolResponse(state InstancePoolState, members ...testInstancePoolMember) response {
+	vms := make([]string, len(members))
+	for i, m := range members {
+		vms[i] = fmt.Sprintf(`{"id": "00000000-0000-0000-0000-%012d", "created": %q, "state": "Running",
+			"nic": [{"ipaddress": "10.0.0.%d", "isdefault": true}]}`, m.n, m.created, m.n)
+	}
+
+	return response{200, jsonContentType, fmt.Sprintf(`
+{"getinstancepoolresponse": {
+	"count": 1,
+	"instancepool": [{
+		"id": "6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1",
+		"zoneid": "1128bd56-b4d9-4ac6-a7b9-c715b187ce11",
+		"state": %q,
+		"size": %d,
+		"virtualmachines": [%s]
+	}]
+}}`, state, len(members), strings.Join(vms, ", "))}
+}
+
+func TestInstancePoolAutoscalingPolicy_DesiredSize(t *testing.T) {
+	policy := InstancePoolAutoscalingPolicy{MinSize: 1, MaxSize: 10, TargetValue: 50, Tolerance: 0.1}
+
+	for _, tc := range []struct {
+		size    int
+		value   float64
+		desired int
+	}{
+		{4, 50, 4},
+		{4, 54, 4},
+		{4, 100, 8},
+		{4, 200, 10},
+		{4, 20, 2},
+		{4, 0, 1},
+		{0, 0, 1},
+	} {
+		require.Equal(t, tc.desired, policy.DesiredSize(tc.size, tc.value), "size %d value %g", tc.size, tc.value)
+	}
+
+	policy.MaxScaleUpStep = 2
+	policy.MaxScaleDownStep = 1
+	require.Equal(t, 6, policy.DesiredSize(4, 100))
+	require.Equal(t, 3, policy.DesiredSize(4, 0))
+
+	// An empty Instance Pool gets a first member if the average value is above the target
+	policy = InstancePoolAutoscalingPolicy{MaxSize: 10, TargetValue: 50, Tolerance: 0.1}
+	require.Equal(t, 0, policy.DesiredSize(0, 0))
+	require.Equal(t, 0, policy.DesiredSize(0, 50))
+	require.Equal(t, 1, policy.DesiredSize(0, 80))
+	policy.MinSize = 2
+	require.Equal(t, 2, policy.DesiredSize(0, 80))
+
+	policy = InstancePoolAutoscalingPolicy{MaxSize: 10, MetricType: AutoscalingMetricTotal, TargetValue: 100}
+	require.Equal(t, 0, policy.DesiredSize(3, 0))
+	require.Equal(t, 3, policy.DesiredSize(0, 250))
+	require.Equal(t, 3, policy.DesiredSize(3, 300))
+	require.Equal(t, 10, policy.DesiredSize(3, 5000))
+
+	require.EqualError(t, InstancePoolAutoscalingPolicy{MinSize: 3, MaxSize: 2, TargetValue: 1}.Validate(),
+		"invalid MaxSize: must be greater than 0 and MinSize")
+	require.Error(t, InstancePoolAutoscalingPolicy{MaxSize: 2}.Validate())
+	require.Error(t, InstancePoolAutoscalingPolicy{MaxSize: 2, TargetValue: 1, MetricType: "lolnope"}.Validate())
+}
+
+func TestInstancePoolAutoscaler(t *testing.T) {
+	var (
+		zoneID = MustParseUUID("1128bd56-b4d9-4ac6-a7b9-c715b187ce11")
+		poolID = MustParseUUID("6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1")
+
+		members = []testInstancePoolMember{
+			{1, "2020-01-01T12:00:00+0000"},
+			{2, "2020-01-01T11:00:00+0000"},
+			{3, "2020-02-01T03:00:00+0000"},
+			{4, "2020-02-01T04:00:00+0000"},
+			{5, "2020-02-01T05:00:00+0000"},
+		}
+		scaled = response{200, jsonContentType, `{"scaleinstancepoolresponse": {"success": true}}`}
+		remain = []testInstancePoolMember{members[0], members[2], members[4]}
+	)
+
+	ts := newServer(
+		testGetInstancePoolResponse(InstancePoolRunning, members[:2]...),
+		scaled,
+		// Within the scale up cooldown
+		testGetInstancePoolResponse(InstancePoolRunning, members[:4]...),
+		testGetInstancePoolResponse(InstancePoolRunning, members[:4]...),
+		scaled,
+		// Scaling down
+		testGetInstancePoolResponse(InstancePoolRunning, members...),
+		response{200, jsonContentType, `{"evictinstancepoolmembersresponse": {"success": true}}`},
+		// Metric error
+		testGetInstancePoolResponse(InstancePoolRunning, remain...),
+		// Run
+		testGetInstancePoolResponse(InstancePoolRunning, remain...),
+		testGetInstancePoolResponse(InstancePoolRunning, remain...),
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+	metrics := &testMetrics{value: 100}
+	clock := &testClock{now: time.Date(2020, 3, 31, 12, 0, 0, 0, time.UTC)}
+
+	autoscaler, err := NewInstancePoolAutoscaler(client, zoneID, poolID, metrics, InstancePoolAutoscalingPolicy{
+		MinSize:           1,
+		MaxSize:           5,
+		TargetValue:       50,
+		ScaleUpCooldown:   time.Minute,
+		ScaleDownCooldown: 5 * time.Minute,
+		MaxScaleDownStep:  2,
+	})
+	require.NoError(t, err)
+
+	var decisions []string
+	autoscaler.Clock = clock
+	autoscaler.OnDecision = func(d InstancePoolScalingDecision) {
+		decisions = append(decisions, d.String())
+	}
+
+	decision, err := autoscaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ScalingActionScaleUp, decision.Action)
+	require.Equal(t, 4, decision.DesiredSize)
+	require.Equal(t, 2, ts.lastResponse)
+
+	// Within the scale up cooldown
+	decision, err = autoscaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ScalingActionNone, decision.Action)
+	require.Equal(t, "scale up cooldown (1m0s remaining)", decision.Reason)
+	require.Equal(t, 3, ts.lastResponse)
+
+	clock.now = clock.now.Add(time.Minute)
+	decision, err = autoscaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 5, decision.DesiredSize)
+	require.Equal(t, 5, ts.lastResponse)
+
+	// Scaling down evicts the unhealthy members first, then the oldest ones
+	metrics.value = 10
+	autoscaler.Health = testHealth{*MustParseUUID("00000000-0000-0000-0000-000000000004")}
+	clock.now = clock.now.Add(5 * time.Minute)
+	decision, err = autoscaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ScalingActionScaleDown, decision.Action)
+	require.Equal(t, []UUID{
+		*MustParseUUID("00000000-0000-0000-0000-000000000004"),
+		*MustParseUUID("00000000-0000-0000-0000-000000000002"),
+	}, decision.Evicted)
+	require.Equal(t, 7, ts.lastResponse)
+
+	// Metric errors are reported without scaling
+	metrics.err = fmt.Errorf("lolnope")
+	_, err = autoscaler.Evaluate(context.Background())
+	require.EqualError(t, err, "unable to retrieve metric: lolnope")
+	require.Equal(t, 8, ts.lastResponse)
+	metrics.err = nil
+
+	require.Error(t, autoscaler.Run(context.Background(), 0))
+
+	// Run evaluates the pool at every interval until the context is done
+	autoscaler.DryRun = true
+	ctx, cancel := context.WithCancel(context.Background())
+	autoscaler.OnDecision = func(d InstancePoolScalingDecision) {
+		decisions = append(decisions, d.String())
+		if len(decisions) == 7 {
+			cancel()
+		}
+	}
+	require.Equal(t, context.Canceled, autoscaler.Run(ctx, 5*time.Minute))
+	require.Equal(t, []string{
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 100: scale-up from 2 to 4",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 100, size 4: no action (scale up cooldown (1m0s remaining))",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 100: scale-up from 4 to 5",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 10: scale-down from 5 to 3",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: error: unable to retrieve metric: lolnope",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 10, size 3: no action (scale down cooldown (5m0s remaining))",
+		"6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1: metric 10: scale-down from 3 to 1",
+	}, decisions)
+	require.Equal(t, 10, ts.lastResponse)
+}
@@ -0,0 +1,378 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// RollInstancePoolOptions represents the options of RollInstancePool.
+type RollInstancePoolOptions struct {
+	ZoneID *UUID
+	// MaxSurge is the number of members created above the pool size during a batch (default: 1
+	// if MaxUnavailable is 0)
+	MaxSurge int
+	// MaxUnavailable is the number of old members evicted during a batch before their
+	// replacements are ready
+	MaxUnavailable int
+	// Rollback is the previous configuration of the Instance Pool, restored on failure: the
+	// members created by the roll are then evicted, and the pool scaled back to its original size.
+	// If nil, only the members created by the failed batch are evicted.
+	Rollback *UpdateInstancePool
+	// OnProgress is called once each batch is completed
+	OnProgress func(RollInstancePoolProgress)
+	// Wait represents the options of the waits for the pool, its new members and the Network
+	// Load Balancers services targeting it
+	Wait WaitOptions
+}
+
+// RollInstancePoolProgress represents the progress of an Instance Pool roll.
+type RollInstancePoolProgress struct {
+	Batch int
+	// Added lists the members created by the batch
+	Added []UUID
+	// Evicted lists the old members evicted by the batch
+	Evicted []UUID
+	// Remaining is the number of old members remaining to replace
+	Remaining int
+}
+
+// RollInstancePoolError represents the failure of an Instance Pool roll.
+type RollInstancePoolError struct {
+	// Batch is the batch which failed
+	Batch int
+	Err   error
+	// RollbackErr is the error which occurred cleaning up the failed batch or rolling back
+	RollbackErr error
+}
+
+// Error implements the error interface
+func (e *RollInstancePoolError) Error() string {
+	msg := fmt.Sprintf("Instance Pool roll failed at batch %d: %s", e.Batch, e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %s)", e.RollbackErr)
+	}
+
+	return msg
+}
+
+// Unwrap returns the error of the failed batch
+func (e *RollInstancePoolError) Unwrap() error {
+	return e.Err
+}
+
+// RollInstancePool replaces the members of an Instance Pool in batches, e.g. after its template or
+// user data have been changed with UpdateInstancePool. For each batch the pool is scaled up, then
+// once the new members are Running and reported healthy by the Network Load Balancer services
+// targeting the pool (if any), old members are evicted. A batch replaces up to
+// opts.MaxSurge + opts.MaxUnavailable members, opts.MaxUnavailable of them being evicted before
+// the pool is scaled up. A *RollInstancePoolError is returned on failure, after the cleanup or
+// rollback described by opts.Rollback.
+func (client *Client) RollInstancePool(ctx context.Context, poolID *UUID, opts RollInstancePoolOptions) error {
+	if poolID == nil || opts.ZoneID == nil {
+		return &ValidationError{Field: "ID", Reason: "the Instance Pool ID and ZoneID are required"}
+	}
+	if opts.MaxSurge < 0 || opts.MaxUnavailable < 0 {
+		return &ValidationError{Field: "MaxSurge", Reason: "must be positive"}
+	}
+	if opts.MaxSurge == 0 && opts.MaxUnavailable == 0 {
+		opts.MaxSurge = 1
+	}
+
+	roll := instancePoolRoll{client: client, poolID: poolID, opts: opts, known: make(map[string]bool)}
+
+	pool, err := roll.pool(ctx, InstancePoolStateIs(InstancePoolRunning))
+	if err != nil {
+		return err
+	}
+	roll.size = pool.Size
+	roll.originalSize = pool.Size
+
+	var old []UUID
+	for _, vm := range pool.VirtualMachines {
+		if vm.ID == nil {
+			continue
+		}
+		old = append(old, *vm.ID)
+		roll.known[vm.ID.String()] = true
+	}
+
+	if roll.nlbs, err = client.instancePoolNetworkLoadBalancers(ctx, opts.ZoneID, poolID); err != nil {
+		return err
+	}
+
+	for batch := 1; len(old) > 0; batch++ {
+		n := opts.MaxSurge + opts.MaxUnavailable
+		if n > len(old) {
+			n = len(old)
+		}
+		unavailable := opts.MaxUnavailable
+		if unavailable > n {
+			unavailable = n
+		}
+
+		progress := RollInstancePoolProgress{Batch: batch}
+
+		if unavailable > 0 {
+			if err := roll.evict(ctx, old[:unavailable]); err != nil {
+				return roll.fail(batch, err, nil)
+			}
+			progress.Evicted = append(progress.Evicted, old[:unavailable]...)
+			old = old[unavailable:]
+		}
+
+		added, err := roll.scaleUp(ctx, roll.size+n)
+		if err != nil {
+			return roll.fail(batch, err, added)
+		}
+		progress.Added = added
+
+		if err := roll.evict(ctx, old[:n-unavailable]); err != nil {
+			return roll.fail(batch, err, nil)
+		}
+		progress.Evicted = append(progress.Evicted, old[:n-unavailable]...)
+		old = old[n-unavailable:]
+
+		progress.Remaining = len(old)
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+	}
+
+	return nil
+}
+
+// instancePoolRoll represents the state of an Instance Pool roll.
+type instancePoolRoll struct {
+	client *Client
+	poolID *UUID
+	opts   RollInstancePoolOptions
+	nlbs   []*NetworkLoadBalancer
+
+	// known indexes the members seen so far, to identify the members created
+	known map[string]bool
+	// created lists the members created by the roll
+	created      []UUID
+	size         int
+	originalSize int
+}
+
+// pool waits for the Instance Pool to satisfy the predicate, and returns it. If the wait fails,
+// the last state observed is returned along with the error, or nil if none.
+func (r *instancePoolRoll) pool(ctx context.Context, predicate WaitPredicate) (*InstancePool, error) {
+	pool, err := r.client.WaitFor(ctx, &InstancePool{ID: r.poolID, ZoneID: r.opts.ZoneID}, predicate, r.opts.Wait)
+	current, _ := pool.(*InstancePool)
+
+	return current, err
+}
+
+// request sends a synchronous request, bounded by the client timeout.
+func (r *instancePoolRoll) request(ctx context.Context, command Command) error {
+	ctx, cancel := context.WithTimeout(ctx, r.client.Timeout)
+	defer cancel()
+
+	return r.client.BooleanRequestWithContext(ctx, command)
+}
+
+// evict evicts members from the Instance Pool, and waits for it to be running again.
+func (r *instancePoolRoll) evict(ctx context.Context, members []UUID) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	if err := r.request(ctx, &EvictInstancePoolMembers{
+		ID:        r.poolID,
+		ZoneID:    r.opts.ZoneID,
+		MemberIDs: members,
+	}); err != nil {
+		return fmt.Errorf("unable to evict members: %s", err)
+	}
+	r.size -= len(members)
+
+	_, err := r.pool(ctx, instancePoolSizeIs(r.size))
+	return err
+}
+
+// scaleUp scales the Instance Pool up to the specified size, and waits for the members created to
+// be Running and healthy. The members created are returned even if the wait fails.
+func (r *instancePoolRoll) scaleUp(ctx context.Context, size int) ([]UUID, error) {
+	if err := r.request(ctx, &ScaleInstancePool{
+		ID:     r.poolID,
+		ZoneID: r.opts.ZoneID,
+		Size:   size,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to scale up: %s", err)
+	}
+	r.size = size
+
+	// The members created are identified from the last state observed, even if the pool
+	// didn't reach the expected size, so that they can be evicted on failure.
+	pool, err := r.pool(ctx, instancePoolSizeIs(size))
+
+	var added []UUID
+	if pool != nil {
+		for _, vm := range pool.VirtualMachines {
+			if vm.ID != nil && !r.known[vm.ID.String()] {
+				r.known[vm.ID.String()] = true
+				added = append(added, *vm.ID)
+			}
+		}
+	}
+	r.created = append(r.created, added...)
+
+	if err != nil {
+		return added, err
+	}
+
+	ips := make([]net.IP, 0, len(added))
+	for i := range added {
+		vm, err := r.client.WaitFor(ctx, &VirtualMachine{ID: &added[i]}, VirtualMachineStateIs(VirtualMachineRunning), r.opts.Wait)
+		if err != nil {
+			return added, fmt.Errorf("member %s: %s", added[i], err)
+		}
+		if ip := vm.(*VirtualMachine).IP(); ip != nil {
+			ips = append(ips, *ip)
+		}
+	}
+
+	for _, nlb := range r.nlbs {
+		if err := r.waitHealthy(ctx, nlb, ips); err != nil {
+			return added, fmt.Errorf("Network Load Balancer %s: %s", nlb.Name, err)
+		}
+	}
+
+	return added, nil
+}
+
+// waitHealthy waits for the services of the Network Load Balancer targeting the Instance Pool to
+// report the specified target servers healthy, within the wait options timeout (or the client
+// timeout if none).
+func (r *instancePoolRoll) waitHealthy(ctx context.Context, nlb *NetworkLoadBalancer, ips []net.IP) error {
+	timeout := r.opts.Wait.Timeout
+	if timeout <= 0 {
+		timeout = r.client.Timeout
+	}
+
+	_, err := v2.NewPoller().
+		WithInterval(r.opts.Wait.Interval).
+		WithTimeout(timeout).
+		Poll(ctx, func(ctx context.Context) (bool, interface{}, error) {
+			current, err := r.client.GetNetworkLoadBalancer(ctx, nlb.zone, nlb.ID)
+			if err != nil {
+				return true, nil, err
+			}
+
+			for _, svc := range current.Services {
+				if svc.InstancePoolID != r.poolID.String() {
+					continue
+				}
+
+				healthy := make(map[string]bool)
+				for _, st := range svc.HealthcheckStatus {
+					healthy[st.InstanceIP.String()] = st.Status == nlbServerStatusHealthy
+				}
+				for _, ip := range ips {
+					if !healthy[ip.String()] {
+						return false, nil, nil
+					}
+				}
+			}
+
+			return true, current, nil
+		})
+
+	return err
+}
+
+// fail cleans up the failed batch or rolls the Instance Pool back, and returns the roll error.
+// The cleanup is performed with a context of its own, so that it happens even if the roll
+// context is over: each request is bounded by the client timeout, and each wait by the wait
+// options timeout (or the client timeout if none).
+func (r *instancePoolRoll) fail(batch int, err error, added []UUID) error {
+	rerr := &RollInstancePoolError{Batch: batch, Err: err}
+
+	ctx := context.Background()
+	if r.opts.Wait.Timeout <= 0 {
+		r.opts.Wait.Timeout = r.client.Timeout
+	}
+
+	if r.opts.Rollback == nil {
+		rerr.RollbackErr = r.evict(ctx, added)
+		return rerr
+	}
+
+	rollback := *r.opts.Rollback
+	rollback.ID, rollback.ZoneID = r.poolID, r.opts.ZoneID
+	if err := r.request(ctx, &rollback); err != nil {
+		rerr.RollbackErr = fmt.Errorf("unable to restore the configuration: %s", err)
+		return rerr
+	}
+
+	if err := r.evict(ctx, r.created); err != nil {
+		rerr.RollbackErr = err
+		return rerr
+	}
+
+	if r.size < r.originalSize {
+		if err := r.request(ctx, &ScaleInstancePool{
+			ID:     r.poolID,
+			ZoneID: r.opts.ZoneID,
+			Size:   r.originalSize,
+		}); err != nil {
+			rerr.RollbackErr = fmt.Errorf("unable to scale up: %s", err)
+			return rerr
+		}
+		r.size = r.originalSize
+		_, rerr.RollbackErr = r.pool(ctx, instancePoolSizeIs(r.size))
+	}
+
+	return rerr
+}
+
+// instancePoolSizeIs returns a predicate satisfied when the Instance Pool is running with the
+// specified number of members.
+func instancePoolSizeIs(size int) WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		pool, ok := resource.(*InstancePool)
+		if !ok || pool == nil {
+			return false, "", nil
+		}
+
+		state := fmt.Sprintf("%s (%d/%d members)", pool.State, len(pool.VirtualMachines), size)
+		return pool.State == InstancePoolRunning && len(pool.VirtualMachines) == size, state, nil
+	}
+}
+
+// instancePoolNetworkLoadBalancers returns the Network Load Balancers having a service targeting
+// the Instance Pool. Each Network Load Balancer is retrieved individually, as the list returned
+// by the API may not include their services.
+func (client *Client) instancePoolNetworkLoadBalancers(ctx context.Context, zoneID, poolID *UUID) ([]*NetworkLoadBalancer, error) {
+	resp, err := client.GetWithContext(ctx, &Zone{ID: zoneID})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve zone: %s", err)
+	}
+
+	nlbs, err := client.ListNetworkLoadBalancers(ctx, resp.(*Zone).Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Network Load Balancers: %s", err)
+	}
+
+	targeting := make([]*NetworkLoadBalancer, 0)
+	for _, item := range nlbs {
+		nlb, err := client.GetNetworkLoadBalancer(ctx, resp.(*Zone).Name, item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve Network Load Balancer %s: %s", item.ID, err)
+		}
+
+		for _, svc := range nlb.Services {
+			if svc.InstancePoolID == poolID.String() {
+				targeting = append(targeting, nlb)
+				break
+			}
+		}
+	}
+
+	return targeting, nil
+}
@@ -0,0 +1,251 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+var (
+	testRollZoneID = MustParseUUID("1128bd56-b4d9-4ac6-a7b9-c715b187ce11")
+	testRollPoolID = MustParseUUID("6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1")
+
+	testRollZones = response{200, jsonContentType, `
+{"listzonesresponse": {
+	"count": 1,
+	"zone": [{"id": "1128bd56-b4d9-4ac6-a7b9-c715b187ce11", "name": "ch-gva-2"}]
+}}`}
+)
+
+// testRollMembers returns the members of the test Instance Pool identified by the n suffix of
+// their ID, created one hour apart.
+func testRollMembers(n ...int) []testInstancePoolMember {
+	members := make([]testInstancePoolMember, len(n))
+	for i := range n {
+		members[i] = testInstancePoolMember{n[i], fmt.Sprintf("2020-01-01T%02d:00:00+0000", n[i])}
+	}
+
+	return members
+}
+
+// testRollVirtualMachineResponse returns the listVirtualMachines response of a running member.
+func testRollVirtualMachineResponse(n int) response {
+	return response{200, jsonContentType, fmt.Sprintf(`
+{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{
+		"id": "00000000-0000-0000-0000-%012d",
+		"state": "Running",
+		"nic": [{"ipaddress": "10.0.0.%d", "isdefault": true}]
+	}]
+}}`, n, n)}
+}
+
+// testRollClient returns a client using the specified API V1 test server, and an API V2 mock
+// client serving the test Network Load Balancer with a single service targeting the Instance
+// Pool, reporting healthy all the members except the ones which IP address is listed in
+// unhealthy.
+func testRollClient(t *testing.T, ts *testServer, unhealthy ...string) *Client {
+	var (
+		poolID   = testRollPoolID.String()
+		statuses = make([]v2.LoadBalancerServerStatus, 0)
+		err      error
+	)
+
+	for i := 1; i < 10; i++ {
+		ip, status := fmt.Sprintf("10.0.0.%d", i), "success"
+		for _, u := range unhealthy {
+			if u == ip {
+				status = "failure"
+			}
+		}
+		statuses = append(statuses, v2.LoadBalancerServerStatus{PublicIp: &ip, Status: &status})
+	}
+
+	nlb := v2.LoadBalancer{
+		Id:        &testNLBID,
+		Name:      &testNLBName,
+		CreatedAt: &testNLBCreatedAt,
+		Services: &[]v2.LoadBalancerService{{
+			Id:                &testNLBServiceID,
+			Name:              &testNLBServiceName,
+			InstancePool:      &v2.Resource{Id: &poolID},
+			Healthcheck:       &v2.Healthcheck{},
+			HealthcheckStatus: &statuses,
+		}},
+	}
+
+	mockClient := v2.NewMockClient()
+	mockClient.RegisterResponder("GET", "/load-balancer",
+		func(req *http.Request) (*http.Response, error) {
+			// The services are only returned when retrieving a Network Load Balancer
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				LoadBalancers *[]v2.LoadBalancer `json:"load-balancers,omitempty"`
+			}{
+				LoadBalancers: &[]v2.LoadBalancer{{Id: nlb.Id, Name: nlb.Name, CreatedAt: nlb.CreatedAt}},
+			})
+			if err != nil {
+				t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/load-balancer/"+testNLBID,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, nlb)
+			if err != nil {
+				t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestClient_RollInstancePool(t *testing.T) {
+	var (
+		scaled  = response{200, jsonContentType, `{"scaleinstancepoolresponse": {"success": true}}`}
+		evicted = response{200, jsonContentType, `{"evictinstancepoolmembersresponse": {"success": true}}`}
+	)
+
+	ts := newServer(
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2, 3)...),
+		testRollZones,
+		// Batch 1
+		scaled,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2, 3, 4, 5)...),
+		testRollVirtualMachineResponse(4),
+		testRollVirtualMachineResponse(5),
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(3, 4, 5)...),
+		// Batch 2
+		scaled,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(3, 4, 5, 6)...),
+		testRollVirtualMachineResponse(6),
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(4, 5, 6)...),
+	)
+	defer ts.Close()
+
+	client := testRollClient(t, ts)
+
+	var progress []RollInstancePoolProgress
+	err := client.RollInstancePool(context.Background(), testRollPoolID, RollInstancePoolOptions{
+		ZoneID:   testRollZoneID,
+		MaxSurge: 2,
+		Wait:     WaitOptions{Interval: time.Millisecond},
+		OnProgress: func(p RollInstancePoolProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []RollInstancePoolProgress{
+		{
+			Batch:     1,
+			Added:     []UUID{*MustParseUUID("00000000-0000-0000-0000-000000000004"), *MustParseUUID("00000000-0000-0000-0000-000000000005")},
+			Evicted:   []UUID{*MustParseUUID("00000000-0000-0000-0000-000000000001"), *MustParseUUID("00000000-0000-0000-0000-000000000002")},
+			Remaining: 1,
+		},
+		{
+			Batch:     2,
+			Added:     []UUID{*MustParseUUID("00000000-0000-0000-0000-000000000006")},
+			Evicted:   []UUID{*MustParseUUID("00000000-0000-0000-0000-000000000003")},
+			Remaining: 0,
+		},
+	}, progress)
+	require.Equal(t, 13, ts.lastResponse)
+}
+
+func TestClient_RollInstancePool_Rollback(t *testing.T) {
+	var (
+		scaled  = response{200, jsonContentType, `{"scaleinstancepoolresponse": {"success": true}}`}
+		evicted = response{200, jsonContentType, `{"evictinstancepoolmembersresponse": {"success": true}}`}
+		wait    = WaitOptions{Interval: time.Millisecond, Timeout: 50 * time.Millisecond}
+	)
+
+	// The first member created never becomes healthy
+	ts := newServer(
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2)...),
+		testRollZones,
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(2)...),
+		scaled,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(2, 3)...),
+		testRollVirtualMachineResponse(3),
+		// Rollback
+		response{200, jsonContentType, `{"updateinstancepoolresponse": {"success": true}}`},
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(2)...),
+		scaled,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(2, 4)...),
+	)
+	defer ts.Close()
+
+	err := testRollClient(t, ts, "10.0.0.3").RollInstancePool(context.Background(), testRollPoolID, RollInstancePoolOptions{
+		ZoneID:         testRollZoneID,
+		MaxUnavailable: 1,
+		Rollback:       &UpdateInstancePool{TemplateID: MustParseUUID("4c0732a0-3df0-4f66-8d16-009f91cf05d6")},
+		Wait:           wait,
+	})
+	require.IsType(t, &RollInstancePoolError{}, err)
+	require.Contains(t, err.Error(), "Instance Pool roll failed at batch 1: Network Load Balancer test-nlb-name: ")
+	require.Nil(t, err.(*RollInstancePoolError).RollbackErr)
+	require.Equal(t, 12, ts.lastResponse)
+
+	// Without rollback, only the members created by the failed batch are evicted. Without wait
+	// timeout, the health wait is bounded by the client timeout.
+	ts = newServer(
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2)...),
+		testRollZones,
+		scaled,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2, 3)...),
+		testRollVirtualMachineResponse(3),
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2)...),
+	)
+	defer ts.Close()
+
+	client := testRollClient(t, ts, "10.0.0.3")
+	client.Timeout = 50 * time.Millisecond
+
+	err = client.RollInstancePool(context.Background(), testRollPoolID, RollInstancePoolOptions{
+		ZoneID: testRollZoneID,
+		Wait:   WaitOptions{Interval: time.Millisecond},
+	})
+	require.IsType(t, &RollInstancePoolError{}, err)
+	require.Nil(t, err.(*RollInstancePoolError).RollbackErr)
+	require.Equal(t, 7, ts.lastResponse)
+
+	// The members created are evicted even if the pool never reaches the expected size
+	ts = newServer(
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2)...),
+		testRollZones,
+		scaled,
+		testGetInstancePoolResponse(InstancePoolScalingUp, testRollMembers(1, 2, 3)...),
+		evicted,
+		testGetInstancePoolResponse(InstancePoolRunning, testRollMembers(1, 2)...),
+	)
+	defer ts.Close()
+
+	err = testRollClient(t, ts).RollInstancePool(context.Background(), testRollPoolID, RollInstancePoolOptions{
+		ZoneID: testRollZoneID,
+		Wait:   WaitOptions{Interval: time.Hour, Timeout: 50 * time.Millisecond},
+	})
+	require.IsType(t, &RollInstancePoolError{}, err)
+	require.IsType(t, &WaitTimeoutError{}, err.(*RollInstancePoolError).Err)
+	require.Nil(t, err.(*RollInstancePoolError).RollbackErr)
+	require.Equal(t, 6, ts.lastResponse)
+}
@@ -0,0 +1,297 @@
+package egoscale
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	apiv2 "github.com/exoscale/egoscale/api/v2"
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// instanceSnapshotDownloadMaxRetries represents the maximum number of consecutive attempts
+// to resume an interrupted snapshot export download without receiving any new data.
+const instanceSnapshotDownloadMaxRetries = 3
+
+// InstanceSnapshotExport represents an exported Compute instance snapshot.
+type InstanceSnapshotExport struct {
+	ID           string
+	PresignedURL string
+	MD5sum       string
+
+	c *Client
+}
+
+func instanceSnapshotExportFromAPI(e *v2.SnapshotExport) *InstanceSnapshotExport {
+	return &InstanceSnapshotExport{
+		ID:           optionalString(e.Id),
+		PresignedURL: optionalString(e.PresignedUrl),
+		MD5sum:       optionalString(e.Md5sum),
+	}
+}
+
+// Download streams the exported snapshot image to w, verifying its MD5 checksum on the fly.
+// If the transfer is interrupted, the download is resumed from the last byte received using
+// HTTP range requests.
+func (e *InstanceSnapshotExport) Download(ctx context.Context, w io.Writer) error {
+	return e.download(ctx, w, md5.New(), 0)
+}
+
+// ResumeDownload resumes a previously interrupted download of the exported snapshot image:
+// the data already retrieved is read from partial in order to compute the checksum, then
+// the rest of the image is streamed to w.
+func (e *InstanceSnapshotExport) ResumeDownload(ctx context.Context, partial io.Reader, w io.Writer) error {
+	h := md5.New()
+
+	offset, err := io.Copy(h, partial)
+	if err != nil {
+		return fmt.Errorf("unable to read partial download: %s", err)
+	}
+
+	return e.download(ctx, w, h, offset)
+}
+
+func (e *InstanceSnapshotExport) download(ctx context.Context, w io.Writer, h hash.Hash, offset int64) error {
+	var (
+		dst     = io.MultiWriter(w, h)
+		retries = 0
+	)
+
+	for {
+		n, retry, err := e.fetch(ctx, dst, offset)
+		offset += n
+		if err == nil {
+			break
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+
+		if n > 0 {
+			retries = 0
+		}
+		if retries++; retries > instanceSnapshotDownloadMaxRetries {
+			return fmt.Errorf("download interrupted after %d bytes: %s", offset, err)
+		}
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, e.MD5sum) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", e.MD5sum, sum)
+	}
+
+	return nil
+}
+
+// fetch copies the content of the exported snapshot image starting at offset to w. It returns
+// the number of bytes copied, and whether the error returned (if any) is transient, in which
+// case the transfer can be resumed.
+func (e *InstanceSnapshotExport) fetch(ctx context.Context, w io.Writer, offset int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.PresignedURL, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := e.c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// The server is resuming the transfer at the requested offset.
+
+	case http.StatusOK:
+		// The server doesn't support range requests: skip the data we already have.
+		if offset > 0 {
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+				return 0, true, err
+			}
+		}
+
+	default:
+		// The pre-signed URL isn't an API endpoint: server errors are transient, whereas the
+		// other errors (e.g. an expired URL) are not.
+		return 0, resp.StatusCode >= 500, fmt.Errorf("unable to download snapshot export: %s", resp.Status)
+	}
+
+	body := &readErrorTracker{r: resp.Body}
+	n, err := io.Copy(w, body)
+
+	return n, err != nil && err == body.err, err
+}
+
+// readErrorTracker is an io.Reader keeping track of the last error returned by the underlying
+// reader, allowing to tell read errors apart from write errors in io.Copy().
+type readErrorTracker struct {
+	r   io.Reader
+	err error
+}
+
+func (t *readErrorTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+
+	return n, err
+}
+
+// InstanceSnapshot represents a Compute instance snapshot.
+type InstanceSnapshot struct {
+	ID          string
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	InstanceID  string
+	State       string
+
+	c    *Client
+	zone string
+}
+
+func instanceSnapshotFromAPI(s *v2.Snapshot) *InstanceSnapshot {
+	return &InstanceSnapshot{
+		ID:          optionalString(s.Id),
+		Name:        optionalString(s.Name),
+		Description: optionalString(s.Description),
+		CreatedAt: func() time.Time {
+			if s.CreatedAt != nil {
+				return *s.CreatedAt
+			}
+			return time.Time{}
+		}(),
+		InstanceID: func() string {
+			if s.Instance != nil {
+				return optionalString(s.Instance.Id)
+			}
+			return ""
+		}(),
+		State: optionalString(s.State),
+	}
+}
+
+// Export exports the snapshot, and returns the information required to download the
+// exported image once the export operation has completed.
+func (s *InstanceSnapshot) Export(ctx context.Context) (*InstanceSnapshotExport, error) {
+	resp, err := s.c.V2.ExportSnapshotWithResponse(apiv2.WithZone(ctx, s.zone), s.ID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	_, err = v2.NewPoller().
+		WithTimeout(s.c.Timeout).
+		Poll(ctx, s.c.V2.OperationPoller(s.zone, *resp.JSON200.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	expResp, err := s.c.V2.GetExportSnapshotWithResponse(apiv2.WithZone(ctx, s.zone), s.ID)
+	if err != nil {
+		return nil, err
+	}
+	if expResp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(expResp.StatusCode(), expResp.Status(), expResp.Body)
+	}
+
+	export := instanceSnapshotExportFromAPI(expResp.JSON200)
+	export.c = s.c
+
+	return export, nil
+}
+
+// CreateInstanceSnapshot creates a snapshot of the specified Compute instance in the specified zone.
+func (c *Client) CreateInstanceSnapshot(ctx context.Context, zone, instanceID string) (*InstanceSnapshot, error) {
+	resp, err := c.V2.CreateSnapshotWithResponse(apiv2.WithZone(ctx, zone), instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	res, err := v2.NewPoller().
+		WithTimeout(c.Timeout).
+		Poll(ctx, c.V2.OperationPoller(zone, *resp.JSON200.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetInstanceSnapshot(ctx, zone, *res.(*v2.Reference).Id)
+}
+
+// ListInstanceSnapshots returns the list of existing Compute instance snapshots in the specified zone.
+func (c *Client) ListInstanceSnapshots(ctx context.Context, zone string) ([]*InstanceSnapshot, error) {
+	var list = make([]*InstanceSnapshot, 0)
+
+	resp, err := c.V2.ListSnapshotsWithResponse(apiv2.WithZone(ctx, zone))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	if resp.JSON200.Snapshots != nil {
+		for i := range *resp.JSON200.Snapshots {
+			snapshot := instanceSnapshotFromAPI(&(*resp.JSON200.Snapshots)[i])
+			snapshot.c = c
+			snapshot.zone = zone
+
+			list = append(list, snapshot)
+		}
+	}
+
+	return list, nil
+}
+
+// GetInstanceSnapshot returns the Compute instance snapshot corresponding to the specified ID
+// in the specified zone.
+func (c *Client) GetInstanceSnapshot(ctx context.Context, zone, id string) (*InstanceSnapshot, error) {
+	resp, err := c.V2.GetSnapshotWithResponse(apiv2.WithZone(ctx, zone), id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	snapshot := instanceSnapshotFromAPI(resp.JSON200)
+	snapshot.c = c
+	snapshot.zone = zone
+
+	return snapshot, nil
+}
+
+// DeleteInstanceSnapshot deletes the specified Compute instance snapshot in the specified zone.
+func (c *Client) DeleteInstanceSnapshot(ctx context.Context, zone, id string) error {
+	resp, err := c.V2.DeleteSnapshotWithResponse(apiv2.WithZone(ctx, zone), id)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	_, err = v2.NewPoller().
+		WithTimeout(c.Timeout).
+		Poll(ctx, c.V2.OperationPoller(zone, *resp.JSON200.Id))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
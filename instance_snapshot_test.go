@@ -0,0 +1,281 @@
+package egoscale
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+var (
+	testInstanceSnapshotID           = "a3a8ad26-9bda-4c8a-a9a6-0e7d2cf9cfd6"
+	testInstanceSnapshotName         = "test-snapshot-name"
+	testInstanceSnapshotCreatedAt, _ = time.Parse(iso8601Format, "2020-08-12T11:12:36Z")
+	testInstanceSnapshotInstanceID   = "6a1bd6a9-a5d5-4d4e-8f70-4c6e2e3a29c0"
+	testInstanceSnapshotState        = "exported"
+	testInstanceSnapshotExportURL    = "https://sos-ch-gva-2.exo.io/test/snapshot.qcow2"
+	testInstanceSnapshotExportData   = bytes.Repeat([]byte("egoscale"), 4096)
+)
+
+func testInstanceSnapshotExportMD5sum() string {
+	sum := md5.Sum(testInstanceSnapshotExportData)
+	return hex.EncodeToString(sum[:])
+}
+
+// newTestInstanceSnapshotExportServer returns a test HTTP server serving the test snapshot export
+// data, interrupting the transfer of the first response after half of the content has been sent.
+func newTestInstanceSnapshotExportServer() *httptest.Server {
+	var served int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&served, 1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(testInstanceSnapshotExportData)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(testInstanceSnapshotExportData[:len(testInstanceSnapshotExportData)/2]) // nolint: errcheck
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(testInstanceSnapshotExportData))
+	}))
+}
+
+func TestInstanceSnapshot_Export(t *testing.T) {
+	var (
+		testOperationID    = "e4d3ebc4-5ba3-4a8c-a1a8-d2e2df1b7b46"
+		testOperationState = "success"
+		testMD5sum         = testInstanceSnapshotExportMD5sum()
+		err                error
+	)
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("POST", "/snapshot/"+testInstanceSnapshotID+":export",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &testOperationID,
+				State:     &testOperationState,
+				Reference: &v2.Reference{Id: &testInstanceSnapshotID},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/operation/"+testOperationID,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &testOperationID,
+				State:     &testOperationState,
+				Reference: &v2.Reference{Id: &testInstanceSnapshotID},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/snapshot/"+testInstanceSnapshotID+":export",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.SnapshotExport{
+				Id:           &testInstanceSnapshotID,
+				Md5sum:       &testMD5sum,
+				PresignedUrl: &testInstanceSnapshotExportURL,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	snapshot := &InstanceSnapshot{
+		ID:   testInstanceSnapshotID,
+		c:    client,
+		zone: testZone,
+	}
+
+	expected := &InstanceSnapshotExport{
+		ID:           testInstanceSnapshotID,
+		PresignedURL: testInstanceSnapshotExportURL,
+		MD5sum:       testMD5sum,
+
+		c: client,
+	}
+
+	actual, err := snapshot.Export(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	mockClient.RegisterResponder("GET", "/snapshot/"+testInstanceSnapshotID+":export",
+		httpmock.NewStringResponder(http.StatusNotFound, `{"message":"not found"}`))
+
+	_, err = snapshot.Export(context.Background())
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestInstanceSnapshotExport_Download(t *testing.T) {
+	ts := newTestInstanceSnapshotExportServer()
+	defer ts.Close()
+
+	export := &InstanceSnapshotExport{
+		ID:           testInstanceSnapshotID,
+		PresignedURL: ts.URL,
+		MD5sum:       testInstanceSnapshotExportMD5sum(),
+
+		c: NewClient("x", "x", "x"),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, export.Download(context.Background(), &buf))
+	require.Equal(t, testInstanceSnapshotExportData, buf.Bytes())
+
+	// A corrupted image must be reported as a checksum mismatch
+	export.MD5sum = "d41d8cd98f00b204e9800998ecf8427e"
+	buf.Reset()
+	require.Error(t, export.Download(context.Background(), &buf))
+
+	// An expired export is not retried
+	var requests int
+	expired := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer expired.Close()
+	export.PresignedURL = expired.URL
+	require.EqualError(t, export.Download(context.Background(), &buf), "unable to download snapshot export: 403 Forbidden")
+	require.Equal(t, 1, requests)
+
+	// Server errors are retried
+	requests = 0
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	export.PresignedURL = unavailable.URL
+	require.Error(t, export.Download(context.Background(), &buf))
+	require.Equal(t, instanceSnapshotDownloadMaxRetries+1, requests)
+}
+
+func TestInstanceSnapshotExport_ResumeDownload(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(testInstanceSnapshotExportData))
+	}))
+	defer ts.Close()
+
+	export := &InstanceSnapshotExport{
+		ID:           testInstanceSnapshotID,
+		PresignedURL: ts.URL,
+		MD5sum:       testInstanceSnapshotExportMD5sum(),
+
+		c: NewClient("x", "x", "x"),
+	}
+
+	partial := testInstanceSnapshotExportData[:1000]
+
+	var buf bytes.Buffer
+	require.NoError(t, export.ResumeDownload(context.Background(), bytes.NewReader(partial), &buf))
+	require.Equal(t, testInstanceSnapshotExportData[1000:], buf.Bytes())
+}
+
+// CreateInstanceSnapshot is not tested as it essentially relies on the already tested GetInstanceSnapshot.
+func TestClient_CreateInstanceSnapshot(t *testing.T) { t.Skip() }
+
+func TestClient_ListInstanceSnapshots(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("GET", "/snapshot",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				Snapshots *[]v2.Snapshot `json:"snapshots,omitempty"`
+			}{
+				Snapshots: &[]v2.Snapshot{{
+					Id:        &testInstanceSnapshotID,
+					Name:      &testInstanceSnapshotName,
+					CreatedAt: &testInstanceSnapshotCreatedAt,
+					Instance:  &v2.Instance{Id: &testInstanceSnapshotInstanceID},
+					State:     &testInstanceSnapshotState,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	expected := []*InstanceSnapshot{{
+		ID:         testInstanceSnapshotID,
+		Name:       testInstanceSnapshotName,
+		CreatedAt:  testInstanceSnapshotCreatedAt,
+		InstanceID: testInstanceSnapshotInstanceID,
+		State:      testInstanceSnapshotState,
+
+		c:    client,
+		zone: testZone,
+	}}
+
+	actual, err := client.ListInstanceSnapshots(context.Background(), testZone)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestClient_GetInstanceSnapshot(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("GET", "/snapshot/"+testInstanceSnapshotID,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Snapshot{
+				Id:        &testInstanceSnapshotID,
+				Name:      &testInstanceSnapshotName,
+				CreatedAt: &testInstanceSnapshotCreatedAt,
+				Instance:  &v2.Instance{Id: &testInstanceSnapshotInstanceID},
+				State:     &testInstanceSnapshotState,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	expected := &InstanceSnapshot{
+		ID:         testInstanceSnapshotID,
+		Name:       testInstanceSnapshotName,
+		CreatedAt:  testInstanceSnapshotCreatedAt,
+		InstanceID: testInstanceSnapshotInstanceID,
+		State:      testInstanceSnapshotState,
+
+		c:    client,
+		zone: testZone,
+	}
+
+	actual, err := client.GetInstanceSnapshot(context.Background(), testZone, expected.ID)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+// DeleteInstanceSnapshot is not tested as it only produces API-side effects.
+func TestClient_DeleteInstanceSnapshot(t *testing.T) { t.Skip() }
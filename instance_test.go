@@ -0,0 +1,163 @@
+package egoscale
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+func TestInstanceSpec_Validate(t *testing.T) {
+	valid := InstanceSpec{
+		Name:             "test-instance",
+		TemplateID:       "4fcb0d5c-0d55-4c5a-8e26-fea1f4e4c6b3",
+		InstanceType:     "standard.medium",
+		DiskSize:         10,
+		SecurityGroupIDs: []string{"e8a4e1a9-7c4a-4b5d-a2d2-93ff38b1b9d8"},
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		field  string
+		mutate func(*InstanceSpec)
+	}{
+		{"TemplateID", func(s *InstanceSpec) { s.TemplateID = "" }},
+		{"TemplateID", func(s *InstanceSpec) { s.TemplateID = "Linux Ubuntu 20.04" }},
+		{"InstanceType", func(s *InstanceSpec) { s.InstanceType = "" }},
+		{"DiskSize", func(s *InstanceSpec) { s.DiskSize = 0 }},
+		{"SecurityGroupIDs", func(s *InstanceSpec) { s.SecurityGroupIDs = []string{"default"} }},
+		{"AntiAffinityGroupIDs", func(s *InstanceSpec) { s.AntiAffinityGroupIDs = []string{"x"} }},
+	}
+
+	for _, test := range tests {
+		spec := valid
+		test.mutate(&spec)
+
+		err := spec.Validate()
+		require.IsType(t, &ValidationError{}, err)
+		require.Equal(t, test.field, err.(*ValidationError).Field)
+	}
+}
+
+func TestClient_CreateInstance(t *testing.T) {
+	var (
+		testInstanceID           = "2e9a2b0c-5fd4-4d2e-93c4-3b8f5c1ea3c4"
+		testInstanceName         = "test-instance"
+		testInstanceTemplateID   = "4fcb0d5c-0d55-4c5a-8e26-fea1f4e4c6b3"
+		testInstanceTypeID       = "b6e9d1e8-89fc-4db3-aaa4-9b4c5b1d0844"
+		testInstanceTypeFamily   = "standard"
+		testInstanceTypeSize     = "medium"
+		testInstanceUserData     = "#cloud-config\n"
+		testOperationID          = "0f4a0a4e-1b47-4c31-9a8b-2a0a1c8c3f11"
+		testOperationState       = "success"
+		actualRequest            createInstanceRequest
+		actualStart              string
+		err                      error
+		testInstanceTypesRequest int
+	)
+
+	ts := newServer(response{200, jsonContentType, `{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{
+		"id": "` + testInstanceID + `",
+		"name": "` + testInstanceName + `",
+		"state": "Running",
+		"templateid": "` + testInstanceTemplateID + `"
+	}]
+}}`})
+	defer ts.Close()
+
+	mockClient := v2.NewMockClient()
+	client := NewClient(ts.URL, "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	// An invalid specification must be rejected before any API call
+	_, err = client.CreateInstance(context.Background(), testZone, &InstanceSpec{Name: testInstanceName})
+	require.IsType(t, &ValidationError{}, err)
+	require.Equal(t, 0, mockClient.GetTotalCallCount())
+
+	mockClient.RegisterResponder("GET", "/instance-type",
+		func(req *http.Request) (*http.Response, error) {
+			testInstanceTypesRequest++
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				InstanceTypes *[]v2.InstanceType `json:"instance-types,omitempty"`
+			}{
+				InstanceTypes: &[]v2.InstanceType{{
+					Id:     &testInstanceTypeID,
+					Family: &testInstanceTypeFamily,
+					Size:   &testInstanceTypeSize,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("POST", "/instance",
+		func(req *http.Request) (*http.Response, error) {
+			actualStart = req.URL.Query().Get("start")
+
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("error reading request body: %s", err)
+			}
+			if err := json.Unmarshal(body, &actualRequest); err != nil {
+				t.Fatalf("error unmarshaling request body: %s", err)
+			}
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &testOperationID,
+				State:     &testOperationState,
+				Reference: &v2.Reference{Id: &testInstanceID},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/operation/"+testOperationID,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &testOperationID,
+				State:     &testOperationState,
+				Reference: &v2.Reference{Id: &testInstanceID},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	actual, err := client.CreateInstance(context.Background(), testZone, &InstanceSpec{
+		Name:         testInstanceName,
+		TemplateID:   testInstanceTemplateID,
+		InstanceType: "medium",
+		DiskSize:     10,
+		SSHKey:       "test-key",
+		UserData:     testInstanceUserData,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, testInstanceTypesRequest)
+	require.Equal(t, "true", actualStart)
+	require.Equal(t, createInstanceRequest{
+		Name:         testInstanceName,
+		Template:     apiv2ResourceRef{ID: testInstanceTemplateID},
+		InstanceType: apiv2ResourceRef{ID: testInstanceTypeID},
+		DiskSize:     10,
+		SSHKey:       &apiv2ResourceRef{Name: "test-key"},
+		UserData:     base64.StdEncoding.EncodeToString([]byte(testInstanceUserData)),
+	}, actualRequest)
+	require.Equal(t, testInstanceID, actual.ID.String())
+	require.Equal(t, testInstanceName, actual.Name)
+	require.Equal(t, string(VirtualMachineRunning), actual.State)
+}
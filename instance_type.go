@@ -0,0 +1,297 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	apiv2 "github.com/exoscale/egoscale/api/v2"
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// InstanceTypeFamilyStandard represents the default Compute instance type family.
+const InstanceTypeFamilyStandard = "standard"
+
+// standardInstanceTypeSizes lists the sizes of the "standard" instance type family, which
+// corresponding legacy service offerings are named after the size only.
+var standardInstanceTypeSizes = map[string]struct{}{
+	"micro":       {},
+	"tiny":        {},
+	"small":       {},
+	"medium":      {},
+	"large":       {},
+	"extra-large": {},
+	"huge":        {},
+	"mega":        {},
+	"titan":       {},
+	"jumbo":       {},
+	"colossus":    {},
+}
+
+// InstanceType represents a Compute instance type.
+type InstanceType struct {
+	// ID is the API V2 instance type ID, empty if the type is only available as a service offering
+	ID string
+	// ServiceOfferingID is the ID of the corresponding API V1 service offering, if any
+	ServiceOfferingID string
+	Family            string
+	Size              string
+	CPUs              int64
+	// GPUs is the number of GPUs, always 0 for instance types only available as a service
+	// offering as the API V1 doesn't report it: such types never match a MinGPUs constraint
+	GPUs int64
+	// Memory is the amount of memory in bytes
+	Memory     int64
+	Authorized bool
+}
+
+func instanceTypeFromAPI(t *v2.InstanceType) *InstanceType {
+	return &InstanceType{
+		ID:     optionalString(t.Id),
+		Family: optionalString(t.Family),
+		Size:   optionalString(t.Size),
+		CPUs:   optionalInt64(t.Cpus),
+		GPUs:   optionalInt64(t.Gpus),
+		Memory: optionalInt64(t.Memory),
+		Authorized: func() bool {
+			if t.Authorized != nil {
+				return *t.Authorized
+			}
+			return false
+		}(),
+	}
+}
+
+// instanceTypeFromServiceOffering returns the instance type corresponding to a service offering
+// lacking an API V2 counterpart. The number of GPUs is left unset, as service offerings don't
+// report it.
+func instanceTypeFromServiceOffering(so *ServiceOffering) *InstanceType {
+	family, size := parseServiceOfferingName(so.Name)
+
+	return &InstanceType{
+		ServiceOfferingID: so.ID.String(),
+		Family:            family,
+		Size:              size,
+		CPUs:              int64(so.CPUNumber),
+		Memory:            int64(so.Memory) << 20,
+		Authorized:        so.Authorized,
+	}
+}
+
+// key returns the name of the service offering corresponding to the instance type, in lower case.
+func (t *InstanceType) key() string {
+	if t.Family == InstanceTypeFamilyStandard {
+		return strings.ToLower(t.Size)
+	}
+
+	return strings.ToLower(t.Family + "-" + t.Size)
+}
+
+// parseServiceOfferingName returns the instance type family and size from a service offering
+// name (e.g. "Medium" or "GPU-small").
+func parseServiceOfferingName(name string) (string, string) {
+	name = strings.ToLower(name)
+
+	if _, ok := standardInstanceTypeSizes[name]; ok {
+		return InstanceTypeFamilyStandard, name
+	}
+
+	if parts := strings.SplitN(name, "-", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return "", name
+}
+
+// ListInstanceTypes returns the list of Compute instance types available in the specified zone.
+func (c *Client) ListInstanceTypes(ctx context.Context, zone string) ([]*InstanceType, error) {
+	var list = make([]*InstanceType, 0)
+
+	resp, err := c.V2.ListInstanceTypesWithResponse(apiv2.WithZone(ctx, zone))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	if resp.JSON200.InstanceTypes != nil {
+		for i := range *resp.JSON200.InstanceTypes {
+			list = append(list, instanceTypeFromAPI(&(*resp.JSON200.InstanceTypes)[i]))
+		}
+	}
+
+	return list, nil
+}
+
+// GetInstanceType returns the Compute instance type corresponding to the specified ID in the
+// specified zone.
+func (c *Client) GetInstanceType(ctx context.Context, zone, id string) (*InstanceType, error) {
+	resp, err := c.V2.GetInstanceTypeWithResponse(apiv2.WithZone(ctx, zone), id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	return instanceTypeFromAPI(resp.JSON200), nil
+}
+
+// InstanceTypeConstraints represents the constraints an instance type must satisfy in a
+// InstanceTypeCatalogue lookup. Zero-valued fields are ignored.
+type InstanceTypeConstraints struct {
+	Family    string
+	MinCPUs   int64
+	MaxCPUs   int64
+	MinGPUs   int64
+	MaxGPUs   int64
+	MinMemory int64
+	MaxMemory int64
+	// Authorized restricts the lookup to instance types the account is authorized to use
+	Authorized bool
+	// RequireServiceOffering restricts the lookup to instance types having a service offering
+	RequireServiceOffering bool
+}
+
+// Match returns true if the instance type satisfies the constraints.
+func (cs InstanceTypeConstraints) Match(t *InstanceType) bool {
+	switch {
+	case cs.Family != "" && !strings.EqualFold(cs.Family, t.Family),
+		t.CPUs < cs.MinCPUs,
+		cs.MaxCPUs > 0 && t.CPUs > cs.MaxCPUs,
+		t.GPUs < cs.MinGPUs,
+		cs.MaxGPUs > 0 && t.GPUs > cs.MaxGPUs,
+		t.Memory < cs.MinMemory,
+		cs.MaxMemory > 0 && t.Memory > cs.MaxMemory,
+		cs.Authorized && !t.Authorized,
+		cs.RequireServiceOffering && t.ServiceOfferingID == "":
+		return false
+	}
+
+	return true
+}
+
+// InstanceTypeCatalogue represents the catalogue of Compute instance types available in a zone,
+// reconciling API V2 instance types with their API V1 service offering counterpart.
+type InstanceTypeCatalogue struct {
+	types []*InstanceType
+}
+
+// NewInstanceTypeCatalogue returns a catalogue of the specified instance types, merging the
+// instance types returned by ListInstanceTypes with the ones built from service offerings.
+// Instance types are matched with service offerings by family and size, or by number of CPUs
+// and amount of memory if the offering name is not conclusive.
+func NewInstanceTypeCatalogue(instanceTypes []*InstanceType, serviceOfferings []ServiceOffering) *InstanceTypeCatalogue {
+	var (
+		types   = make([]*InstanceType, 0, len(instanceTypes))
+		byKey   = make(map[string]*InstanceType)
+		bySpecs = make(map[string][]*InstanceType)
+	)
+
+	specs := func(t *InstanceType) string { return fmt.Sprintf("%d/%d", t.CPUs, t.Memory) }
+
+	for _, t := range instanceTypes {
+		t := *t
+		types = append(types, &t)
+		byKey[t.key()] = &t
+		bySpecs[specs(&t)] = append(bySpecs[specs(&t)], &t)
+	}
+
+	for i := range serviceOfferings {
+		so := &serviceOfferings[i]
+		if so.IsSystem || so.ID == nil {
+			continue
+		}
+
+		st := instanceTypeFromServiceOffering(so)
+
+		match, ok := byKey[st.key()]
+		if !ok {
+			if candidates := bySpecs[specs(st)]; len(candidates) == 1 {
+				match = candidates[0]
+			}
+		}
+
+		if match != nil && match.ServiceOfferingID == "" {
+			match.ServiceOfferingID = st.ServiceOfferingID
+			continue
+		}
+
+		types = append(types, st)
+	}
+
+	sortInstanceTypes(types)
+
+	return &InstanceTypeCatalogue{types: types}
+}
+
+// LoadInstanceTypeCatalogue returns the catalogue of Compute instance types available in the
+// specified zone.
+func (c *Client) LoadInstanceTypeCatalogue(ctx context.Context, zone string) (*InstanceTypeCatalogue, error) {
+	instanceTypes, err := c.ListInstanceTypes(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.ListWithContext(ctx, &ServiceOffering{})
+	if err != nil {
+		return nil, err
+	}
+
+	serviceOfferings := make([]ServiceOffering, 0, len(res))
+	for _, so := range res {
+		serviceOfferings = append(serviceOfferings, *so.(*ServiceOffering))
+	}
+
+	return NewInstanceTypeCatalogue(instanceTypes, serviceOfferings), nil
+}
+
+// InstanceTypes returns all the instance types of the catalogue, from the smallest to the largest.
+func (cat *InstanceTypeCatalogue) InstanceTypes() []*InstanceType {
+	return append([]*InstanceType(nil), cat.types...)
+}
+
+// Select returns the instance types of the catalogue satisfying the specified constraints, from
+// the smallest to the largest.
+func (cat *InstanceTypeCatalogue) Select(cs InstanceTypeConstraints) []*InstanceType {
+	var list = make([]*InstanceType, 0)
+
+	for _, t := range cat.types {
+		if cs.Match(t) {
+			list = append(list, t)
+		}
+	}
+
+	return list
+}
+
+// Smallest returns the smallest instance type of the catalogue satisfying the specified
+// constraints, or ErrNotFound if none does.
+func (cat *InstanceTypeCatalogue) Smallest(cs InstanceTypeConstraints) (*InstanceType, error) {
+	for _, t := range cat.types {
+		if cs.Match(t) {
+			return t, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// sortInstanceTypes sorts instance types by amount of memory, then number of CPUs, then number of GPUs.
+func sortInstanceTypes(types []*InstanceType) {
+	sort.SliceStable(types, func(i, j int) bool {
+		a, b := types[i], types[j]
+
+		switch {
+		case a.Memory != b.Memory:
+			return a.Memory < b.Memory
+		case a.CPUs != b.CPUs:
+			return a.CPUs < b.CPUs
+		default:
+			return a.GPUs < b.GPUs
+		}
+	})
+}
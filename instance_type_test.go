@@ -0,0 +1,170 @@
+package egoscale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+func newTestInstanceTypes() []*InstanceType {
+	return []*InstanceType{
+		{ID: "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8", Family: "standard", Size: "large", CPUs: 4, Memory: 8 << 30, Authorized: true},
+		{ID: "b6e9d1e8-89fc-4db3-aaa4-9b4c5b1d0844", Family: "standard", Size: "medium", CPUs: 2, Memory: 4 << 30, Authorized: true},
+		{ID: "350dc5ea-0e3a-4f1d-9f17-1ea1e4dbc1b8", Family: "standard", Size: "extra-large", CPUs: 4, Memory: 16 << 30, Authorized: true},
+		{ID: "5c3a1d1c-7d63-4b1b-9b8b-a3c6c8b1d1e1", Family: "memory", Size: "large", CPUs: 4, Memory: 16 << 30, Authorized: true},
+		{ID: "d2e7dc5e-4d1b-4bfa-9ac8-5e2f3d0c7c6e", Family: "gpu", Size: "small", CPUs: 12, GPUs: 1, Memory: 56 << 30},
+	}
+}
+
+func newTestServiceOfferings() []ServiceOffering {
+	return []ServiceOffering{
+		{ID: MustParseUUID("5e5fb3c6-e076-429d-9b6c-b71f7b27760b"), Name: "Medium", CPUNumber: 2, Memory: 4096, Authorized: true},
+		{ID: MustParseUUID("21624abb-764e-4def-81d7-9fc54b5957fb"), Name: "Large", CPUNumber: 4, Memory: 8192, Authorized: true},
+		{ID: MustParseUUID("b6e9d1e8-89fc-4db3-aaa4-9b4c5b1d0844"), Name: "Extra-large", CPUNumber: 4, Memory: 16384, Authorized: true},
+		{ID: MustParseUUID("ba3c8a87-e2b8-4bb0-a6c6-9d8b1f0c5e94"), Name: "GPU-small", CPUNumber: 12, Memory: 57344},
+		{ID: MustParseUUID("3e1f6d9f-1c1a-4a1e-8d6f-8f4f3e1d4c2b"), Name: "Micro", CPUNumber: 1, Memory: 512, Authorized: true},
+		{ID: MustParseUUID("a1a2a3a4-b1b2-c1c2-d1d2-e1e2e3e4e5e6"), Name: "System", IsSystem: true},
+	}
+}
+
+func TestNewInstanceTypeCatalogue(t *testing.T) {
+	cat := NewInstanceTypeCatalogue(newTestInstanceTypes(), newTestServiceOfferings())
+
+	expected := []*InstanceType{
+		{ServiceOfferingID: "3e1f6d9f-1c1a-4a1e-8d6f-8f4f3e1d4c2b", Family: "standard", Size: "micro", CPUs: 1, Memory: 512 << 20, Authorized: true},
+		{ID: "b6e9d1e8-89fc-4db3-aaa4-9b4c5b1d0844", ServiceOfferingID: "5e5fb3c6-e076-429d-9b6c-b71f7b27760b", Family: "standard", Size: "medium", CPUs: 2, Memory: 4 << 30, Authorized: true},
+		{ID: "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8", ServiceOfferingID: "21624abb-764e-4def-81d7-9fc54b5957fb", Family: "standard", Size: "large", CPUs: 4, Memory: 8 << 30, Authorized: true},
+		{ID: "350dc5ea-0e3a-4f1d-9f17-1ea1e4dbc1b8", ServiceOfferingID: "b6e9d1e8-89fc-4db3-aaa4-9b4c5b1d0844", Family: "standard", Size: "extra-large", CPUs: 4, Memory: 16 << 30, Authorized: true},
+		{ID: "5c3a1d1c-7d63-4b1b-9b8b-a3c6c8b1d1e1", Family: "memory", Size: "large", CPUs: 4, Memory: 16 << 30, Authorized: true},
+		{ID: "d2e7dc5e-4d1b-4bfa-9ac8-5e2f3d0c7c6e", ServiceOfferingID: "ba3c8a87-e2b8-4bb0-a6c6-9d8b1f0c5e94", Family: "gpu", Size: "small", CPUs: 12, GPUs: 1, Memory: 56 << 30},
+	}
+
+	require.Equal(t, expected, cat.InstanceTypes())
+}
+
+func TestInstanceTypeCatalogue_Select(t *testing.T) {
+	cat := NewInstanceTypeCatalogue(newTestInstanceTypes(), newTestServiceOfferings())
+
+	actual := cat.Select(InstanceTypeConstraints{MinCPUs: 4, MinMemory: 16 << 30})
+	require.Len(t, actual, 3)
+	require.Equal(t, "extra-large", actual[0].Size)
+	require.Equal(t, "memory", actual[1].Family)
+	require.Equal(t, "gpu", actual[2].Family)
+
+	require.Empty(t, cat.Select(InstanceTypeConstraints{Family: "storage"}))
+}
+
+func TestInstanceTypeCatalogue_Smallest(t *testing.T) {
+	cat := NewInstanceTypeCatalogue(newTestInstanceTypes(), newTestServiceOfferings())
+
+	actual, err := cat.Smallest(InstanceTypeConstraints{Family: "standard", MinCPUs: 4})
+	require.NoError(t, err)
+	require.Equal(t, "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8", actual.ID)
+	require.Equal(t, "21624abb-764e-4def-81d7-9fc54b5957fb", actual.ServiceOfferingID)
+
+	actual, err = cat.Smallest(InstanceTypeConstraints{MinGPUs: 1, Authorized: true})
+	require.Equal(t, ErrNotFound, err)
+	require.Nil(t, actual)
+}
+
+func TestClient_ListInstanceTypes(t *testing.T) {
+	var (
+		testInstanceTypeID               = "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8"
+		testInstanceTypeFamily           = "standard"
+		testInstanceTypeSize             = "large"
+		testInstanceTypeCPUs       int64 = 4
+		testInstanceTypeMemory     int64 = 8 << 30
+		testInstanceTypeAuthorized       = true
+		err                        error
+	)
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("GET", "/instance-type",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				InstanceTypes *[]v2.InstanceType `json:"instance-types,omitempty"`
+			}{
+				InstanceTypes: &[]v2.InstanceType{{
+					Id:         &testInstanceTypeID,
+					Family:     &testInstanceTypeFamily,
+					Size:       &testInstanceTypeSize,
+					Cpus:       &testInstanceTypeCPUs,
+					Memory:     &testInstanceTypeMemory,
+					Authorized: &testInstanceTypeAuthorized,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	expected := []*InstanceType{{
+		ID:         testInstanceTypeID,
+		Family:     testInstanceTypeFamily,
+		Size:       testInstanceTypeSize,
+		CPUs:       testInstanceTypeCPUs,
+		Memory:     testInstanceTypeMemory,
+		Authorized: testInstanceTypeAuthorized,
+	}}
+
+	actual, err := client.ListInstanceTypes(context.Background(), testZone)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestClient_LoadInstanceTypeCatalogue(t *testing.T) {
+	var err error
+
+	ts := newServer(response{200, jsonContentType, `{"listserviceofferingsresponse": {
+	"count": 1,
+	"serviceoffering": [{
+		"id": "21624abb-764e-4def-81d7-9fc54b5957fb",
+		"name": "Large",
+		"cpunumber": 4,
+		"memory": 8192,
+		"authorized": true
+	}]
+}}`})
+	defer ts.Close()
+
+	mockClient := v2.NewMockClient()
+	client := NewClient(ts.URL, "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("GET", "/instance-type",
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(http.StatusOK, `{"instance-types": [{
+	"id": "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8",
+	"family": "standard",
+	"size": "large",
+	"cpus": 4,
+	"memory": 8589934592,
+	"authorized": true
+}]}`)
+			resp.Header.Set("Content-Type", jsonContentType)
+			return resp, nil
+		})
+
+	cat, err := client.LoadInstanceTypeCatalogue(context.Background(), testZone)
+	require.NoError(t, err)
+	require.Equal(t, []*InstanceType{{
+		ID:                "b6cd1ff5-3a2f-4e9d-a4d1-8988c1191fe8",
+		ServiceOfferingID: "21624abb-764e-4def-81d7-9fc54b5957fb",
+		Family:            "standard",
+		Size:              "large",
+		CPUs:              4,
+		Memory:            8 << 30,
+		Authorized:        true,
+	}}, cat.InstanceTypes())
+}
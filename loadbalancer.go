@@ -135,17 +135,10 @@ func (nlb *NetworkLoadBalancer) AddService(ctx context.Context,
 		healthcheckTimeout  = int64(svc.Healthcheck.Timeout.Seconds())
 	)
 
-	// The API doesn't return the NLB service created directly, so in order to return a
-	// *NetworkLoadBalancerService corresponding to the new service we have to manually
-	// compare the list of services on the NLB instance before and after the service
-	// creation, and identify the service that wasn't there before.
-	// Note: in case of multiple services creation in parallel this technique is subject
-	// to race condition as we could return an unrelated service. To prevent this, we
-	// also compare the name of the new service to the name specified in the svc
-	// parameter.
+	// Services created before this call, to be ignored when identifying the new service.
 	services := make(map[string]struct{})
-	for _, svc := range nlb.Services {
-		services[svc.ID] = struct{}{}
+	for _, s := range nlb.Services {
+		services[s.ID] = struct{}{}
 	}
 
 	resp, err := nlb.c.V2.AddServiceToLoadBalancerWithResponse(
@@ -193,19 +186,68 @@ func (nlb *NetworkLoadBalancer) AddService(ctx context.Context,
 		return nil, err
 	}
 
-	nlbUpdated, err := nlb.c.GetNetworkLoadBalancer(ctx, nlb.zone, *res.(*v2.Reference).Id)
+	nlbUpdated, err := nlb.c.GetNetworkLoadBalancer(ctx, nlb.zone, nlb.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Look for an unknown service: if we find one we hope it's the one we've just created.
-	for _, s := range nlbUpdated.Services {
-		if _, ok := services[svc.ID]; !ok && s.Name == svc.Name {
-			return s, nil
+	return nlbUpdated.identifyService(res.(*v2.Reference), svc, services)
+}
+
+// identifyService returns the service created by an AddService operation. If the operation
+// reference points to the service it is used directly, otherwise the service is identified by
+// its port, which is unique among the services of a Network Load Balancer instance. Services
+// listed in known are ignored. An error is returned if the service cannot be identified
+// unambiguously.
+func (nlb *NetworkLoadBalancer) identifyService(ref *v2.Reference, svc *NetworkLoadBalancerService,
+	known map[string]struct{}) (*NetworkLoadBalancerService, error) {
+	if id := nlbServiceIDFromReference(ref); id != "" {
+		for _, s := range nlb.Services {
+			if s.ID == id {
+				return s, nil
+			}
+		}
+
+		return nil, fmt.Errorf("service %s not found in Network Load Balancer %s", id, nlb.ID)
+	}
+
+	candidates := make([]*NetworkLoadBalancerService, 0)
+	for _, s := range nlb.Services {
+		if _, ok := known[s.ID]; ok {
+			continue
+		}
+
+		if s.Port == svc.Port && s.Name == svc.Name {
+			candidates = append(candidates, s)
 		}
 	}
 
-	return nil, errors.New("unable to identify the service created")
+	switch len(candidates) {
+	case 0:
+		return nil, errors.New("unable to identify the service created")
+
+	case 1:
+		return candidates[0], nil
+
+	default:
+		return nil, fmt.Errorf("unable to identify the service created: %d services match port %d and name %q",
+			len(candidates), svc.Port, svc.Name)
+	}
+}
+
+// nlbServiceIDFromReference returns the ID of the Network Load Balancer service an operation
+// reference points to, or an empty string if the reference doesn't point to a service.
+func nlbServiceIDFromReference(ref *v2.Reference) string {
+	if ref == nil || ref.Link == nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimSuffix(*ref.Link, "/"), "/")
+	if len(parts) >= 2 && parts[len(parts)-2] == "service" {
+		return parts[len(parts)-1]
+	}
+
+	return ""
 }
 
 // UpdateService updates the specified Network Load Balancer service.
@@ -0,0 +1,376 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// NetworkLoadBalancerPlanAction represents an action of a Network Load Balancer reconciliation plan.
+type NetworkLoadBalancerPlanAction string
+
+const (
+	// NetworkLoadBalancerCreate represents the creation of the Network Load Balancer instance.
+	NetworkLoadBalancerCreate NetworkLoadBalancerPlanAction = "create"
+	// NetworkLoadBalancerUpdate represents the update of the Network Load Balancer instance properties.
+	NetworkLoadBalancerUpdate NetworkLoadBalancerPlanAction = "update"
+	// NetworkLoadBalancerAddService represents the addition of a service.
+	NetworkLoadBalancerAddService NetworkLoadBalancerPlanAction = "add-service"
+	// NetworkLoadBalancerUpdateService represents the update of an existing service.
+	NetworkLoadBalancerUpdateService NetworkLoadBalancerPlanAction = "update-service"
+	// NetworkLoadBalancerDeleteService represents the deletion of an existing service.
+	NetworkLoadBalancerDeleteService NetworkLoadBalancerPlanAction = "delete-service"
+)
+
+// NetworkLoadBalancerPlanStep represents a step of a Network Load Balancer reconciliation plan.
+type NetworkLoadBalancerPlanStep struct {
+	Action NetworkLoadBalancerPlanAction
+	// Service is the service affected by the step: the desired service for additions and updates,
+	// the existing service for deletions, nil for Network Load Balancer instance-level actions
+	Service *NetworkLoadBalancerService
+	// Changes lists the properties changed by an update step, in a human-readable form
+	Changes []string
+
+	// Applied is true if the step has been applied successfully
+	Applied bool
+	// Err is the error that occurred while applying the step, if any
+	Err error
+}
+
+// String returns a human-readable description of the step.
+func (s *NetworkLoadBalancerPlanStep) String() string {
+	switch s.Action {
+	case NetworkLoadBalancerCreate:
+		return "+ create Network Load Balancer"
+
+	case NetworkLoadBalancerUpdate:
+		return fmt.Sprintf("~ update Network Load Balancer: %s", strings.Join(s.Changes, ", "))
+
+	case NetworkLoadBalancerAddService:
+		return fmt.Sprintf("+ add service %q (%s/%d => %d)",
+			s.Service.Name, s.Service.Protocol, s.Service.Port, s.Service.TargetPort)
+
+	case NetworkLoadBalancerUpdateService:
+		return fmt.Sprintf("~ update service %q: %s", s.Service.Name, strings.Join(s.Changes, ", "))
+
+	case NetworkLoadBalancerDeleteService:
+		return fmt.Sprintf("- delete service %q", s.Service.Name)
+	}
+
+	return string(s.Action)
+}
+
+// NetworkLoadBalancerPlan represents the steps required to reconcile a Network Load Balancer
+// instance with its desired state.
+type NetworkLoadBalancerPlan struct {
+	// NetworkLoadBalancer is the Network Load Balancer instance reconciled, nil if it has to be
+	// created and the plan hasn't been applied yet
+	NetworkLoadBalancer *NetworkLoadBalancer
+	Steps               []*NetworkLoadBalancerPlanStep
+}
+
+// Empty returns true if the Network Load Balancer instance is already in the desired state.
+func (p *NetworkLoadBalancerPlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// String returns a human-readable description of the plan, one step per line.
+func (p *NetworkLoadBalancerPlan) String() string {
+	if p.Empty() {
+		return "no changes\n"
+	}
+
+	var b strings.Builder
+	for _, step := range p.Steps {
+		b.WriteString(step.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// ApplyNetworkLoadBalancerOptions represents the options of ApplyNetworkLoadBalancer.
+type ApplyNetworkLoadBalancerOptions struct {
+	// DryRun computes the plan without applying it
+	DryRun bool
+	// PlanOutput receives the human-readable description of the plan before it is applied, if set
+	PlanOutput io.Writer
+}
+
+// PlanNetworkLoadBalancer returns the plan required to reconcile the Network Load Balancer instance
+// matching desired (by ID if set, otherwise by name) in the specified zone with the desired state.
+func (c *Client) PlanNetworkLoadBalancer(ctx context.Context, zone string,
+	desired *NetworkLoadBalancer) (*NetworkLoadBalancerPlan, error) {
+	current, err := c.findNetworkLoadBalancer(ctx, zone, desired)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	return planNetworkLoadBalancer(current, desired), nil
+}
+
+// ApplyNetworkLoadBalancer reconciles the Network Load Balancer instance matching desired (by ID if
+// set, otherwise by name) in the specified zone with the desired state: the instance is created if
+// missing, and its services are added, updated or deleted so as to match the desired services,
+// which are matched with the existing ones by name. Steps are applied sequentially, each one
+// waiting for the completion of its operation, and stop at the first failure. The plan returned
+// reports the result of each step.
+func (c *Client) ApplyNetworkLoadBalancer(ctx context.Context, zone string, desired *NetworkLoadBalancer,
+	opts ApplyNetworkLoadBalancerOptions) (*NetworkLoadBalancerPlan, error) {
+	plan, err := c.PlanNetworkLoadBalancer(ctx, zone, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PlanOutput != nil {
+		if _, err := io.WriteString(opts.PlanOutput, plan.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	var deleted bool
+	for _, step := range plan.Steps {
+		// The services deleted must not be considered by the following steps: the Network Load
+		// Balancer instance is retrieved again once they are gone.
+		if deleted && step.Action != NetworkLoadBalancerDeleteService {
+			if plan.NetworkLoadBalancer, err = c.GetNetworkLoadBalancer(ctx, zone, plan.NetworkLoadBalancer.ID); err != nil {
+				return plan, err
+			}
+			deleted = false
+		}
+
+		if step.Err = c.applyNetworkLoadBalancerPlanStep(ctx, zone, plan, desired, step); step.Err != nil {
+			return plan, fmt.Errorf("unable to apply step %q: %s", step, step.Err)
+		}
+		step.Applied = true
+		deleted = deleted || step.Action == NetworkLoadBalancerDeleteService
+	}
+
+	if !plan.Empty() {
+		if plan.NetworkLoadBalancer, err = c.GetNetworkLoadBalancer(ctx, zone, plan.NetworkLoadBalancer.ID); err != nil {
+			return plan, err
+		}
+	}
+
+	return plan, nil
+}
+
+func (c *Client) applyNetworkLoadBalancerPlanStep(ctx context.Context, zone string, plan *NetworkLoadBalancerPlan,
+	desired *NetworkLoadBalancer, step *NetworkLoadBalancerPlanStep) error {
+	var err error
+
+	switch step.Action {
+	case NetworkLoadBalancerCreate:
+		plan.NetworkLoadBalancer, err = c.CreateNetworkLoadBalancer(ctx, zone, desired)
+
+	case NetworkLoadBalancerUpdate:
+		nlb := *plan.NetworkLoadBalancer
+		nlb.Name = desired.Name
+		nlb.Description = desired.Description
+		plan.NetworkLoadBalancer, err = c.UpdateNetworkLoadBalancer(ctx, zone, &nlb)
+
+	case NetworkLoadBalancerAddService:
+		_, err = plan.NetworkLoadBalancer.AddService(ctx, step.Service)
+
+	case NetworkLoadBalancerUpdateService:
+		err = plan.NetworkLoadBalancer.UpdateService(ctx, step.Service)
+
+	case NetworkLoadBalancerDeleteService:
+		err = plan.NetworkLoadBalancer.DeleteService(ctx, step.Service)
+
+	default:
+		err = fmt.Errorf("unsupported action %q", step.Action)
+	}
+
+	return err
+}
+
+// findNetworkLoadBalancer returns the existing Network Load Balancer instance matching nlb, by ID
+// if set, otherwise by name.
+func (c *Client) findNetworkLoadBalancer(ctx context.Context, zone string,
+	nlb *NetworkLoadBalancer) (*NetworkLoadBalancer, error) {
+	if nlb.ID != "" {
+		return c.GetNetworkLoadBalancer(ctx, zone, nlb.ID)
+	}
+
+	list, err := c.ListNetworkLoadBalancers(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *NetworkLoadBalancer
+	for _, n := range list {
+		if n.Name == nlb.Name {
+			if found != nil {
+				return nil, ErrTooManyFound
+			}
+			found = n
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+
+	// Services are not necessarily returned when listing Network Load Balancers.
+	return c.GetNetworkLoadBalancer(ctx, zone, found.ID)
+}
+
+// planNetworkLoadBalancer computes the plan required to reconcile current with desired. If current
+// is nil, the Network Load Balancer instance has to be created.
+func planNetworkLoadBalancer(current, desired *NetworkLoadBalancer) *NetworkLoadBalancerPlan {
+	plan := NetworkLoadBalancerPlan{
+		NetworkLoadBalancer: current,
+		Steps:               make([]*NetworkLoadBalancerPlanStep, 0),
+	}
+
+	existing := make(map[string]*NetworkLoadBalancerService)
+
+	if current == nil {
+		plan.Steps = append(plan.Steps, &NetworkLoadBalancerPlanStep{Action: NetworkLoadBalancerCreate})
+	} else {
+		changes := make([]string, 0)
+		if current.Name != desired.Name {
+			changes = append(changes, fmt.Sprintf("name %q => %q", current.Name, desired.Name))
+		}
+		if current.Description != desired.Description {
+			changes = append(changes, fmt.Sprintf("description %q => %q", current.Description, desired.Description))
+		}
+		if len(changes) > 0 {
+			plan.Steps = append(plan.Steps, &NetworkLoadBalancerPlanStep{
+				Action:  NetworkLoadBalancerUpdate,
+				Changes: changes,
+			})
+		}
+
+		for _, svc := range current.Services {
+			existing[svc.Name] = svc
+		}
+	}
+
+	var (
+		deletions = make([]*NetworkLoadBalancerPlanStep, 0)
+		updates   = make([]*NetworkLoadBalancerPlanStep, 0)
+		additions = make([]*NetworkLoadBalancerPlanStep, 0)
+		wanted    = make(map[string]struct{})
+	)
+
+	for _, svc := range desired.Services {
+		wanted[svc.Name] = struct{}{}
+
+		cur, ok := existing[svc.Name]
+		if !ok {
+			additions = append(additions, &NetworkLoadBalancerPlanStep{
+				Action:  NetworkLoadBalancerAddService,
+				Service: svc,
+			})
+			continue
+		}
+
+		// The Instance Pool of a service cannot be changed: the service has to be re-created.
+		if svc.InstancePoolID != cur.InstancePoolID {
+			deletions = append(deletions, &NetworkLoadBalancerPlanStep{
+				Action:  NetworkLoadBalancerDeleteService,
+				Service: cur,
+			})
+			additions = append(additions, &NetworkLoadBalancerPlanStep{
+				Action:  NetworkLoadBalancerAddService,
+				Service: svc,
+			})
+			continue
+		}
+
+		if changes := diffNetworkLoadBalancerServices(cur, svc); len(changes) > 0 {
+			update := *svc
+			update.ID = cur.ID
+			updates = append(updates, &NetworkLoadBalancerPlanStep{
+				Action:  NetworkLoadBalancerUpdateService,
+				Service: &update,
+				Changes: changes,
+			})
+		}
+	}
+
+	if current != nil {
+		for _, svc := range current.Services {
+			if _, ok := wanted[svc.Name]; !ok {
+				deletions = append(deletions, &NetworkLoadBalancerPlanStep{
+					Action:  NetworkLoadBalancerDeleteService,
+					Service: svc,
+				})
+			}
+		}
+	}
+
+	// Deletions are applied first to release the ports of the services removed.
+	plan.Steps = append(plan.Steps, deletions...)
+	plan.Steps = append(plan.Steps, updates...)
+	plan.Steps = append(plan.Steps, additions...)
+
+	return &plan
+}
+
+// Default values of the Network Load Balancer service properties, applied by the API if unset.
+const (
+	defaultNLBServiceProtocol            = "tcp"
+	defaultNLBServiceStrategy            = "round-robin"
+	defaultNLBServiceHealthcheckInterval = 10 * time.Second
+)
+
+// networkLoadBalancerServiceWithDefaults returns a copy of the service with the unset properties
+// set to the values applied by the API.
+func networkLoadBalancerServiceWithDefaults(svc *NetworkLoadBalancerService) *NetworkLoadBalancerService {
+	s := *svc
+	if s.Protocol == "" {
+		s.Protocol = defaultNLBServiceProtocol
+	}
+	if s.Strategy == "" {
+		s.Strategy = defaultNLBServiceStrategy
+	}
+	if s.Healthcheck.Interval == 0 {
+		s.Healthcheck.Interval = defaultNLBServiceHealthcheckInterval
+	}
+
+	return &s
+}
+
+// diffNetworkLoadBalancerServices returns the list of changes required to update a service from
+// current to desired, in a human-readable form. The properties left unset are compared using
+// the API default values.
+func diffNetworkLoadBalancerServices(current, desired *NetworkLoadBalancerService) []string {
+	var changes = make([]string, 0)
+
+	current = networkLoadBalancerServiceWithDefaults(current)
+	desired = networkLoadBalancerServiceWithDefaults(desired)
+
+	diff := func(property string, a, b interface{}) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s %v => %v", property, a, b))
+		}
+	}
+
+	diff("description", current.Description, desired.Description)
+	diff("protocol", current.Protocol, desired.Protocol)
+	diff("port", current.Port, desired.Port)
+	diff("target port", current.TargetPort, desired.TargetPort)
+	diff("strategy", current.Strategy, desired.Strategy)
+	diff("healthcheck mode", current.Healthcheck.Mode, desired.Healthcheck.Mode)
+	diff("healthcheck port", current.Healthcheck.Port, desired.Healthcheck.Port)
+	diff("healthcheck interval", current.Healthcheck.Interval, desired.Healthcheck.Interval)
+	diff("healthcheck timeout", current.Healthcheck.Timeout, desired.Healthcheck.Timeout)
+	diff("healthcheck retries", current.Healthcheck.Retries, desired.Healthcheck.Retries)
+	if strings.HasPrefix(desired.Healthcheck.Mode, "http") {
+		diff("healthcheck URI", current.Healthcheck.URI, desired.Healthcheck.URI)
+	}
+	if desired.Healthcheck.Mode == "https" {
+		diff("healthcheck TLS SNI", current.Healthcheck.TLSSNI, desired.Healthcheck.TLSSNI)
+	}
+
+	return changes
+}
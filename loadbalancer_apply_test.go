@@ -0,0 +1,209 @@
+package egoscale
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+func newTestNLBService(name string, port uint16) *NetworkLoadBalancerService {
+	return &NetworkLoadBalancerService{
+		ID:             "id-" + name,
+		Name:           name,
+		InstancePoolID: testNLBServiceInstancePoolID,
+		Protocol:       "tcp",
+		Port:           port,
+		TargetPort:     port,
+		Strategy:       "round-robin",
+		Healthcheck: NetworkLoadBalancerServiceHealthcheck{
+			Mode:     "tcp",
+			Port:     port,
+			Interval: 10 * time.Second,
+			Timeout:  3 * time.Second,
+			Retries:  1,
+		},
+	}
+}
+
+func TestPlanNetworkLoadBalancer(t *testing.T) {
+	current := &NetworkLoadBalancer{
+		ID:          testNLBID,
+		Name:        testNLBName,
+		Description: "old",
+		Services: []*NetworkLoadBalancerService{
+			newTestNLBService("http", 80),
+			newTestNLBService("legacy", 8000),
+			newTestNLBService("https", 443),
+			newTestNLBService("ssh", 22),
+		},
+	}
+
+	httpSvc := newTestNLBService("http", 80)
+	httpSvc.ID = ""
+	httpSvc.TargetPort = 8080
+	httpSvc.Healthcheck.Mode = "http"
+	httpSvc.Healthcheck.URI = "/health"
+
+	httpsSvc := newTestNLBService("https", 443)
+	httpsSvc.InstancePoolID = "1e2b7d8a-3c0b-4e59-a8ef-0f2e3b9e6a7c"
+
+	dnsSvc := newTestNLBService("dns", 53)
+	dnsSvc.Protocol = "udp"
+
+	desired := &NetworkLoadBalancer{
+		Name:        testNLBName,
+		Description: "new",
+		Services: []*NetworkLoadBalancerService{
+			httpSvc,
+			httpsSvc,
+			newTestNLBService("ssh", 22),
+			dnsSvc,
+		},
+	}
+
+	plan := planNetworkLoadBalancer(current, desired)
+	require.Equal(t, current, plan.NetworkLoadBalancer)
+	require.Len(t, plan.Steps, 6)
+
+	require.Equal(t, NetworkLoadBalancerUpdate, plan.Steps[0].Action)
+	require.Equal(t, []string{`description "old" => "new"`}, plan.Steps[0].Changes)
+
+	require.Equal(t, NetworkLoadBalancerDeleteService, plan.Steps[1].Action)
+	require.Equal(t, "id-https", plan.Steps[1].Service.ID)
+
+	require.Equal(t, NetworkLoadBalancerDeleteService, plan.Steps[2].Action)
+	require.Equal(t, "id-legacy", plan.Steps[2].Service.ID)
+
+	require.Equal(t, NetworkLoadBalancerUpdateService, plan.Steps[3].Action)
+	require.Equal(t, "id-http", plan.Steps[3].Service.ID)
+	require.Equal(t, []string{
+		"target port 80 => 8080",
+		"healthcheck mode tcp => http",
+		"healthcheck URI  => /health",
+	}, plan.Steps[3].Changes)
+
+	require.Equal(t, NetworkLoadBalancerAddService, plan.Steps[4].Action)
+	require.Equal(t, httpsSvc, plan.Steps[4].Service)
+
+	require.Equal(t, NetworkLoadBalancerAddService, plan.Steps[5].Action)
+	require.Equal(t, dnsSvc, plan.Steps[5].Service)
+
+	require.Equal(t, `~ update Network Load Balancer: description "old" => "new"
+- delete service "https"
+- delete service "legacy"
+~ update service "http": target port 80 => 8080, healthcheck mode tcp => http, healthcheck URI  => /health
++ add service "https" (tcp/443 => 443)
++ add service "dns" (udp/53 => 53)
+`, plan.String())
+
+	// An up-to-date Network Load Balancer must result in an empty plan
+	require.True(t, planNetworkLoadBalancer(current, current).Empty())
+
+	// Unset properties must be compared using the API default values
+	sshSvc := newTestNLBService("ssh", 22)
+	sshSvc.Protocol = ""
+	sshSvc.Strategy = ""
+	sshSvc.Healthcheck.Interval = 0
+	require.True(t, planNetworkLoadBalancer(
+		&NetworkLoadBalancer{Name: testNLBName, Services: []*NetworkLoadBalancerService{newTestNLBService("ssh", 22)}},
+		&NetworkLoadBalancer{Name: testNLBName, Services: []*NetworkLoadBalancerService{sshSvc}},
+	).Empty())
+
+	// A missing Network Load Balancer must be created first
+	plan = planNetworkLoadBalancer(nil, desired)
+	require.Len(t, plan.Steps, 5)
+	require.Equal(t, NetworkLoadBalancerCreate, plan.Steps[0].Action)
+	for _, step := range plan.Steps[1:] {
+		require.Equal(t, NetworkLoadBalancerAddService, step.Action)
+	}
+}
+
+func TestClient_ApplyNetworkLoadBalancer(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	backend := testNLBServicesBackend{t: t}
+	backend.register(mockClient)
+
+	desired := &NetworkLoadBalancer{
+		ID:   testNLBID,
+		Name: testNLBName,
+		Services: []*NetworkLoadBalancerService{
+			newTestNLBService("http", 80),
+		},
+	}
+
+	// In dry-run mode the plan must only be printed
+	var output bytes.Buffer
+	plan, err := client.ApplyNetworkLoadBalancer(context.Background(), testZone, desired,
+		ApplyNetworkLoadBalancerOptions{DryRun: true, PlanOutput: &output})
+	require.NoError(t, err)
+	require.Equal(t, "+ add service \"http\" (tcp/80 => 80)\n", output.String())
+	require.Len(t, plan.Steps, 1)
+	require.False(t, plan.Steps[0].Applied)
+	require.Empty(t, backend.services)
+
+	plan, err = client.ApplyNetworkLoadBalancer(context.Background(), testZone, desired,
+		ApplyNetworkLoadBalancerOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.True(t, plan.Steps[0].Applied)
+	require.NoError(t, plan.Steps[0].Err)
+	require.Len(t, backend.services, 1)
+	require.Len(t, plan.NetworkLoadBalancer.Services, 1)
+	require.Equal(t, "http", plan.NetworkLoadBalancer.Services[0].Name)
+
+	// Applying the same state again must be a no-op
+	plan, err = client.ApplyNetworkLoadBalancer(context.Background(), testZone, desired,
+		ApplyNetworkLoadBalancerOptions{})
+	require.NoError(t, err)
+	require.True(t, plan.Empty())
+
+	// The Network Load Balancer instance must be retrieved again once services are deleted
+	mockClient.RegisterResponder("DELETE", "=~^/load-balancer/"+testNLBID+"/service/",
+		func(req *http.Request) (*http.Response, error) {
+			id := strings.TrimPrefix(req.URL.Path, "/load-balancer/"+testNLBID+"/service/")
+
+			backend.Lock()
+			services := backend.services[:0]
+			for _, svc := range backend.services {
+				if *svc.Id != id {
+					services = append(services, svc)
+				}
+			}
+			backend.services = services
+			backend.Unlock()
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{Id: &id, Reference: &v2.Reference{Id: &testNLBID}})
+			if err != nil {
+				t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+	mockClient.ZeroCallCounters()
+
+	desired.Services = []*NetworkLoadBalancerService{newTestNLBService("https", 443)}
+	plan, err = client.ApplyNetworkLoadBalancer(context.Background(), testZone, desired,
+		ApplyNetworkLoadBalancerOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+	require.Equal(t, NetworkLoadBalancerDeleteService, plan.Steps[0].Action)
+	require.Equal(t, NetworkLoadBalancerAddService, plan.Steps[1].Action)
+	require.Len(t, plan.NetworkLoadBalancer.Services, 1)
+	require.Equal(t, "https", plan.NetworkLoadBalancer.Services[0].Name)
+	// Lookup, after the deletion, after the addition and once the plan is applied
+	require.Equal(t, 4, mockClient.GetCallCountInfo()["GET /load-balancer/"+testNLBID])
+}
@@ -0,0 +1,244 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// nlbServerStatusHealthy is the healthcheck status of a healthy Network Load Balancer service target server.
+const nlbServerStatusHealthy = "success"
+
+// NetworkLoadBalancerHealthEvent represents a health transition of a Network Load Balancer service.
+type NetworkLoadBalancerHealthEvent struct {
+	Time        time.Time
+	ServiceID   string
+	ServiceName string
+	// InstanceIP is the IP address of the target server which healthcheck status changed, or nil
+	// if the event reports a change of the service state
+	InstanceIP net.IP
+	// From is the previous status (or state), empty if the server (or service) just appeared
+	From string
+	// To is the new status (or state), empty if the server (or service) disappeared
+	To string
+	// Err reports an error that occurred while polling the Network Load Balancer, in which case
+	// all other fields except Time are empty
+	Err error
+}
+
+// HealthyServers returns the number of target servers of the service reported healthy.
+func (svc *NetworkLoadBalancerService) HealthyServers() int {
+	var n int
+
+	for _, st := range svc.HealthcheckStatus {
+		if st.Status == nlbServerStatusHealthy {
+			n++
+		}
+	}
+
+	return n
+}
+
+// WaitHealthy blocks until every service of the Network Load Balancer instance has at least
+// minHealthy healthy target servers, or the context is done or the client timeout is reached. An
+// error is returned if the Network Load Balancer instance has no services to wait for.
+func (nlb *NetworkLoadBalancer) WaitHealthy(ctx context.Context, minHealthy int) error {
+	_, err := v2.NewPoller().
+		WithTimeout(nlb.c.Timeout).
+		Poll(ctx, func(ctx context.Context) (bool, interface{}, error) {
+			current, err := nlb.c.GetNetworkLoadBalancer(ctx, nlb.zone, nlb.ID)
+			if err != nil {
+				return true, nil, err
+			}
+			if len(current.Services) == 0 {
+				return true, nil, fmt.Errorf("Network Load Balancer %s has no services", nlb.ID)
+			}
+
+			for _, svc := range current.Services {
+				if svc.HealthyServers() < minHealthy {
+					return false, nil, nil
+				}
+			}
+
+			return true, current, nil
+		})
+
+	return err
+}
+
+// nlbHealthState represents the health state of a Network Load Balancer instance, indexed by
+// service ID.
+type nlbHealthState map[string]*nlbServiceHealthState
+
+type nlbServiceHealthState struct {
+	name    string
+	state   string
+	servers map[string]string
+}
+
+func nlbHealthStateOf(nlb *NetworkLoadBalancer) nlbHealthState {
+	state := make(nlbHealthState)
+
+	for _, svc := range nlb.Services {
+		s := nlbServiceHealthState{
+			name:    svc.Name,
+			state:   svc.State,
+			servers: make(map[string]string),
+		}
+		for _, st := range svc.HealthcheckStatus {
+			s.servers[st.InstanceIP.String()] = st.Status
+		}
+
+		state[svc.ID] = &s
+	}
+
+	return state
+}
+
+// diff returns the events describing the transitions from prev to s, ordered by service and
+// target server IP address.
+func (s nlbHealthState) diff(prev nlbHealthState, now time.Time) []*NetworkLoadBalancerHealthEvent {
+	var events = make([]*NetworkLoadBalancerHealthEvent, 0)
+
+	for id, svc := range s {
+		p, ok := prev[id]
+		if !ok {
+			p = &nlbServiceHealthState{servers: map[string]string{}}
+		}
+
+		if p.state != svc.state {
+			events = append(events, &NetworkLoadBalancerHealthEvent{
+				Time:        now,
+				ServiceID:   id,
+				ServiceName: svc.name,
+				From:        p.state,
+				To:          svc.state,
+			})
+		}
+
+		for ip, status := range svc.servers {
+			if p.servers[ip] != status {
+				events = append(events, &NetworkLoadBalancerHealthEvent{
+					Time:        now,
+					ServiceID:   id,
+					ServiceName: svc.name,
+					InstanceIP:  net.ParseIP(ip),
+					From:        p.servers[ip],
+					To:          status,
+				})
+			}
+		}
+
+		for ip, status := range p.servers {
+			if _, ok := svc.servers[ip]; !ok {
+				events = append(events, &NetworkLoadBalancerHealthEvent{
+					Time:        now,
+					ServiceID:   id,
+					ServiceName: svc.name,
+					InstanceIP:  net.ParseIP(ip),
+					From:        status,
+				})
+			}
+		}
+	}
+
+	for id, p := range prev {
+		if _, ok := s[id]; !ok {
+			events = append(events, &NetworkLoadBalancerHealthEvent{
+				Time:        now,
+				ServiceID:   id,
+				ServiceName: p.name,
+				From:        p.state,
+			})
+		}
+	}
+
+	// Service state events (without instance IP address) come before their target servers events.
+	ipKey := func(ip net.IP) string {
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].ServiceID != events[j].ServiceID {
+			return events[i].ServiceID < events[j].ServiceID
+		}
+		return ipKey(events[i].InstanceIP) < ipKey(events[j].InstanceIP)
+	})
+
+	return events
+}
+
+// WatchNetworkLoadBalancerHealth polls the Network Load Balancer instance corresponding to the
+// specified ID in the specified zone at the specified interval, and sends an event on the returned
+// channel every time a service target server healthcheck status or a service state changes. The
+// initial state of the services and their target servers is reported as a first series of events.
+// Polling errors are reported as events and don't interrupt the polling. The channel is closed
+// once the context is done. The interval must be positive.
+func (c *Client) WatchNetworkLoadBalancerHealth(ctx context.Context, zone, id string,
+	interval time.Duration) (<-chan *NetworkLoadBalancerHealthEvent, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid polling interval %s, must be positive", interval)
+	}
+
+	nlb, err := c.GetNetworkLoadBalancer(ctx, zone, id)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *NetworkLoadBalancerHealthEvent)
+
+	go func() {
+		defer close(events)
+
+		send := func(e *NetworkLoadBalancerHealthEvent) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		state := nlbHealthStateOf(nlb)
+		for _, e := range state.diff(nil, time.Now()) {
+			if !send(e) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				nlb, err := c.GetNetworkLoadBalancer(ctx, zone, id)
+				if err != nil {
+					if ctx.Err() != nil || !send(&NetworkLoadBalancerHealthEvent{Time: time.Now(), Err: err}) {
+						return
+					}
+					continue
+				}
+
+				newState := nlbHealthStateOf(nlb)
+				for _, e := range newState.diff(state, time.Now()) {
+					if !send(e) {
+						return
+					}
+				}
+				state = newState
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
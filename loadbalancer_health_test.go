@@ -0,0 +1,189 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+// registerTestNLBHealthResponder registers a mock HTTP responder returning the test Network Load
+// Balancer with a single service, which state and target servers statuses are returned by next
+// at every call.
+func registerTestNLBHealthResponder(t *testing.T, mockClient *v2.MockClient,
+	next func() (string, map[string]string)) {
+	var mu sync.Mutex
+
+	mockClient.RegisterResponder("GET", "/load-balancer/"+testNLBID,
+		func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			state, servers := next()
+			mu.Unlock()
+
+			statuses := make([]v2.LoadBalancerServerStatus, 0)
+			for ip, status := range servers {
+				ip, status := ip, status
+				statuses = append(statuses, v2.LoadBalancerServerStatus{PublicIp: &ip, Status: &status})
+			}
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.LoadBalancer{
+				Id:        &testNLBID,
+				Name:      &testNLBName,
+				CreatedAt: &testNLBCreatedAt,
+				Services: &[]v2.LoadBalancerService{{
+					Id:                &testNLBServiceID,
+					Name:              &testNLBServiceName,
+					InstancePool:      &v2.Resource{Id: &testNLBServiceInstancePoolID},
+					Healthcheck:       &v2.Healthcheck{},
+					HealthcheckStatus: &statuses,
+					State:             &state,
+				}},
+			})
+			if err != nil {
+				t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+}
+
+func TestClient_WatchNetworkLoadBalancerHealth(t *testing.T) {
+	var (
+		ip1 = testNLBServiceHealthcheckStatus1InstanceIP
+		ip2 = testNLBServiceHealthcheckStatus2InstanceIP
+		err error
+	)
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	steps := []struct {
+		state   string
+		servers map[string]string
+	}{
+		{"creating", map[string]string{ip1: "failure"}},
+		{"running", map[string]string{ip1: "success"}},
+		{"running", map[string]string{ip1: "success"}},
+		{"running", map[string]string{ip1: "success", ip2: "failure"}},
+		{"running", map[string]string{ip2: "success"}},
+	}
+	calls := 0
+	registerTestNLBHealthResponder(t, mockClient, func() (string, map[string]string) {
+		step := steps[len(steps)-1]
+		if calls < len(steps) {
+			step = steps[calls]
+		}
+		calls++
+		return step.state, step.servers
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = client.WatchNetworkLoadBalancerHealth(ctx, testZone, testNLBID, 0)
+	require.Error(t, err)
+
+	events, err := client.WatchNetworkLoadBalancerHealth(ctx, testZone, testNLBID, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	type transition struct {
+		ip       string
+		from, to string
+	}
+
+	expected := []transition{
+		{"", "", "creating"},
+		{ip1, "", "failure"},
+		{"", "creating", "running"},
+		{ip1, "failure", "success"},
+		{ip2, "", "failure"},
+		{ip1, "success", ""},
+		{ip2, "failure", "success"},
+	}
+
+	actual := make([]transition, 0)
+	for e := range events {
+		require.NoError(t, e.Err)
+		require.Equal(t, testNLBServiceID, e.ServiceID)
+		require.Equal(t, testNLBServiceName, e.ServiceName)
+
+		ip := ""
+		if e.InstanceIP != nil {
+			ip = e.InstanceIP.String()
+		}
+		actual = append(actual, transition{ip, e.From, e.To})
+
+		if len(actual) == len(expected) {
+			cancel()
+		}
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestNetworkLoadBalancer_WaitHealthy(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	calls := 0
+	registerTestNLBHealthResponder(t, mockClient, func() (string, map[string]string) {
+		calls++
+		if calls == 1 {
+			return "running", map[string]string{
+				testNLBServiceHealthcheckStatus1InstanceIP: "success",
+				testNLBServiceHealthcheckStatus2InstanceIP: "failure",
+			}
+		}
+		return "running", map[string]string{
+			testNLBServiceHealthcheckStatus1InstanceIP: "success",
+			testNLBServiceHealthcheckStatus2InstanceIP: "success",
+		}
+	})
+
+	nlb := &NetworkLoadBalancer{
+		ID: testNLBID,
+
+		c:    client,
+		zone: testZone,
+	}
+
+	require.NoError(t, nlb.WaitHealthy(context.Background(), 2))
+	require.Equal(t, 2, calls)
+
+	// A Network Load Balancer without services has nothing to wait for
+	mockClient.RegisterResponder("GET", "/load-balancer/"+testNLBID,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(http.StatusOK, v2.LoadBalancer{
+				Id:        &testNLBID,
+				Name:      &testNLBName,
+				CreatedAt: &testNLBCreatedAt,
+			})
+		})
+	require.EqualError(t, nlb.WaitHealthy(context.Background(), 1),
+		fmt.Sprintf("Network Load Balancer %s has no services", testNLBID))
+}
+
+func TestNetworkLoadBalancerService_HealthyServers(t *testing.T) {
+	svc := NetworkLoadBalancerService{
+		HealthcheckStatus: []*NetworkLoadBalancerServerStatus{
+			{InstanceIP: net.ParseIP("1.2.3.4"), Status: "success"},
+			{InstanceIP: net.ParseIP("5.6.7.8"), Status: "failure"},
+			{InstanceIP: net.ParseIP("9.10.11.12"), Status: "success"},
+		},
+	}
+
+	require.Equal(t, 2, svc.HealthyServers())
+}
@@ -2,8 +2,12 @@ package egoscale
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -177,11 +181,217 @@ func TestNetworkLoadBalancer_AddService(t *testing.T) {
 
 }
 
+// testNLBServicesBackend is a minimal stateful mock of the Network Load Balancer services API,
+// used to test concurrent services creation.
+type testNLBServicesBackend struct {
+	sync.Mutex
+
+	t        *testing.T
+	services []v2.LoadBalancerService
+	// serviceLink makes the AddService operations reference the service created.
+	serviceLink bool
+}
+
+func (b *testNLBServicesBackend) register(mockClient *v2.MockClient) {
+	var testOperationState = "success"
+
+	mockClient.RegisterResponder("POST", "/load-balancer/"+testNLBID+"/service",
+		func(req *http.Request) (*http.Response, error) {
+			var svc v2.LoadBalancerService
+			if err := json.NewDecoder(req.Body).Decode(&svc); err != nil {
+				b.t.Errorf("error decoding request body: %s", err)
+				return httpmock.NewStringResponse(http.StatusBadRequest, err.Error()), nil
+			}
+
+			b.Lock()
+			id := fmt.Sprintf("00000000-0000-0000-0000-%012d", len(b.services)+1)
+			svc.Id = &id
+			b.services = append(b.services, svc)
+			b.Unlock()
+
+			ref := v2.Reference{Id: &testNLBID}
+			if b.serviceLink {
+				link := "/v2/load-balancer/" + testNLBID + "/service/" + id
+				ref.Link = &link
+			}
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &id,
+				State:     &testOperationState,
+				Reference: &ref,
+			})
+			if err != nil {
+				b.t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "=~^/operation/",
+		func(req *http.Request) (*http.Response, error) {
+			id := strings.TrimPrefix(req.URL.Path, "/operation/")
+
+			b.Lock()
+			ref := v2.Reference{Id: &testNLBID}
+			if b.serviceLink {
+				link := "/v2/load-balancer/" + testNLBID + "/service/" + id
+				ref.Link = &link
+			}
+			b.Unlock()
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.Operation{
+				Id:        &id,
+				State:     &testOperationState,
+				Reference: &ref,
+			})
+			if err != nil {
+				b.t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+
+	mockClient.RegisterResponder("GET", "/load-balancer/"+testNLBID,
+		func(req *http.Request) (*http.Response, error) {
+			b.Lock()
+			services := append([]v2.LoadBalancerService(nil), b.services...)
+			b.Unlock()
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, v2.LoadBalancer{
+				Id:        &testNLBID,
+				Name:      &testNLBName,
+				CreatedAt: &testNLBCreatedAt,
+				Services:  &services,
+			})
+			if err != nil {
+				b.t.Errorf("error initializing mock HTTP responder: %s", err)
+				return httpmock.NewStringResponse(http.StatusInternalServerError, err.Error()), nil
+			}
+			return resp, nil
+		})
+}
+
+func TestNetworkLoadBalancer_AddService_Concurrent(t *testing.T) {
+	for _, serviceLink := range []bool{false, true} {
+		var err error
+
+		mockClient := v2.NewMockClient()
+		client := NewClient("x", "x", "x")
+		client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+		require.NoError(t, err)
+
+		backend := testNLBServicesBackend{t: t, serviceLink: serviceLink}
+		backend.register(mockClient)
+
+		nlb := &NetworkLoadBalancer{
+			ID:        testNLBID,
+			Name:      testNLBName,
+			CreatedAt: testNLBCreatedAt,
+
+			c: client,
+		}
+
+		var (
+			wg      sync.WaitGroup
+			results = make([]*NetworkLoadBalancerService, 5)
+			errs    = make([]error, 5)
+		)
+
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				// All services share the same name, only their port differs.
+				results[i], errs[i] = nlb.AddService(context.Background(), &NetworkLoadBalancerService{
+					Name:       testNLBServiceName,
+					Port:       uint16(8000 + i),
+					TargetPort: uint16(8000 + i),
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		ids := make(map[string]struct{})
+		for i := range results {
+			require.NoError(t, errs[i])
+			require.Equal(t, uint16(8000+i), results[i].Port)
+			ids[results[i].ID] = struct{}{}
+		}
+		require.Len(t, ids, len(results))
+	}
+}
+
+func TestNetworkLoadBalancer_AddService_Ambiguous(t *testing.T) {
+	var err error
+
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(mockClient))
+	require.NoError(t, err)
+
+	backend := testNLBServicesBackend{t: t}
+	backend.register(mockClient)
+
+	// Simulate an unknown service conflicting with the one about to be created.
+	conflictID := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	backend.services = append(backend.services, v2.LoadBalancerService{
+		Id:           &conflictID,
+		Name:         &testNLBServiceName,
+		InstancePool: &v2.Resource{Id: &testNLBServiceInstancePoolID},
+		Port:         &testNLBServicePort,
+		TargetPort:   &testNLBServiceTargetPort,
+		Healthcheck:  &v2.Healthcheck{},
+	})
+
+	nlb := &NetworkLoadBalancer{
+		ID:        testNLBID,
+		Name:      testNLBName,
+		CreatedAt: testNLBCreatedAt,
+
+		c: client,
+	}
+
+	_, err = nlb.AddService(context.Background(), &NetworkLoadBalancerService{
+		Name:       testNLBServiceName,
+		Port:       uint16(testNLBServicePort),
+		TargetPort: uint16(testNLBServiceTargetPort),
+	})
+	require.Error(t, err)
+}
+
 // UpdateService is not tested as it essentially relies on the already tested GetNetworkLoadBalancer.
 func TestNetworkLoadBalancer_UpdateService(t *testing.T) { t.Skip() }
 
-// DeleteService is not tested as it only produces API-side effects.
-func TestNetworkLoadBalancer_DeleteService(t *testing.T) { t.Skip() }
+func TestNetworkLoadBalancer_DeleteService(t *testing.T) {
+	var err error
+
+	server := v2.NewFakeServer(testZone)
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(server))
+	require.NoError(t, err)
+
+	server.AddLoadBalancer(v2.LoadBalancer{
+		Id:   &testNLBID,
+		Name: &testNLBName,
+		Services: &[]v2.LoadBalancerService{{
+			Id:           &testNLBServiceID,
+			Name:         &testNLBServiceName,
+			InstancePool: &v2.Resource{Id: &testNLBServiceInstancePoolID},
+			Healthcheck:  &v2.Healthcheck{},
+		}},
+	})
+
+	nlb, err := client.GetNetworkLoadBalancer(context.Background(), testZone, testNLBID)
+	require.NoError(t, err)
+	require.Len(t, nlb.Services, 1)
+
+	require.NoError(t, nlb.DeleteService(context.Background(), nlb.Services[0]))
+
+	nlb, err = client.GetNetworkLoadBalancer(context.Background(), testZone, testNLBID)
+	require.NoError(t, err)
+	require.Empty(t, nlb.Services)
+}
 
 // CreateNetworkLoadBalancer is not tested as it essentially relies on the already tested GetNetworkLoadBalancer.
 func TestClient_CreateNetworkLoadBalancer(t *testing.T) { t.Skip() }
@@ -387,5 +597,19 @@ func TestClient_GetNetworkLoadBalancer(t *testing.T) {
 // UpdateNetworkLoadBalancer is not tested as it essentially relies on the already tested GetNetworkLoadBalancer.
 func TestClient_UpdateNetworkLoadBalancer(t *testing.T) { t.Skip() }
 
-// DeleteNetworkLoadBalancer is not tested as it only produces API-side effects.
-func TestClient_DeleteNetworkLoadBalancer(t *testing.T) { t.Skip() }
+func TestClient_DeleteNetworkLoadBalancer(t *testing.T) {
+	var err error
+
+	server := v2.NewFakeServer(testZone)
+	client := NewClient("x", "x", "x")
+	client.V2, err = v2.NewClientWithResponses("", v2.WithHTTPClient(server))
+	require.NoError(t, err)
+
+	server.AddLoadBalancer(v2.LoadBalancer{Id: &testNLBID, Name: &testNLBName})
+
+	require.NoError(t, client.DeleteNetworkLoadBalancer(context.Background(), testZone, testNLBID))
+
+	_, err = client.GetNetworkLoadBalancer(context.Background(), testZone, testNLBID)
+	require.Error(t, err)
+	require.Equal(t, ErrNotFound, client.DeleteNetworkLoadBalancer(context.Background(), testZone, testNLBID))
+}
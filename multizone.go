@@ -0,0 +1,120 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ZoneErrors represents the errors that occurred during a multi-zone operation, indexed by zone.
+type ZoneErrors map[string]error
+
+// Error implements the error interface
+func (e ZoneErrors) Error() string {
+	zones := make([]string, 0, len(e))
+	for zone := range e {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	msgs := make([]string, len(zones))
+	for i, zone := range zones {
+		msgs[i] = fmt.Sprintf("%s: %s", zone, e[zone])
+	}
+
+	return fmt.Sprintf("error in %d zone(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ListAvailableZones returns the names of the zones available through the Exoscale API V2.
+func (c *Client) ListAvailableZones(ctx context.Context) ([]string, error) {
+	var list = make([]string, 0)
+
+	resp, err := c.V2.ListZonesWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, apiv2Error(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	if resp.JSON200.Zones != nil {
+		for _, zone := range *resp.JSON200.Zones {
+			if name := optionalString(zone.Name); name != "" {
+				list = append(list, name)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// FanOut executes fn concurrently in each of the specified zones, or in every available zone if
+// no zones are specified. A failure in a zone doesn't interrupt the execution in the other zones:
+// if fn fails in some zones, the error returned is of type ZoneErrors.
+func (c *Client) FanOut(ctx context.Context, zones []string, fn func(ctx context.Context, zone string) error) error {
+	if len(zones) == 0 {
+		var err error
+		if zones, err = c.ListAvailableZones(ctx); err != nil {
+			return fmt.Errorf("unable to list available zones: %s", err)
+		}
+	}
+
+	var (
+		errs = make(ZoneErrors)
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for _, zone := range zones {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+
+			if err := fn(ctx, zone); err != nil {
+				mu.Lock()
+				errs[zone] = err
+				mu.Unlock()
+			}
+		}(zone)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// ListNetworkLoadBalancersInZones returns the Network Load Balancers existing in the specified
+// zones, or in every available zone if no zones are specified, indexed by zone. Zones are queried
+// concurrently: if some zones fail, the results of the other zones are returned along with an
+// error of type ZoneErrors.
+func (c *Client) ListNetworkLoadBalancersInZones(ctx context.Context,
+	zones ...string) (map[string][]*NetworkLoadBalancer, error) {
+	var (
+		res = make(map[string][]*NetworkLoadBalancer)
+		mu  sync.Mutex
+	)
+
+	err := c.FanOut(ctx, zones, func(ctx context.Context, zone string) error {
+		list, err := c.ListNetworkLoadBalancers(ctx, zone)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		res[zone] = list
+		mu.Unlock()
+
+		return nil
+	})
+	if _, ok := err.(ZoneErrors); err != nil && !ok {
+		return nil, err
+	}
+
+	return res, err
+}
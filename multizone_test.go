@@ -0,0 +1,141 @@
+package egoscale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	apiv2 "github.com/exoscale/egoscale/api/v2"
+	v2 "github.com/exoscale/egoscale/pkg/v2"
+)
+
+var testZones = []string{"ch-gva-2", "ch-dk-2", "de-fra-1"}
+
+// newTestMultiZoneClient returns a Client which API V2 requests are routed to zone-specific
+// endpoints, with a mock HTTP responder listing the test zones.
+func newTestMultiZoneClient(t *testing.T) (*Client, *v2.MockClient) {
+	mockClient := v2.NewMockClient()
+	client := NewClient("x", "x", "x")
+
+	var err error
+	client.V2, err = v2.NewClientWithResponses("https://api.exoscale.com/v2.alpha/",
+		v2.WithHTTPClient(mockClient),
+		v2.WithRequestEditorFn(apiv2.SetEndpointFromContext))
+	require.NoError(t, err)
+
+	mockClient.RegisterResponder("GET", "https://api.exoscale.com/v2.alpha/zone",
+		func(req *http.Request) (*http.Response, error) {
+			zones := make([]v2.Zone, len(testZones))
+			for i := range testZones {
+				zones[i] = v2.Zone{Name: &testZones[i]}
+			}
+
+			resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+				Zones *[]v2.Zone `json:"zones,omitempty"`
+			}{
+				Zones: &zones,
+			})
+			if err != nil {
+				t.Fatalf("error initializing mock HTTP responder: %s", err)
+			}
+			return resp, nil
+		})
+
+	return client, mockClient
+}
+
+func TestZoneErrors_Error(t *testing.T) {
+	err := ZoneErrors{
+		"de-fra-1": errors.New("o noes"),
+		"ch-dk-2":  errors.New("ouch"),
+	}
+
+	require.Equal(t, "error in 2 zone(s): ch-dk-2: ouch; de-fra-1: o noes", err.Error())
+}
+
+func TestClient_ListAvailableZones(t *testing.T) {
+	client, _ := newTestMultiZoneClient(t)
+
+	actual, err := client.ListAvailableZones(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, testZones, actual)
+}
+
+func TestClient_FanOut(t *testing.T) {
+	client, _ := newTestMultiZoneClient(t)
+
+	var (
+		visited = make(map[string]bool)
+		mu      sync.Mutex
+	)
+
+	err := client.FanOut(context.Background(), nil, func(_ context.Context, zone string) error {
+		mu.Lock()
+		visited[zone] = true
+		mu.Unlock()
+
+		if zone == "ch-dk-2" {
+			return errors.New("o noes")
+		}
+
+		return nil
+	})
+	require.Equal(t, map[string]bool{"ch-gva-2": true, "ch-dk-2": true, "de-fra-1": true}, visited)
+	require.IsType(t, ZoneErrors{}, err)
+	require.Len(t, err.(ZoneErrors), 1)
+	require.Error(t, err.(ZoneErrors)["ch-dk-2"])
+
+	// Only the specified zones must be visited
+	visited = make(map[string]bool)
+	require.NoError(t, client.FanOut(context.Background(), []string{"de-fra-1"},
+		func(_ context.Context, zone string) error {
+			mu.Lock()
+			visited[zone] = true
+			mu.Unlock()
+			return nil
+		}))
+	require.Equal(t, map[string]bool{"de-fra-1": true}, visited)
+}
+
+func TestClient_ListNetworkLoadBalancersInZones(t *testing.T) {
+	client, mockClient := newTestMultiZoneClient(t)
+
+	for _, zone := range []string{"ch-gva-2", "de-fra-1"} {
+		zone := zone
+		mockClient.RegisterResponder("GET", "https://api-"+zone+".exoscale.com/v2.alpha/load-balancer",
+			func(req *http.Request) (*http.Response, error) {
+				resp, err := httpmock.NewJsonResponse(http.StatusOK, struct {
+					LoadBalancers *[]v2.LoadBalancer `json:"load-balancers,omitempty"`
+				}{
+					LoadBalancers: &[]v2.LoadBalancer{{
+						Id:        &testNLBID,
+						Name:      &zone,
+						CreatedAt: &testNLBCreatedAt,
+					}},
+				})
+				if err != nil {
+					t.Fatalf("error initializing mock HTTP responder: %s", err)
+				}
+				return resp, nil
+			})
+	}
+
+	mockClient.RegisterResponder("GET", "https://api-ch-dk-2.exoscale.com/v2.alpha/load-balancer",
+		httpmock.NewStringResponder(http.StatusInternalServerError, `{"message":"internal error"}`))
+
+	actual, err := client.ListNetworkLoadBalancersInZones(context.Background())
+	require.IsType(t, ZoneErrors{}, err)
+	require.Len(t, err.(ZoneErrors), 1)
+	require.Error(t, err.(ZoneErrors)["ch-dk-2"])
+	require.Len(t, actual, 2)
+
+	for _, zone := range []string{"ch-gva-2", "de-fra-1"} {
+		require.Len(t, actual[zone], 1)
+		require.Equal(t, zone, actual[zone][0].Name)
+	}
+}
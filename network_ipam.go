@@ -0,0 +1,288 @@
+package egoscale
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// ErrNoFreeIPAddress is returned when the DHCP range of a managed network is exhausted.
+var ErrNoFreeIPAddress = errors.New("no free IP address left in the network range")
+
+// ValidateManagedNetworkRange checks that the DHCP range (startIP to endIP) and the gateway (if
+// any) of a managed network fall inside the subnet defined by the netmask, excluding its network
+// and broadcast addresses, that the netmask is at most /30, and that the gateway lies outside of
+// the DHCP range.
+func ValidateManagedNetworkRange(startIP, endIP, netmask, gateway net.IP) error {
+	if err := checkNetworkRange(startIP, endIP, netmask); err != nil {
+		return err
+	}
+
+	mask := net.IPMask(netmask.To4())
+	if ones, _ := mask.Size(); ones > 30 {
+		return &ValidationError{Field: "Netmask", Reason: "must be at most /30"}
+	}
+
+	subnet := net.IPNet{IP: startIP.Mask(mask), Mask: mask}
+	first, last := ipv4ToUint32(subnet.IP)+1, ipv4ToUint32(subnet.IP)|^binary.BigEndian.Uint32(mask)-1
+	inSubnet := func(ip net.IP) bool {
+		n := ipv4ToUint32(ip)
+		return subnet.Contains(ip) && n >= first && n <= last
+	}
+
+	if !inSubnet(startIP) {
+		return &ValidationError{Field: "StartIP", Reason: fmt.Sprintf("%s is not a host address of %s", startIP, subnet.String())}
+	}
+	if !inSubnet(endIP) {
+		return &ValidationError{Field: "EndIP", Reason: fmt.Sprintf("%s is not a host address of %s", endIP, subnet.String())}
+	}
+
+	if gateway != nil {
+		if gateway.To4() == nil || !inSubnet(gateway) {
+			return &ValidationError{Field: "Gateway", Reason: fmt.Sprintf("%s is not a host address of %s", gateway, subnet.String())}
+		}
+		if n := ipv4ToUint32(gateway); n >= ipv4ToUint32(startIP) && n <= ipv4ToUint32(endIP) {
+			return &ValidationError{Field: "Gateway", Reason: fmt.Sprintf("%s is inside the DHCP range", gateway)}
+		}
+	}
+
+	return nil
+}
+
+// checkNetworkRange checks that the DHCP range (startIP to endIP) of a managed network is an
+// IPv4 range inside the subnet defined by the netmask.
+func checkNetworkRange(startIP, endIP, netmask net.IP) error {
+	if startIP.To4() == nil {
+		return &ValidationError{Field: "StartIP", Reason: "a valid IPv4 address is required"}
+	}
+	if endIP.To4() == nil {
+		return &ValidationError{Field: "EndIP", Reason: "a valid IPv4 address is required"}
+	}
+
+	mask := net.IPMask(netmask.To4())
+	if _, bits := mask.Size(); mask == nil || bits == 0 {
+		return &ValidationError{Field: "Netmask", Reason: "a valid IPv4 netmask is required"}
+	}
+
+	subnet := net.IPNet{IP: startIP.Mask(mask), Mask: mask}
+	if !subnet.Contains(endIP) {
+		return &ValidationError{Field: "EndIP", Reason: fmt.Sprintf("%s is outside of %s", endIP, subnet.String())}
+	}
+	if ipv4ToUint32(startIP) > ipv4ToUint32(endIP) {
+		return &ValidationError{Field: "EndIP", Reason: fmt.Sprintf("%s is lower than the start IP %s", endIP, startIP)}
+	}
+
+	return nil
+}
+
+// NetworkIPAMConflict represents a conflicting static IP address assignment.
+type NetworkIPAMConflict struct {
+	IPAddress net.IP
+	NicIDs    []UUID
+	Reason    string
+}
+
+// String returns a human-readable description of the conflict.
+func (c NetworkIPAMConflict) String() string {
+	return fmt.Sprintf("%s: %s (NIC %v)", c.IPAddress, c.Reason, c.NicIDs)
+}
+
+// NetworkIPAM tracks the IP addresses assigned in the DHCP range of a managed private network.
+type NetworkIPAM struct {
+	Network Network
+
+	// leases maps the IP addresses in use to the NICs they are assigned to
+	leases map[uint32][]UUID
+	// reserved indexes the IP addresses reserved but not yet assigned
+	reserved map[uint32]bool
+
+	c *Client
+}
+
+// NetworkIPAM returns the IPAM of a managed private network, loaded with the IP addresses of the
+// NICs attached to it.
+func (client *Client) NetworkIPAM(ctx context.Context, network Network) (*NetworkIPAM, error) {
+	if network.ID == nil {
+		return nil, &ValidationError{Field: "ID", Reason: "required"}
+	}
+	if err := ValidateManagedNetworkRange(network.StartIP, network.EndIP, network.Netmask, network.Gateway); err != nil {
+		return nil, err
+	}
+
+	nics, err := client.ListWithContext(ctx, &Nic{NetworkID: network.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	ipam := &NetworkIPAM{
+		Network:  network,
+		leases:   make(map[uint32][]UUID),
+		reserved: make(map[uint32]bool),
+		c:        client,
+	}
+	for _, item := range nics {
+		nic := item.(*Nic)
+		if nic.IPAddress.To4() != nil && nic.ID != nil {
+			n := ipv4ToUint32(nic.IPAddress)
+			ipam.leases[n] = append(ipam.leases[n], *nic.ID)
+		}
+	}
+
+	return ipam, nil
+}
+
+// inRange returns true if the IP address belongs to the DHCP range of the network.
+func (ipam *NetworkIPAM) inRange(ip net.IP) bool {
+	if ip.To4() == nil {
+		return false
+	}
+
+	n := ipv4ToUint32(ip)
+	return n >= ipv4ToUint32(ipam.Network.StartIP) && n <= ipv4ToUint32(ipam.Network.EndIP)
+}
+
+// IsFree returns true if the IP address belongs to the DHCP range of the network, and is neither
+// assigned nor reserved.
+func (ipam *NetworkIPAM) IsFree(ip net.IP) bool {
+	if !ipam.inRange(ip) {
+		return false
+	}
+
+	n := ipv4ToUint32(ip)
+	return len(ipam.leases[n]) == 0 && !ipam.reserved[n]
+}
+
+// Free returns the free IP addresses of the DHCP range of the network, in ascending order.
+func (ipam *NetworkIPAM) Free() []net.IP {
+	free := make([]net.IP, 0)
+
+	for n := ipv4ToUint32(ipam.Network.StartIP); n <= ipv4ToUint32(ipam.Network.EndIP); n++ {
+		if len(ipam.leases[n]) == 0 && !ipam.reserved[n] {
+			free = append(free, uint32ToIPv4(n))
+		}
+		if n == ^uint32(0) {
+			break
+		}
+	}
+
+	return free
+}
+
+// Reserve reserves the IP address, so that it is not handed out by the IPAM. If ip is nil, the
+// lowest free IP address is reserved. The IP address reserved is returned.
+func (ipam *NetworkIPAM) Reserve(ip net.IP) (net.IP, error) {
+	if ip == nil {
+		free := ipam.Free()
+		if len(free) == 0 {
+			return nil, ErrNoFreeIPAddress
+		}
+		ip = free[0]
+	}
+
+	if !ipam.inRange(ip) {
+		return nil, fmt.Errorf("%s is outside of the network range %s-%s", ip, ipam.Network.StartIP, ipam.Network.EndIP)
+	}
+	if !ipam.IsFree(ip) {
+		return nil, fmt.Errorf("%s is already in use", ip)
+	}
+
+	ipam.reserved[ipv4ToUint32(ip)] = true
+
+	return ip.To4(), nil
+}
+
+// Release releases an IP address previously reserved.
+func (ipam *NetworkIPAM) Release(ip net.IP) {
+	if ip.To4() != nil {
+		delete(ipam.reserved, ipv4ToUint32(ip))
+	}
+}
+
+// Assign assigns the IP address as a static lease to the NIC, which must be attached to the
+// network. If ip is nil, the lowest free IP address is assigned. The IP address must be free,
+// or reserved beforehand with Reserve. The IP address assigned is returned.
+func (ipam *NetworkIPAM) Assign(ctx context.Context, nicID *UUID, ip net.IP) (net.IP, error) {
+	if nicID == nil {
+		return nil, &ValidationError{Field: "NicID", Reason: "required"}
+	}
+	if ip != nil && ip.To4() == nil {
+		return nil, &ValidationError{Field: "IPAddress", Reason: "a valid IPv4 address is required"}
+	}
+
+	if ip == nil || !ipam.reserved[ipv4ToUint32(ip)] {
+		var err error
+		if ip, err = ipam.Reserve(ip); err != nil {
+			return nil, err
+		}
+	}
+	n := ipv4ToUint32(ip)
+
+	if _, err := ipam.c.RequestWithContext(ctx, &UpdateVMNicIP{NicID: nicID, IPAddress: ip}); err != nil {
+		ipam.Release(ip)
+		return nil, err
+	}
+
+	// The previous lease of the NIC, if any, is released
+	for lease, ids := range ipam.leases {
+		for i := range ids {
+			if ids[i].Equal(*nicID) {
+				ipam.leases[lease] = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ipam.leases[lease]) == 0 {
+			delete(ipam.leases, lease)
+		}
+	}
+
+	delete(ipam.reserved, n)
+	ipam.leases[n] = append(ipam.leases[n], *nicID)
+
+	return ip, nil
+}
+
+// Conflicts returns the conflicting static IP address assignments of the network: IP addresses
+// assigned to several NICs, to the gateway, or outside of the network DHCP range.
+func (ipam *NetworkIPAM) Conflicts() []NetworkIPAMConflict {
+	var gateway uint32
+	if ipam.Network.Gateway.To4() != nil {
+		gateway = ipv4ToUint32(ipam.Network.Gateway)
+	}
+
+	leases := make([]uint32, 0, len(ipam.leases))
+	for n := range ipam.leases {
+		leases = append(leases, n)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i] < leases[j] })
+
+	conflicts := make([]NetworkIPAMConflict, 0)
+	for _, n := range leases {
+		ip, ids := uint32ToIPv4(n), ipam.leases[n]
+
+		switch {
+		case len(ids) > 1:
+			conflicts = append(conflicts, NetworkIPAMConflict{IPAddress: ip, NicIDs: ids, Reason: "assigned to several NICs"})
+		case n == gateway:
+			conflicts = append(conflicts, NetworkIPAMConflict{IPAddress: ip, NicIDs: ids, Reason: "assigned to the gateway"})
+		case !ipam.inRange(ip):
+			conflicts = append(conflicts, NetworkIPAMConflict{IPAddress: ip, NicIDs: ids, Reason: "outside of the network range"})
+		}
+	}
+
+	return conflicts
+}
+
+// ipv4ToUint32 returns the integer representation of an IPv4 address.
+func ipv4ToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+// uint32ToIPv4 returns the IPv4 address of an integer representation.
+func uint32ToIPv4(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
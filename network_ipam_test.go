@@ -0,0 +1,163 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateManagedNetworkRange(t *testing.T) {
+	var (
+		start   = net.ParseIP("10.0.0.10")
+		end     = net.ParseIP("10.0.0.20")
+		netmask = net.ParseIP("255.255.255.0")
+	)
+
+	require.NoError(t, ValidateManagedNetworkRange(start, end, netmask, nil))
+	require.NoError(t, ValidateManagedNetworkRange(start, end, netmask, net.ParseIP("10.0.0.1")))
+
+	for _, tc := range []struct {
+		start, end, netmask, gateway string
+		err                          string
+	}{
+		{"", "10.0.0.20", "255.255.255.0", "", "invalid StartIP: a valid IPv4 address is required"},
+		{"10.0.0.10", "", "255.255.255.0", "", "invalid EndIP: a valid IPv4 address is required"},
+		{"10.0.0.10", "10.0.0.20", "", "", "invalid Netmask: a valid IPv4 netmask is required"},
+		{"10.0.0.10", "10.0.0.20", "255.0.255.0", "", "invalid Netmask: a valid IPv4 netmask is required"},
+		{"10.0.0.10", "10.0.0.10", "255.255.255.254", "", "invalid Netmask: must be at most /30"},
+		{"10.0.0.0", "10.0.0.20", "255.255.255.0", "", "invalid StartIP: 10.0.0.0 is not a host address of 10.0.0.0/24"},
+		{"10.0.0.10", "10.0.1.20", "255.255.255.0", "", "invalid EndIP: 10.0.1.20 is outside of 10.0.0.0/24"},
+		{"10.0.0.10", "10.0.0.255", "255.255.255.0", "", "invalid EndIP: 10.0.0.255 is not a host address of 10.0.0.0/24"},
+		{"10.0.0.20", "10.0.0.10", "255.255.255.0", "", "invalid EndIP: 10.0.0.10 is lower than the start IP 10.0.0.20"},
+		{"10.0.0.10", "10.0.0.20", "255.255.255.0", "192.168.0.1", "invalid Gateway: 192.168.0.1 is not a host address of 10.0.0.0/24"},
+		{"10.0.0.10", "10.0.0.20", "255.255.255.0", "10.0.0.15", "invalid Gateway: 10.0.0.15 is inside the DHCP range"},
+	} {
+		err := ValidateManagedNetworkRange(net.ParseIP(tc.start), net.ParseIP(tc.end),
+			net.ParseIP(tc.netmask), net.ParseIP(tc.gateway))
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+func TestCreateNetworkOnBeforeSendRange(t *testing.T) {
+	req := &CreateNetwork{
+		StartIP: net.ParseIP("10.0.0.10"),
+		EndIP:   net.ParseIP("10.0.0.20"),
+		Netmask: net.ParseIP("255.255.255.0"),
+	}
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	// The gateway is left to ValidateManagedNetworkRange
+	req.Gateway = net.ParseIP("10.0.0.12")
+	require.NoError(t, req.onBeforeSend(url.Values{}))
+
+	req.EndIP = net.ParseIP("10.0.1.20")
+	require.IsType(t, &ValidationError{}, req.onBeforeSend(url.Values{}))
+
+	// Partial ranges are left to the API
+	require.NoError(t, (&CreateNetwork{StartIP: net.ParseIP("10.0.0.10")}).onBeforeSend(url.Values{}))
+}
+
+func TestClient_NetworkIPAM(t *testing.T) {
+	var (
+		networkID = MustParseUUID("2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01")
+		nicID     = func(n int) *UUID { return MustParseUUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", n)) }
+	)
+
+	network := Network{
+		ID:      networkID,
+		StartIP: net.ParseIP("10.0.0.10"),
+		EndIP:   net.ParseIP("10.0.0.14"),
+		Netmask: net.ParseIP("255.255.255.0"),
+		Gateway: net.ParseIP("10.0.0.1"),
+	}
+
+	updated := func(n int, ip string) response {
+		return response{200, jsonContentType, fmt.Sprintf(`
+{"updatevmnicipresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"virtualmachine": {"nic": [{"id": "00000000-0000-0000-0000-%012d", "ipaddress": %q}]}},
+	"jobstatus": 1
+}}`, n, ip)}
+	}
+
+	ts := newServer(
+		response{200, jsonContentType, `
+{"listnicsresponse": {
+	"count": 6,
+	"nic": [
+		{"id": "00000000-0000-0000-0000-000000000001", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01", "ipaddress": "10.0.0.10"},
+		{"id": "00000000-0000-0000-0000-000000000002", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01", "ipaddress": "10.0.0.12"},
+		{"id": "00000000-0000-0000-0000-000000000003", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01", "ipaddress": "10.0.0.12"},
+		{"id": "00000000-0000-0000-0000-000000000004", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01", "ipaddress": "10.0.0.1"},
+		{"id": "00000000-0000-0000-0000-000000000005", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01", "ipaddress": "10.0.0.50"},
+		{"id": "00000000-0000-0000-0000-000000000006", "networkid": "2fa5a0b8-6a5b-4a49-a0e5-5b5e7c7b2c01"}
+	]
+}}`},
+		response{200, jsonContentType, `
+{"updatevmnicipresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"errorcode": 431, "errortext": "IP address already in use"},
+	"jobstatus": 2
+}}`},
+		updated(6, "10.0.0.14"),
+		updated(5, "10.0.0.11"),
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	ipam, err := client.NetworkIPAM(context.Background(), network)
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("10.0.0.11").To4(), net.ParseIP("10.0.0.13").To4(), net.ParseIP("10.0.0.14").To4()}, ipam.Free())
+	require.True(t, ipam.IsFree(net.ParseIP("10.0.0.11")))
+	require.False(t, ipam.IsFree(net.ParseIP("10.0.0.12")))
+	require.False(t, ipam.IsFree(net.ParseIP("10.0.0.50")))
+
+	require.Equal(t, []NetworkIPAMConflict{
+		{IPAddress: net.ParseIP("10.0.0.1").To4(), NicIDs: []UUID{*nicID(4)}, Reason: "assigned to the gateway"},
+		{IPAddress: net.ParseIP("10.0.0.12").To4(), NicIDs: []UUID{*nicID(2), *nicID(3)}, Reason: "assigned to several NICs"},
+		{IPAddress: net.ParseIP("10.0.0.50").To4(), NicIDs: []UUID{*nicID(5)}, Reason: "outside of the network range"},
+	}, ipam.Conflicts())
+
+	// Reserved IP addresses are not handed out
+	ip, err := ipam.Reserve(nil)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", ip.String())
+	_, err = ipam.Reserve(net.ParseIP("10.0.0.11"))
+	require.EqualError(t, err, "10.0.0.11 is already in use")
+	_, err = ipam.Reserve(net.ParseIP("10.0.0.20"))
+	require.EqualError(t, err, "10.0.0.20 is outside of the network range 10.0.0.10-10.0.0.14")
+
+	// The assignment failure releases the IP address
+	_, err = ipam.Assign(context.Background(), nicID(6), nil)
+	require.Error(t, err)
+	require.True(t, ipam.IsFree(net.ParseIP("10.0.0.13")))
+
+	// Only IPv4 addresses can be assigned
+	for _, invalid := range []net.IP{net.ParseIP("fd00::1"), {10, 0}} {
+		_, err = ipam.Assign(context.Background(), nicID(6), invalid)
+		require.IsType(t, &ValidationError{}, err)
+	}
+
+	ip, err = ipam.Assign(context.Background(), nicID(6), net.ParseIP("10.0.0.14"))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.14", ip.String())
+
+	// Assigning a reserved IP address, which releases the previous lease of the NIC
+	ip, err = ipam.Assign(context.Background(), nicID(5), net.ParseIP("10.0.0.11"))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", ip.String())
+	require.Len(t, ipam.Conflicts(), 2)
+
+	require.Equal(t, 4, ts.lastResponse)
+
+	require.Equal(t, []net.IP{net.ParseIP("10.0.0.13").To4()}, ipam.Free())
+	_, err = ipam.Reserve(nil)
+	require.NoError(t, err)
+	_, err = ipam.Reserve(nil)
+	require.Equal(t, ErrNoFreeIPAddress, err)
+}
@@ -133,6 +133,11 @@ func (req CreateNetwork) onBeforeSend(params url.Values) error {
 	if req.DisplayText == "" {
 		params.Set("displaytext", "")
 	}
+	// The DHCP range of managed networks is checked beforehand, see ValidateManagedNetworkRange
+	// for a thorough validation
+	if req.StartIP != nil && req.EndIP != nil && req.Netmask != nil {
+		return checkNetworkRange(req.StartIP, req.EndIP, req.Netmask)
+	}
 	return nil
 }
 
@@ -0,0 +1,239 @@
+package egoscale
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrPassphraseRequired represents an error indicating that a private key is passphrase-protected
+// and no passphrase was provided.
+var ErrPassphraseRequired = errors.New("private key is passphrase-protected")
+
+// Decrypt decrypts the password using the PEM encoded RSA private key of the SSH key pair the
+// virtual machine was deployed with. PKCS#1 ("RSA PRIVATE KEY"), PKCS#8 ("PRIVATE KEY") and
+// OpenSSH ("OPENSSH PRIVATE KEY") keys are supported; see DecryptWithPassphrase for
+// passphrase-protected keys.
+func (password Password) Decrypt(privateKey []byte) (string, error) {
+	return password.DecryptWithPassphrase(privateKey, nil)
+}
+
+// DecryptWithPassphrase decrypts the password using a PEM encoded RSA private key, protected by
+// the specified passphrase if any: PKCS#1 keys with the legacy PEM encryption ("Proc-Type:
+// 4,ENCRYPTED"), encrypted PKCS#8 keys ("ENCRYPTED PRIVATE KEY", PBES2 with AES-CBC) and
+// encrypted OpenSSH keys are supported. ErrPassphraseRequired is returned if the key is
+// encrypted and no passphrase is provided.
+func (password Password) DecryptWithPassphrase(privateKey, passphrase []byte) (string, error) {
+	if password.EncryptedPassword == "" {
+		return "", errors.New("no encrypted password, the template may not be password-enabled")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(password.EncryptedPassword)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted password: %s", err)
+	}
+
+	key, err := parseRSAPrivateKey(privateKey, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := rsa.DecryptPKCS1v15(rand.Reader, key, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt password, the private key may not match the virtual machine key pair: %s", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// GetDecryptedVMPassword retrieves the password of a virtual machine and decrypts it using the
+// PEM encoded RSA private key of its SSH key pair (see Password.DecryptWithPassphrase), protected
+// by the specified passphrase if not nil.
+func (client *Client) GetDecryptedVMPassword(ctx context.Context, vmID *UUID, privateKey, passphrase []byte) (string, error) {
+	resp, err := client.RequestWithContext(ctx, &GetVMPassword{ID: vmID})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.(*Password).DecryptWithPassphrase(privateKey, passphrase)
+}
+
+// parseRSAPrivateKey parses the first private key of a PEM document.
+func parseRSAPrivateKey(data, passphrase []byte) (*rsa.PrivateKey, error) {
+	var block *pem.Block
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM encoded private key found")
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			break
+		}
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+
+		var der []byte
+		if der, err = decryptPKCS8PrivateKey(block.Bytes, passphrase); err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %s", err)
+		}
+
+		if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %s", err)
+		}
+	} else {
+		key, err = ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if len(passphrase) == 0 {
+				return nil, ErrPassphraseRequired
+			}
+
+			if key, err = ssh.ParseRawPrivateKeyWithPassphrase(pem.EncodeToMemory(block), passphrase); err != nil {
+				return nil, fmt.Errorf("unable to decrypt private key: %s", err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T, expected an RSA key", key)
+	}
+
+	return rsaKey, nil
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// pkcs8EncryptedPrivateKeyInfo represents an encrypted PKCS#8 private key (RFC 5208).
+type pkcs8EncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params represents the parameters of the PBES2 encryption scheme (RFC 8018).
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params represents the parameters of the PBKDF2 key derivation function (RFC 8018).
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8PrivateKey decrypts the DER encoded private key of an encrypted PKCS#8 document.
+// Only the PBES2 scheme with PBKDF2 and AES-CBC, the OpenSSL default, is supported.
+func decryptPKCS8PrivateKey(der, passphrase []byte) ([]byte, error) {
+	var info pkcs8EncryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption scheme %s, expected PBES2", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s, expected PBKDF2",
+			params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 pseudo-random function %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher %s, expected AES-CBC", params.EncryptionScheme.Algorithm)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, keyLen, prf))
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.EncryptedData) == 0 ||
+		len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted data")
+	}
+
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// A wrong passphrase is most likely detected by an invalid PKCS#7 padding.
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, x509.IncorrectPasswordError
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, x509.IncorrectPasswordError
+		}
+	}
+
+	return plaintext[:len(plaintext)-padding], nil
+}
@@ -0,0 +1,50 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// API wraps a ClientWithResponses to expose the Exoscale API V2 operations with idiomatic
+// signatures: the wrappers (generated in api.gen.go) check the API responses, wait for the
+// asynchronous operations to complete and return typed models. The operations return the
+// resource affected when it can be retrieved, its reference otherwise, and nothing but an error
+// for the deletions.
+//
+// The request bodies and optional parameters are still the oapi-codegen generated types, which
+// fields are pointers: no value-typed parameters are generated.
+type API struct {
+	Client *ClientWithResponses
+
+	// Timeout is the maximum duration to wait for an asynchronous operation to complete
+	// (default: no time out).
+	Timeout time.Duration
+
+	// PollInterval is the interval at which asynchronous operations are polled (default: 3s).
+	PollInterval time.Duration
+}
+
+// NewAPI returns an API instance wrapping the specified client.
+func NewAPI(client *ClientWithResponses) *API {
+	return &API{Client: client}
+}
+
+// wait waits for the specified operation to complete, and returns the reference to the resource
+// affected by the operation.
+func (a *API) wait(ctx context.Context, zone string, op *Operation) (*Reference, error) {
+	res, err := NewPoller().
+		WithInterval(a.PollInterval).
+		WithTimeout(a.Timeout).
+		Poll(ctx, a.Client.OperationPoller(zone, *op.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	ref, _ := res.(*Reference)
+	if ref == nil {
+		return nil, fmt.Errorf("operation %s completed without reference", *op.Id)
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,81 @@
+package v2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAPI(t *testing.T, server *FakeServer) *API {
+	client, err := NewClientWithResponses("", WithHTTPClient(server))
+	require.NoError(t, err)
+
+	api := NewAPI(client)
+	api.PollInterval = time.Millisecond
+
+	return api
+}
+
+func TestAPI(t *testing.T) {
+	var (
+		server = NewFakeServer(testFakeZone)
+		api    = newTestAPI(t, server)
+		ctx    = context.Background()
+	)
+
+	lb, err := api.CreateLoadBalancer(ctx, testFakeZone, CreateLoadBalancerJSONRequestBody{
+		Name: fakeString("test"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test", *lb.Name)
+
+	lb, err = api.UpdateLoadBalancer(ctx, testFakeZone, *lb.Id, UpdateLoadBalancerJSONRequestBody{
+		Description: fakeString("updated"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test", *lb.Name)
+	require.Equal(t, "updated", *lb.Description)
+
+	lbs, err := api.ListLoadBalancers(ctx, testFakeZone)
+	require.NoError(t, err)
+	require.Len(t, lbs, 1)
+
+	// Sub-resources operations return the resources affected, retrieved by their identifiers
+	port := int64(80)
+	lb, err = api.AddServiceToLoadBalancer(ctx, testFakeZone, *lb.Id, AddServiceToLoadBalancerJSONRequestBody{
+		Name:         fakeString("svc"),
+		Port:         &port,
+		TargetPort:   &port,
+		InstancePool: &Resource{Id: fakeString("6f9e9a5c-8a4b-4b5e-9a52-a2b1c8c7e5d1")},
+		Healthcheck:  &Healthcheck{Port: &port},
+	})
+	require.NoError(t, err)
+	require.Len(t, *lb.Services, 1)
+
+	svc, err := api.UpdateLoadBalancerService(ctx, testFakeZone, *lb.Id, *(*lb.Services)[0].Id,
+		UpdateLoadBalancerServiceJSONRequestBody{Description: fakeString("updated")})
+	require.NoError(t, err)
+	require.Equal(t, "updated", *svc.Description)
+
+	require.NoError(t, api.DeleteLoadBalancer(ctx, testFakeZone, *lb.Id))
+
+	_, err = api.GetLoadBalancer(ctx, testFakeZone, *lb.Id)
+	require.Equal(t, ErrNotFound, err)
+
+	// Empty lists must not be nil
+	sgs, err := api.ListSecurityGroups(ctx, testFakeZone)
+	require.NoError(t, err)
+	require.NotNil(t, sgs)
+	require.Empty(t, sgs)
+
+	// Failed operations must be reported
+	server.OperationSchedule = func(string) FakeOperationSchedule {
+		return FakeOperationSchedule{FailureReason: "nope"}
+	}
+	_, err = api.CreateSecurityGroup(ctx, testFakeZone, CreateSecurityGroupJSONRequestBody{
+		Name: fakeString("test"),
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound represents an error indicating a non-existent resource.
+var ErrNotFound = errors.New("resource not found")
+
+// ErrAlreadyExists represents an error indicating a resource conflicting with an existing one.
+var ErrAlreadyExists = errors.New("resource already exists")
+
+// APIError represents an unexpected response returned by the API.
+type APIError struct {
+	// StatusCode is the HTTP status code of the API response
+	StatusCode int
+	// Status is the HTTP status line of the API response
+	Status string
+	// Message is the error message returned by the API, if any
+	Message string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("unexpected response from API: %s: %s", e.Status, e.Message)
+	}
+
+	return fmt.Sprintf("unexpected response from API: %s", e.Status)
+}
+
+// NewAPIError returns the error corresponding to a non-successful API response: ErrNotFound for
+// a "404 Not Found" response, ErrAlreadyExists for a "409 Conflict" response, otherwise an
+// *APIError.
+func NewAPIError(statusCode int, status string, body []byte) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+
+	case http.StatusConflict:
+		return ErrAlreadyExists
+	}
+
+	var payload struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	return &APIError{
+		StatusCode: statusCode,
+		Status:     status,
+		Message:    payload.Message,
+	}
+}
@@ -0,0 +1,21 @@
+package v2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIError(t *testing.T) {
+	require.Equal(t, ErrNotFound, NewAPIError(http.StatusNotFound, "404 Not Found", nil))
+	require.Equal(t, ErrAlreadyExists, NewAPIError(http.StatusConflict, "409 Conflict", nil))
+
+	err := NewAPIError(http.StatusInternalServerError, "500 Internal Server Error", []byte(`{"message":"lolnope"}`))
+	require.IsType(t, &APIError{}, err)
+	require.Equal(t, http.StatusInternalServerError, err.(*APIError).StatusCode)
+	require.Equal(t, "unexpected response from API: 500 Internal Server Error: lolnope", err.Error())
+
+	err = NewAPIError(http.StatusBadGateway, "502 Bad Gateway", []byte("<html></html>"))
+	require.Equal(t, "unexpected response from API: 502 Bad Gateway", err.Error())
+}
@@ -0,0 +1,895 @@
+package v2
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	v2 "github.com/exoscale/egoscale/api/v2"
+)
+
+// FakeOperationSchedule represents the way an asynchronous operation of a FakeServer completes.
+type FakeOperationSchedule struct {
+	// Pending is the number of times the operation is reported "pending" before completing.
+	Pending int
+
+	// FailureReason, if not empty, makes the operation complete in the "failure" state with the
+	// specified reason instead of "success", in which case the requested change is not applied.
+	FailureReason string
+}
+
+// FakeServer is a stateful, in-memory implementation of the Exoscale API V2 intended for tests.
+// It implements load balancers (and their services), security groups (and their rules), instance
+// snapshots, instance types and zones.
+//
+// Mutating requests return an asynchronous operation, and the requested change is only applied
+// once the operation completes successfully, i.e. when it is polled after its schedule has
+// elapsed. Resources are not partitioned by zone, and the request host is ignored.
+//
+// A FakeServer can be used as the HTTP client of a ClientWithResponses (see WithHTTPClient), as
+// the Transport of an http.Client, or served over the network as an http.Handler.
+type FakeServer struct {
+	// APIKey and APISecret are the credentials the EXO2-HMAC-SHA256 signature of the requests
+	// "Authorization" header is verified against. If APIKey is empty, requests are not
+	// authenticated.
+	APIKey    string
+	APISecret string
+
+	// OperationSchedule returns the schedule of the asynchronous operation triggered by the
+	// specified command (e.g. "create-load-balancer"). If nil, operations complete successfully
+	// the first time they are polled.
+	OperationSchedule func(command string) FakeOperationSchedule
+
+	// Now returns the current time, used to timestamp resources and to check requests
+	// signature expiration (default: time.Now).
+	Now func() time.Time
+
+	mu             sync.Mutex
+	zones          []string
+	instanceTypes  map[string]*InstanceType
+	loadBalancers  map[string]*LoadBalancer
+	securityGroups map[string]*SecurityGroup
+	snapshots      map[string]*Snapshot
+	exports        map[string]*SnapshotExport
+	operations     map[string]*fakeOperation
+	lbIPs          int
+}
+
+type fakeOperation struct {
+	Operation
+
+	schedule FakeOperationSchedule
+	polls    int
+
+	// apply applies the change requested, it is invoked with the server lock held.
+	apply func() error
+}
+
+// fakeRequest represents a request being handled by a FakeServer.
+type fakeRequest struct {
+	*http.Request
+
+	body []byte
+	path []string
+}
+
+// NewFakeServer returns a new FakeServer instance reporting the specified zones as available.
+func NewFakeServer(zones ...string) *FakeServer {
+	return &FakeServer{
+		zones:          zones,
+		instanceTypes:  make(map[string]*InstanceType),
+		loadBalancers:  make(map[string]*LoadBalancer),
+		securityGroups: make(map[string]*SecurityGroup),
+		snapshots:      make(map[string]*Snapshot),
+		exports:        make(map[string]*SnapshotExport),
+		operations:     make(map[string]*fakeOperation),
+	}
+}
+
+// AddInstanceType adds an instance type to the server, and returns its ID.
+func (s *FakeServer) AddInstanceType(t InstanceType) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Id == nil {
+		t.Id = fakeID()
+	}
+	s.instanceTypes[*t.Id] = &t
+
+	return *t.Id
+}
+
+// AddLoadBalancer adds a load balancer to the server, and returns its ID.
+// The IDs of the load balancer services are assigned if not set.
+func (s *FakeServer) AddLoadBalancer(lb LoadBalancer) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lb.Id == nil {
+		lb.Id = fakeID()
+	}
+	if lb.CreatedAt == nil {
+		lb.CreatedAt = s.now()
+	}
+
+	services := make([]LoadBalancerService, 0)
+	if lb.Services != nil {
+		services = append(services, *lb.Services...)
+	}
+	for i := range services {
+		if services[i].Id == nil {
+			services[i].Id = fakeID()
+		}
+	}
+	lb.Services = &services
+
+	s.loadBalancers[*lb.Id] = &lb
+
+	return *lb.Id
+}
+
+// AddSecurityGroup adds a security group to the server, and returns its ID.
+// The IDs of the security group rules are assigned if not set.
+func (s *FakeServer) AddSecurityGroup(sg SecurityGroup) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sg.Id == nil {
+		sg.Id = fakeID()
+	}
+
+	rules := make([]SecurityGroupRule, 0)
+	if sg.Rules != nil {
+		rules = append(rules, *sg.Rules...)
+	}
+	for i := range rules {
+		if rules[i].Id == nil {
+			rules[i].Id = fakeID()
+		}
+	}
+	sg.Rules = &rules
+
+	s.securityGroups[*sg.Id] = &sg
+
+	return *sg.Id
+}
+
+// AddSnapshot adds an instance snapshot to the server, and returns its ID.
+func (s *FakeServer) AddSnapshot(snapshot Snapshot) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snapshot.Id == nil {
+		snapshot.Id = fakeID()
+	}
+	if snapshot.CreatedAt == nil {
+		snapshot.CreatedAt = s.now()
+	}
+	s.snapshots[*snapshot.Id] = &snapshot
+
+	return *snapshot.Id
+}
+
+// Do implements the HttpRequestDoer interface.
+func (s *FakeServer) Do(req *http.Request) (*http.Response, error) {
+	return s.RoundTrip(req)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (s *FakeServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *FakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r := fakeRequest{Request: req}
+
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			fakeError(w, http.StatusBadRequest, "unable to read request body: %s", err)
+			return
+		}
+		r.body = data
+	}
+
+	if err := s.authenticate(&r); err != nil {
+		fakeError(w, http.StatusForbidden, "%s", err)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	path = strings.TrimPrefix(path, v2.APIPrefix+"/")
+	r.path = strings.Split(strings.TrimSuffix(path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		status = http.StatusOK
+		res    interface{}
+		err    error
+	)
+
+	switch r.path[0] {
+	case "zone":
+		res, err = s.handleZones(&r)
+	case "instance-type":
+		res, err = s.handleInstanceTypes(&r)
+	case "load-balancer":
+		res, err = s.handleLoadBalancers(&r)
+	case "security-group":
+		res, err = s.handleSecurityGroups(&r)
+	case "instance", "snapshot":
+		res, err = s.handleSnapshots(&r)
+	case "operation":
+		res, err = s.handleOperations(&r)
+	default:
+		err = errFakeNotFound
+	}
+	if err != nil {
+		status = http.StatusBadRequest
+		if fe, ok := err.(*fakeHTTPError); ok {
+			status = fe.status
+		}
+		fakeError(w, status, "%s", err)
+		return
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		fakeError(w, http.StatusInternalServerError, "unable to marshal response: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func (s *FakeServer) handleZones(r *fakeRequest) (interface{}, error) {
+	if len(r.path) != 1 || r.Method != http.MethodGet {
+		return nil, errFakeNotFound
+	}
+
+	zones := make([]Zone, len(s.zones))
+	for i := range s.zones {
+		zones[i] = Zone{Name: &s.zones[i]}
+	}
+
+	return struct {
+		Zones []Zone `json:"zones"`
+	}{zones}, nil
+}
+
+func (s *FakeServer) handleInstanceTypes(r *fakeRequest) (interface{}, error) {
+	if r.Method != http.MethodGet {
+		return nil, errFakeNotFound
+	}
+
+	switch len(r.path) {
+	case 1:
+		instanceTypes := make([]InstanceType, 0, len(s.instanceTypes))
+		for _, id := range fakeSortedIDs(s.instanceTypes) {
+			instanceTypes = append(instanceTypes, *s.instanceTypes[id])
+		}
+
+		return struct {
+			InstanceTypes []InstanceType `json:"instance-types"`
+		}{instanceTypes}, nil
+
+	case 2:
+		if t, ok := s.instanceTypes[r.path[1]]; ok {
+			return t, nil
+		}
+	}
+
+	return nil, errFakeNotFound
+}
+
+func (s *FakeServer) handleLoadBalancers(r *fakeRequest) (interface{}, error) {
+	var lb *LoadBalancer
+
+	if len(r.path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			loadBalancers := make([]*LoadBalancer, 0, len(s.loadBalancers))
+			for _, id := range fakeSortedIDs(s.loadBalancers) {
+				loadBalancers = append(loadBalancers, s.loadBalancers[id])
+			}
+
+			return struct {
+				LoadBalancers []*LoadBalancer `json:"load-balancers"`
+			}{loadBalancers}, nil
+
+		case http.MethodPost:
+			var body CreateLoadBalancerJSONBody
+			if err := r.decode(&body); err != nil {
+				return nil, err
+			}
+			if body.Name == nil || *body.Name == "" {
+				return nil, fakeHTTPErrorf(http.StatusBadRequest, "missing name")
+			}
+
+			id := *fakeID()
+			return s.newOperation("create-load-balancer", id, "/load-balancer/"+id, func() error {
+				s.lbIPs++
+				s.loadBalancers[id] = &LoadBalancer{
+					Id:          &id,
+					Name:        body.Name,
+					Description: body.Description,
+					CreatedAt:   s.now(),
+					Ip:          fakeString(fmt.Sprintf("192.0.2.%d", s.lbIPs)),
+					State:       fakeString("running"),
+					Services:    &[]LoadBalancerService{},
+				}
+				return nil
+			}), nil
+		}
+
+		return nil, errFakeNotFound
+	}
+
+	lbID := r.path[1]
+	if lb = s.loadBalancers[lbID]; lb == nil {
+		return nil, errFakeNotFound
+	}
+	lbLink := "/load-balancer/" + lbID
+
+	if len(r.path) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			return lb, nil
+
+		case http.MethodPut:
+			var body UpdateLoadBalancerJSONBody
+			if err := r.decode(&body); err != nil {
+				return nil, err
+			}
+
+			return s.newOperation("update-load-balancer", lbID, lbLink, func() error {
+				if lb = s.loadBalancers[lbID]; lb == nil {
+					return errFakeNotFound
+				}
+				if body.Name != nil {
+					lb.Name = body.Name
+				}
+				if body.Description != nil {
+					lb.Description = body.Description
+				}
+				return nil
+			}), nil
+
+		case http.MethodDelete:
+			return s.newOperation("delete-load-balancer", lbID, lbLink, func() error {
+				if _, ok := s.loadBalancers[lbID]; !ok {
+					return errFakeNotFound
+				}
+				delete(s.loadBalancers, lbID)
+				return nil
+			}), nil
+		}
+
+		return nil, errFakeNotFound
+	}
+
+	if r.path[2] != "service" {
+		return nil, errFakeNotFound
+	}
+
+	if len(r.path) == 3 && r.Method == http.MethodPost {
+		var body AddServiceToLoadBalancerJSONBody
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		if body.Name == nil || *body.Name == "" {
+			return nil, fakeHTTPErrorf(http.StatusBadRequest, "missing name")
+		}
+
+		svcID := *fakeID()
+		return s.newOperation("add-service-to-load-balancer", svcID, lbLink+"/service/"+svcID,
+			func() error {
+				if lb = s.loadBalancers[lbID]; lb == nil {
+					return errFakeNotFound
+				}
+
+				svc := LoadBalancerService(body)
+				svc.Id = &svcID
+				svc.State = fakeString("running")
+				svc.HealthcheckStatus = &[]LoadBalancerServerStatus{}
+				*lb.Services = append(*lb.Services, svc)
+				return nil
+			}), nil
+	}
+
+	if len(r.path) != 4 {
+		return nil, errFakeNotFound
+	}
+
+	svcID := r.path[3]
+	svcLink := lbLink + "/service/" + svcID
+	findService := func() (*LoadBalancerService, int) {
+		if lb = s.loadBalancers[lbID]; lb != nil {
+			for i, svc := range *lb.Services {
+				if *svc.Id == svcID {
+					return &(*lb.Services)[i], i
+				}
+			}
+		}
+		return nil, -1
+	}
+
+	svc, _ := findService()
+	if svc == nil {
+		return nil, errFakeNotFound
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return svc, nil
+
+	case http.MethodPut:
+		var body UpdateLoadBalancerServiceJSONBody
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+
+		return s.newOperation("update-load-balancer-service", svcID, svcLink, func() error {
+			svc, _ := findService()
+			if svc == nil {
+				return errFakeNotFound
+			}
+			if body.Name != nil {
+				svc.Name = body.Name
+			}
+			if body.Description != nil {
+				svc.Description = body.Description
+			}
+			if body.Healthcheck != nil {
+				svc.Healthcheck = body.Healthcheck
+			}
+			if body.Port != nil {
+				svc.Port = body.Port
+			}
+			if body.Protocol != nil {
+				svc.Protocol = body.Protocol
+			}
+			if body.Strategy != nil {
+				svc.Strategy = body.Strategy
+			}
+			if body.TargetPort != nil {
+				svc.TargetPort = body.TargetPort
+			}
+			return nil
+		}), nil
+
+	case http.MethodDelete:
+		return s.newOperation("delete-load-balancer-service", svcID, svcLink, func() error {
+			_, i := findService()
+			if i < 0 {
+				return errFakeNotFound
+			}
+			*lb.Services = append((*lb.Services)[:i], (*lb.Services)[i+1:]...)
+			return nil
+		}), nil
+	}
+
+	return nil, errFakeNotFound
+}
+
+func (s *FakeServer) handleSecurityGroups(r *fakeRequest) (interface{}, error) {
+	var sg *SecurityGroup
+
+	if len(r.path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			securityGroups := make([]SecurityGroup, 0, len(s.securityGroups))
+			for _, id := range fakeSortedIDs(s.securityGroups) {
+				securityGroups = append(securityGroups, *s.securityGroups[id])
+			}
+
+			return struct {
+				SecurityGroups []SecurityGroup `json:"security-groups"`
+			}{securityGroups}, nil
+
+		case http.MethodPost:
+			var body CreateSecurityGroupJSONBody
+			if err := r.decode(&body); err != nil {
+				return nil, err
+			}
+			if body.Name == nil || *body.Name == "" {
+				return nil, fakeHTTPErrorf(http.StatusBadRequest, "missing name")
+			}
+
+			id := *fakeID()
+			return s.newOperation("create-security-group", id, "/security-group/"+id, func() error {
+				for _, sg := range s.securityGroups {
+					if *sg.Name == *body.Name {
+						return fmt.Errorf("a security group named %q already exists", *body.Name)
+					}
+				}
+
+				s.securityGroups[id] = &SecurityGroup{
+					Id:          &id,
+					Name:        body.Name,
+					Description: body.Description,
+					Rules:       &[]SecurityGroupRule{},
+				}
+				return nil
+			}), nil
+		}
+
+		return nil, errFakeNotFound
+	}
+
+	sgID := r.path[1]
+	if sg = s.securityGroups[sgID]; sg == nil {
+		return nil, errFakeNotFound
+	}
+	sgLink := "/security-group/" + sgID
+
+	switch {
+	case len(r.path) == 2 && r.Method == http.MethodGet:
+		return sg, nil
+
+	case len(r.path) == 2 && r.Method == http.MethodDelete:
+		return s.newOperation("delete-security-group", sgID, sgLink, func() error {
+			if _, ok := s.securityGroups[sgID]; !ok {
+				return errFakeNotFound
+			}
+			delete(s.securityGroups, sgID)
+			return nil
+		}), nil
+
+	case len(r.path) == 3 && r.path[2] == "rules" && r.Method == http.MethodPost:
+		var body AddRuleToSecurityGroupJSONBody
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		if body.FlowDirection == nil || (*body.FlowDirection != "ingress" && *body.FlowDirection != "egress") {
+			return nil, fakeHTTPErrorf(http.StatusBadRequest, "invalid flow direction")
+		}
+		if (body.Network == nil) == (body.SecurityGroup == nil) {
+			return nil, fakeHTTPErrorf(http.StatusBadRequest, "exactly one of network or security group must be set")
+		}
+		if body.Network != nil {
+			if _, _, err := net.ParseCIDR(*body.Network); err != nil {
+				return nil, fakeHTTPErrorf(http.StatusBadRequest, "invalid network: %s", err)
+			}
+		}
+
+		ruleID := *fakeID()
+		return s.newOperation("add-rule-to-security-group", sgID, sgLink, func() error {
+			if sg = s.securityGroups[sgID]; sg == nil {
+				return errFakeNotFound
+			}
+
+			rule := SecurityGroupRule(body)
+			rule.Id = &ruleID
+			*sg.Rules = append(*sg.Rules, rule)
+			return nil
+		}), nil
+
+	case len(r.path) == 4 && r.path[2] == "rules" && r.Method == http.MethodDelete:
+		ruleID := r.path[3]
+		return s.newOperation("delete-rule-from-security-group", sgID, sgLink, func() error {
+			if sg = s.securityGroups[sgID]; sg != nil {
+				for i, rule := range *sg.Rules {
+					if *rule.Id == ruleID {
+						*sg.Rules = append((*sg.Rules)[:i], (*sg.Rules)[i+1:]...)
+						return nil
+					}
+				}
+			}
+			return errFakeNotFound
+		}), nil
+	}
+
+	return nil, errFakeNotFound
+}
+
+func (s *FakeServer) handleSnapshots(r *fakeRequest) (interface{}, error) {
+	if len(r.path) == 1 && r.path[0] == "snapshot" && r.Method == http.MethodGet {
+		snapshots := make([]*Snapshot, 0, len(s.snapshots))
+		for _, id := range fakeSortedIDs(s.snapshots) {
+			snapshots = append(snapshots, s.snapshots[id])
+		}
+
+		return struct {
+			Snapshots []*Snapshot `json:"snapshots"`
+		}{snapshots}, nil
+	}
+
+	if len(r.path) != 2 {
+		return nil, errFakeNotFound
+	}
+
+	id, action := r.path[1], ""
+	if i := strings.Index(id, ":"); i >= 0 {
+		id, action = id[:i], id[i+1:]
+	}
+
+	if r.path[0] == "instance" {
+		if action != "create-snapshot" || r.Method != http.MethodPost {
+			return nil, errFakeNotFound
+		}
+
+		instanceID := id
+		id = *fakeID()
+		return s.newOperation("create-snapshot", id, "/snapshot/"+id, func() error {
+			s.snapshots[id] = &Snapshot{
+				Id:        &id,
+				Name:      fakeString("snapshot-" + instanceID),
+				CreatedAt: s.now(),
+				Instance:  &Instance{Id: &instanceID},
+				State:     fakeString("exported"),
+			}
+			return nil
+		}), nil
+	}
+
+	snapshot, ok := s.snapshots[id]
+	if !ok {
+		return nil, errFakeNotFound
+	}
+	link := "/snapshot/" + id
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		return snapshot, nil
+
+	case action == "" && r.Method == http.MethodDelete:
+		return s.newOperation("delete-snapshot", id, link, func() error {
+			if _, ok := s.snapshots[id]; !ok {
+				return errFakeNotFound
+			}
+			delete(s.snapshots, id)
+			delete(s.exports, id)
+			return nil
+		}), nil
+
+	case action == "export" && r.Method == http.MethodGet:
+		if export, ok := s.exports[id]; ok {
+			return export, nil
+		}
+		return nil, errFakeNotFound
+
+	case action == "export" && r.Method == http.MethodPost:
+		return s.newOperation("export-snapshot", id, link, func() error {
+			if _, ok := s.snapshots[id]; !ok {
+				return errFakeNotFound
+			}
+			s.exports[id] = &SnapshotExport{
+				Id:           &id,
+				PresignedUrl: fakeString("https://sos.example.net/snapshots/" + id),
+				Md5sum:       fakeString(fmt.Sprintf("%x", sha256.Sum256([]byte(id)))[:32]),
+			}
+			return nil
+		}), nil
+	}
+
+	return nil, errFakeNotFound
+}
+
+func (s *FakeServer) handleOperations(r *fakeRequest) (interface{}, error) {
+	if len(r.path) != 2 || r.Method != http.MethodGet {
+		return nil, errFakeNotFound
+	}
+
+	op, ok := s.operations[r.path[1]]
+	if !ok {
+		return nil, errFakeNotFound
+	}
+
+	if *op.State != operationStatePending {
+		return &op.Operation, nil
+	}
+
+	if op.polls < op.schedule.Pending {
+		op.polls++
+		return &op.Operation, nil
+	}
+
+	if err := op.apply(); err != nil {
+		op.State = fakeString(operationStateFailure)
+		op.Reason = fakeString(err.Error())
+	} else {
+		op.State = fakeString(operationStateSuccess)
+	}
+
+	return &op.Operation, nil
+}
+
+// newOperation registers a new pending asynchronous operation, which applies the specified
+// function upon successful completion.
+func (s *FakeServer) newOperation(command, refID, link string, apply func() error) *Operation {
+	op := fakeOperation{
+		Operation: Operation{
+			Id:    fakeID(),
+			State: fakeString(operationStatePending),
+			Reference: &Reference{
+				Command: &command,
+				Id:      &refID,
+				Link:    fakeString("/" + v2.APIPrefix + link),
+			},
+		},
+	}
+
+	if s.OperationSchedule != nil {
+		op.schedule = s.OperationSchedule(command)
+	}
+
+	op.apply = func() error {
+		if op.schedule.FailureReason != "" {
+			return errors.New(op.schedule.FailureReason)
+		}
+		return apply()
+	}
+
+	s.operations[*op.Id] = &op
+
+	return &op.Operation
+}
+
+// authenticate verifies the EXO2-HMAC-SHA256 signature of the request "Authorization" header.
+func (s *FakeServer) authenticate(r *fakeRequest) error {
+	if s.APIKey == "" {
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "EXO2-HMAC-SHA256 ") {
+		return errors.New("missing or invalid Authorization header")
+	}
+
+	pragmas := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "EXO2-HMAC-SHA256 "), ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid Authorization header pragma %q", part)
+		}
+		pragmas[kv[0]] = kv[1]
+	}
+
+	if pragmas["credential"] != s.APIKey {
+		return errors.New("invalid credential")
+	}
+
+	expires, err := strconv.ParseInt(pragmas["expires"], 10, 64)
+	if err != nil {
+		return errors.New("invalid expiration date")
+	}
+	if time.Unix(expires, 0).Before(*s.now()) {
+		return errors.New("request expired")
+	}
+
+	var params string
+	if signed := pragmas["signed-query-args"]; signed != "" {
+		query := r.URL.Query()
+		for _, param := range strings.Split(signed, ";") {
+			params += query.Get(param)
+		}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(pragmas["signature"])
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+
+	h := hmac.New(sha256.New, []byte(s.APISecret))
+	_, _ = h.Write([]byte(strings.Join([]string{
+		fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+		string(r.body),
+		params,
+		"",
+		pragmas["expires"],
+	}, "\n")))
+	if !hmac.Equal(signature, h.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+func (s *FakeServer) now() *time.Time {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	now = now.UTC().Truncate(time.Second)
+
+	return &now
+}
+
+// decode decodes the JSON request body into v.
+func (r *fakeRequest) decode(v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(r.body))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fakeHTTPErrorf(http.StatusBadRequest, "invalid request body: %s", err)
+	}
+
+	return nil
+}
+
+type fakeHTTPError struct {
+	status  int
+	message string
+}
+
+func (e *fakeHTTPError) Error() string { return e.message }
+
+func fakeHTTPErrorf(status int, format string, a ...interface{}) error {
+	return &fakeHTTPError{status: status, message: fmt.Sprintf(format, a...)}
+}
+
+var errFakeNotFound = fakeHTTPErrorf(http.StatusNotFound, "not found")
+
+func fakeError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	data, _ := json.Marshal(struct {
+		Message string `json:"message"`
+	}{fmt.Sprintf(format, a...)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func fakeID() *string {
+	id := uuid.Must(uuid.NewV4()).String()
+	return &id
+}
+
+func fakeString(v string) *string {
+	return &v
+}
+
+// fakeSortedIDs returns the keys of a map indexed by resource ID in lexical order, so that
+// listings are deterministic.
+func fakeSortedIDs(m interface{}) []string {
+	var ids []string
+
+	switch m := m.(type) {
+	case map[string]*InstanceType:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*LoadBalancer:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*SecurityGroup:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*Snapshot:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
@@ -0,0 +1,268 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/exoscale/egoscale/api/v2"
+)
+
+const (
+	testFakeAPIKey    = "EXOxxxxxxxxxxxxxxxxxxxxxxxx"
+	testFakeAPISecret = "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX"
+	testFakeZone      = "ch-gva-2"
+)
+
+func newTestFakeClient(t *testing.T, server *FakeServer, apiKey, apiSecret string) *ClientWithResponses {
+	sp, err := v2.NewSecurityProviderExoscale(apiKey, apiSecret)
+	require.NoError(t, err)
+
+	client, err := NewClientWithResponses("https://api.exoscale.com/"+v2.APIPrefix+"/",
+		WithHTTPClient(server),
+		WithRequestEditorFn(sp.Intercept))
+	require.NoError(t, err)
+
+	return client
+}
+
+// testFakeWait waits for the completion of the specified operation, and returns the resource
+// it references.
+func testFakeWait(client *ClientWithResponses, op *Operation) (*Reference, error) {
+	res, err := NewPoller().
+		WithInterval(time.Millisecond).
+		Poll(context.Background(), client.OperationPoller(testFakeZone, *op.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*Reference), nil
+}
+
+func TestFakeServer_authenticate(t *testing.T) {
+	server := NewFakeServer(testFakeZone)
+	server.APIKey = testFakeAPIKey
+	server.APISecret = testFakeAPISecret
+
+	resp, err := newTestFakeClient(t, server, testFakeAPIKey, testFakeAPISecret).
+		ListZonesWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Equal(t, testFakeZone, *(*resp.JSON200.Zones)[0].Name)
+
+	resp, err = newTestFakeClient(t, server, testFakeAPIKey, "lolnope").
+		ListZonesWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	resp, err = newTestFakeClient(t, server, "EXOlolnope", testFakeAPISecret).
+		ListZonesWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// Unsigned requests must be rejected
+	client, err := NewClientWithResponses("", WithHTTPClient(server))
+	require.NoError(t, err)
+	resp, err = client.ListZonesWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// Expired requests must be rejected
+	server.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	resp, err = newTestFakeClient(t, server, testFakeAPIKey, testFakeAPISecret).
+		ListZonesWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode())
+}
+
+func TestFakeServer_LoadBalancers(t *testing.T) {
+	var (
+		server = NewFakeServer(testFakeZone)
+		client = newTestFakeClient(t, server, "x", "x")
+		ctx    = context.Background()
+	)
+
+	createResp, err := client.CreateLoadBalancerWithResponse(ctx, CreateLoadBalancerJSONRequestBody{
+		Name: fakeString("test"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, createResp.StatusCode())
+	require.Equal(t, operationStatePending, *createResp.JSON200.State)
+
+	// The load balancer must not exist until the operation completes
+	listResp, err := client.ListLoadBalancersWithResponse(ctx)
+	require.NoError(t, err)
+	require.Empty(t, *listResp.JSON200.LoadBalancers)
+
+	ref, err := testFakeWait(client, createResp.JSON200)
+	require.NoError(t, err)
+	lbID := *ref.Id
+
+	getResp, err := client.GetLoadBalancerWithResponse(ctx, lbID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, getResp.StatusCode())
+	require.Equal(t, "test", *getResp.JSON200.Name)
+	require.Equal(t, "192.0.2.1", *getResp.JSON200.Ip)
+	require.NotNil(t, getResp.JSON200.CreatedAt)
+
+	addResp, err := client.AddServiceToLoadBalancerWithResponse(ctx, lbID, AddServiceToLoadBalancerJSONRequestBody{
+		Name:         fakeString("http"),
+		Port:         func() *int64 { v := int64(80); return &v }(),
+		InstancePool: &Resource{Id: fakeString("pool")},
+		Healthcheck:  &Healthcheck{Mode: fakeString("tcp")},
+	})
+	require.NoError(t, err)
+	ref, err = testFakeWait(client, addResp.JSON200)
+	require.NoError(t, err)
+	svcID := *ref.Id
+	require.Equal(t, "/"+v2.APIPrefix+"/load-balancer/"+lbID+"/service/"+svcID, *ref.Link)
+
+	updateResp, err := client.UpdateLoadBalancerServiceWithResponse(ctx, lbID, svcID,
+		UpdateLoadBalancerServiceJSONRequestBody{Description: fakeString("web")})
+	require.NoError(t, err)
+	_, err = testFakeWait(client, updateResp.JSON200)
+	require.NoError(t, err)
+
+	svcResp, err := client.GetLoadBalancerServiceWithResponse(ctx, lbID, svcID)
+	require.NoError(t, err)
+	require.Equal(t, "http", *svcResp.JSON200.Name)
+	require.Equal(t, "web", *svcResp.JSON200.Description)
+	require.Equal(t, int64(80), *svcResp.JSON200.Port)
+
+	deleteSvcResp, err := client.DeleteLoadBalancerServiceWithResponse(ctx, lbID, svcID)
+	require.NoError(t, err)
+	_, err = testFakeWait(client, deleteSvcResp.JSON200)
+	require.NoError(t, err)
+
+	getResp, err = client.GetLoadBalancerWithResponse(ctx, lbID)
+	require.NoError(t, err)
+	require.Empty(t, *getResp.JSON200.Services)
+
+	deleteResp, err := client.DeleteLoadBalancerWithResponse(ctx, lbID)
+	require.NoError(t, err)
+	_, err = testFakeWait(client, deleteResp.JSON200)
+	require.NoError(t, err)
+
+	getResp, err = client.GetLoadBalancerWithResponse(ctx, lbID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, getResp.StatusCode())
+}
+
+func TestFakeServer_OperationSchedule(t *testing.T) {
+	var (
+		server = NewFakeServer(testFakeZone)
+		client = newTestFakeClient(t, server, "x", "x")
+		ctx    = context.Background()
+	)
+
+	server.OperationSchedule = func(command string) FakeOperationSchedule {
+		if command == "delete-security-group" {
+			return FakeOperationSchedule{Pending: 2, FailureReason: "security group in use"}
+		}
+		return FakeOperationSchedule{Pending: 1}
+	}
+
+	sgID := server.AddSecurityGroup(SecurityGroup{Name: fakeString("default")})
+
+	deleteResp, err := client.DeleteSecurityGroupWithResponse(ctx, sgID)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		opResp, err := client.GetOperationWithResponse(ctx, *deleteResp.JSON200.Id)
+		require.NoError(t, err)
+		require.Equal(t, operationStatePending, *opResp.JSON200.State)
+	}
+
+	opResp, err := client.GetOperationWithResponse(ctx, *deleteResp.JSON200.Id)
+	require.NoError(t, err)
+	require.Equal(t, operationStateFailure, *opResp.JSON200.State)
+	require.Equal(t, "security group in use", *opResp.JSON200.Reason)
+
+	// A failed operation must not apply the change
+	getResp, err := client.GetSecurityGroupWithResponse(ctx, sgID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, getResp.StatusCode())
+
+	addResp, err := client.AddRuleToSecurityGroupWithResponse(ctx, sgID, AddRuleToSecurityGroupJSONRequestBody{
+		FlowDirection: fakeString("ingress"),
+		Network:       fakeString("0.0.0.0/0"),
+		Protocol:      fakeString("tcp"),
+	})
+	require.NoError(t, err)
+	_, err = testFakeWait(client, addResp.JSON200)
+	require.NoError(t, err)
+
+	getResp, err = client.GetSecurityGroupWithResponse(ctx, sgID)
+	require.NoError(t, err)
+	require.Len(t, *getResp.JSON200.Rules, 1)
+	require.Equal(t, "0.0.0.0/0", *(*getResp.JSON200.Rules)[0].Network)
+
+	// Invalid rules must be rejected
+	addResp, err = client.AddRuleToSecurityGroupWithResponse(ctx, sgID, AddRuleToSecurityGroupJSONRequestBody{
+		FlowDirection: fakeString("sideways"),
+		Network:       fakeString("0.0.0.0/0"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, addResp.StatusCode())
+}
+
+func TestFakeServer_Snapshots(t *testing.T) {
+	var (
+		server = NewFakeServer(testFakeZone)
+		client = newTestFakeClient(t, server, "x", "x")
+		ctx    = context.Background()
+	)
+
+	createResp, err := client.CreateSnapshotWithResponse(ctx, "instance")
+	require.NoError(t, err)
+	ref, err := testFakeWait(client, createResp.JSON200)
+	require.NoError(t, err)
+	snapshotID := *ref.Id
+
+	getResp, err := client.GetSnapshotWithResponse(ctx, snapshotID)
+	require.NoError(t, err)
+	require.Equal(t, "instance", *getResp.JSON200.Instance.Id)
+
+	getExportResp, err := client.GetExportSnapshotWithResponse(ctx, snapshotID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, getExportResp.StatusCode())
+
+	exportResp, err := client.ExportSnapshotWithResponse(ctx, snapshotID)
+	require.NoError(t, err)
+	_, err = testFakeWait(client, exportResp.JSON200)
+	require.NoError(t, err)
+
+	getExportResp, err = client.GetExportSnapshotWithResponse(ctx, snapshotID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, getExportResp.StatusCode())
+	require.NotEmpty(t, *getExportResp.JSON200.PresignedUrl)
+
+	listResp, err := client.ListSnapshotsWithResponse(ctx)
+	require.NoError(t, err)
+	require.Len(t, *listResp.JSON200.Snapshots, 1)
+}
+
+func TestFakeServer_InstanceTypes(t *testing.T) {
+	var (
+		server = NewFakeServer(testFakeZone)
+		client = newTestFakeClient(t, server, "x", "x")
+		ctx    = context.Background()
+	)
+
+	id := server.AddInstanceType(InstanceType{Family: fakeString("standard"), Size: fakeString("medium")})
+
+	listResp, err := client.ListInstanceTypesWithResponse(ctx)
+	require.NoError(t, err)
+	require.Len(t, *listResp.JSON200.InstanceTypes, 1)
+
+	getResp, err := client.GetInstanceTypeWithResponse(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "medium", *getResp.JSON200.Size)
+
+	getResp, err = client.GetInstanceTypeWithResponse(ctx, "lolnope")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, getResp.StatusCode())
+}
@@ -0,0 +1,398 @@
+// A tool generating idiomatic wrappers around the oapi-codegen generated API V2 client.
+//
+// The wrappers are derived from the ClientWithResponsesInterface found in the generated client
+// source (itself generated from the OpenAPI specification), so that every new API endpoint gets
+// its wrapper as soon as the client is regenerated. The wrappers take the request bodies and
+// optional parameters as the generated client does, as structures with pointer fields.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var output = flag.String("o", "api.gen.go", "output file")
+
+// ignoredOperations lists the client operations not to generate a wrapper for.
+var ignoredOperations = map[string]bool{
+	// Used internally by the operations poller
+	"GetOperation": true,
+}
+
+// globalOperations lists the client operations not bound to a zone, whose wrapper doesn't take a
+// zone argument.
+var globalOperations = map[string]bool{
+	"ListZones": true,
+}
+
+const (
+	kindModel     = "model"
+	kindList      = "list"
+	kindOperation = "operation"
+)
+
+type param struct {
+	Name string
+	Type string
+	// Arg is the expression passing the parameter to the underlying client method
+	Arg string
+}
+
+type wrapper struct {
+	Name   string
+	Kind   string
+	Global bool
+	Params []param
+	// Result is the type returned by the wrapper
+	Result string
+	// ListField is the name of the response field holding the list items (list kind only)
+	ListField string
+	// Getter is the name of the wrapper used to retrieve the resource created or updated by an
+	// operation, if any (operation kind only)
+	Getter string
+	// GetterArgs is the expression passing the resource identifier(s) to the getter: the reference
+	// returned by the operation, or the operation's own arguments
+	GetterArgs string
+}
+
+type endpoint struct {
+	params   []param
+	response string
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: generate [-o output] <generated client source>")
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, flag.Arg(0), nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	endpoints, responses := inspect(file)
+
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	wrappers := make(map[string]*wrapper)
+	for _, name := range names {
+		if ignoredOperations[name] {
+			continue
+		}
+
+		e := endpoints[name]
+		w := wrapper{Name: name, Global: globalOperations[name], Params: e.params}
+
+		json200, ok := responses[e.response]
+		if !ok {
+			log.Printf("%s: unsupported response type, skipping", name)
+			continue
+		}
+
+		switch t := json200.(type) {
+		case *ast.StarExpr:
+			switch x := t.X.(type) {
+			case *ast.Ident:
+				if x.Name == "Operation" {
+					w.Kind = kindOperation
+					// Deleted resources can't be retrieved, only the error is returned
+					if !strings.HasPrefix(name, "Delete") {
+						w.Result = "*Reference"
+					}
+				} else {
+					w.Kind = kindModel
+					w.Result = "*" + x.Name
+				}
+
+			case *ast.StructType:
+				if len(x.Fields.List) != 1 || len(x.Fields.List[0].Names) != 1 {
+					log.Printf("%s: unsupported response structure, skipping", name)
+					continue
+				}
+				w.Kind = kindList
+				w.ListField = x.Fields.List[0].Names[0].Name
+				w.Result = strings.TrimPrefix(types.ExprString(x.Fields.List[0].Type), "*")
+			}
+		}
+		if w.Kind == "" {
+			log.Printf("%s: unsupported response type, skipping", name)
+			continue
+		}
+
+		wrappers[name] = &w
+	}
+
+	// Operations creating or updating a resource return the resource itself if it can be
+	// retrieved, either by the ID referenced by the operation or, for the operations updating a
+	// resource (e.g. UpdateLoadBalancer) or adding a sub-resource to it (e.g.
+	// AddServiceToLoadBalancer), by the identifiers passed to the operation.
+	for _, name := range names {
+		w, ok := wrappers[name]
+		if !ok || w.Kind != kindOperation || w.Result == "" {
+			continue
+		}
+
+		candidates := []struct {
+			name   string
+			byArgs bool
+		}{
+			{"Get" + name, false},
+			{"Get" + strings.TrimPrefix(name, "Create"), false},
+			{"Get" + strings.TrimPrefix(name, "Update"), true},
+		}
+		if i := strings.LastIndex(name, "To"); strings.HasPrefix(name, "Add") && i > 0 {
+			candidates = append(candidates, struct {
+				name   string
+				byArgs bool
+			}{"Get" + name[i+len("To"):], true})
+		}
+
+		for _, c := range candidates {
+			g, ok := wrappers[c.name]
+			if !ok || g.Kind != kindModel {
+				continue
+			}
+
+			if c.byArgs && sameParams(g.Params, stringParams(w.Params)) {
+				w.Getter, w.GetterArgs, w.Result = c.name, args(g.Params), g.Result
+				break
+			}
+			if len(g.Params) == 1 && g.Params[0].Type == "string" {
+				w.Getter, w.GetterArgs, w.Result = c.name, "*ref.Id", g.Result
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	list := make([]*wrapper, 0, len(wrappers))
+	for _, name := range names {
+		if w, ok := wrappers[name]; ok {
+			list = append(list, w)
+		}
+	}
+	if err := tmpl.Execute(&buf, list); err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("unable to format generated code: %s\n%s", err, buf.String())
+	}
+
+	if err := ioutil.WriteFile(*output, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stringParams returns the string parameters, i.e. the path parameters.
+func stringParams(params []param) []param {
+	var list []param
+	for _, p := range params {
+		if p.Type == "string" {
+			list = append(list, p)
+		}
+	}
+
+	return list
+}
+
+// sameParams returns true if both lists have the same parameters, in the same order.
+func sameParams(a, b []param) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+
+	return true
+}
+
+// args returns the expression passing the parameters to the underlying client method.
+func args(params []param) string {
+	var s []string
+	for _, p := range params {
+		s = append(s, p.Arg)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// inspect returns the endpoints of the ClientWithResponsesInterface interface indexed by
+// operation name, and the type of the JSON200 field of the responses structures indexed by
+// response type name.
+func inspect(file *ast.File) (map[string]endpoint, map[string]ast.Expr) {
+	var (
+		endpoints = make(map[string]endpoint)
+		responses = make(map[string]ast.Expr)
+	)
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+
+		switch t := spec.Type.(type) {
+		case *ast.InterfaceType:
+			if spec.Name.Name != "ClientWithResponsesInterface" {
+				return false
+			}
+
+			for _, m := range t.Methods.List {
+				name := m.Names[0].Name
+				if !strings.HasSuffix(name, "WithResponse") || strings.HasSuffix(name, "WithBodyWithResponse") {
+					continue
+				}
+				name = strings.TrimSuffix(name, "WithResponse")
+
+				fn := m.Type.(*ast.FuncType)
+				var e endpoint
+				for _, f := range fn.Params.List[1:] { // Skip context.Context parameter
+					typ := types.ExprString(f.Type)
+					for _, n := range f.Names {
+						p := param{Name: n.Name, Type: typ, Arg: n.Name}
+						// Pass optional parameters structures by value
+						if star, ok := f.Type.(*ast.StarExpr); ok {
+							p.Type = types.ExprString(star.X)
+							p.Arg = "&" + n.Name
+						}
+						e.params = append(e.params, p)
+					}
+				}
+				e.response = strings.TrimPrefix(types.ExprString(fn.Results.List[0].Type), "*")
+
+				endpoints[name] = e
+			}
+
+		case *ast.StructType:
+			for _, f := range t.Fields.List {
+				if len(f.Names) == 1 && f.Names[0].Name == "JSON200" {
+					responses[spec.Name.Name] = f.Type
+				}
+			}
+		}
+
+		return false
+	})
+
+	return endpoints, responses
+}
+
+var tmpl = template.Must(template.New("api").Funcs(template.FuncMap{
+	"params": func(w *wrapper) string {
+		var s []string
+		for _, p := range w.Params {
+			s = append(s, fmt.Sprintf("%s %s", p.Name, p.Type))
+		}
+		return strings.Join(s, ", ")
+	},
+	"args": func(w *wrapper) string {
+		return args(w.Params)
+	},
+}).Parse(`// Code generated by generate/main.go; DO NOT EDIT.
+
+package v2
+
+import (
+	"context"
+	"net/http"
+
+	v2 "github.com/exoscale/egoscale/api/v2"
+)
+{{ range . }}{{ if and (eq .Kind "operation") (not .Result) }}
+// {{ .Name }} calls the {{ .Name }} API operation in the specified zone and waits for it to complete.
+func (a *API) {{ .Name }}(ctx context.Context, zone string{{ with params . }}, {{ . }}{{ end }}) error {
+	resp, err := a.Client.{{ .Name }}WithResponse(v2.WithZone(ctx, zone){{ with args . }}, {{ . }}{{ end }})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return NewAPIError(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	_, err = a.wait(ctx, zone, resp.JSON200)
+	return err
+}
+{{ else if eq .Kind "operation" }}
+// {{ .Name }} calls the {{ .Name }} API operation in the specified zone, waits for it to complete
+// and returns the {{ if .Getter }}resource affected{{ else }}reference to the resource affected{{ end }}.
+func (a *API) {{ .Name }}(ctx context.Context, zone string{{ with params . }}, {{ . }}{{ end }}) ({{ .Result }}, error) {
+	resp, err := a.Client.{{ .Name }}WithResponse(v2.WithZone(ctx, zone){{ with args . }}, {{ . }}{{ end }})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, NewAPIError(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+{{ if and .Getter (ne .GetterArgs "*ref.Id") }}
+	if _, err := a.wait(ctx, zone, resp.JSON200); err != nil {
+		return nil, err
+	}
+
+	return a.{{ .Getter }}(ctx, zone, {{ .GetterArgs }})
+{{ else }}
+	ref, err := a.wait(ctx, zone, resp.JSON200)
+	if err != nil {
+		return nil, err
+	}
+{{ if .Getter }}
+	return a.{{ .Getter }}(ctx, zone, *ref.Id)
+{{ else }}
+	return ref, nil
+{{ end }}{{ end -}}
+}
+{{ else if eq .Kind "list" }}
+// {{ .Name }} calls the {{ .Name }} API operation{{ if not .Global }} in the specified zone{{ end }}.
+func (a *API) {{ .Name }}({{ template "params" . }}) ({{ .Result }}, error) {
+	resp, err := a.Client.{{ .Name }}WithResponse({{ template "args" . }})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, NewAPIError(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	if resp.JSON200.{{ .ListField }} == nil {
+		return {{ .Result }}{}, nil
+	}
+
+	return *resp.JSON200.{{ .ListField }}, nil
+}
+{{ else }}
+// {{ .Name }} calls the {{ .Name }} API operation{{ if not .Global }} in the specified zone{{ end }}.
+func (a *API) {{ .Name }}({{ template "params" . }}) ({{ .Result }}, error) {
+	resp, err := a.Client.{{ .Name }}WithResponse({{ template "args" . }})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, NewAPIError(resp.StatusCode(), resp.Status(), resp.Body)
+	}
+
+	return resp.JSON200, nil
+}
+{{ end }}{{ end }}
+{{- define "params" }}ctx context.Context{{ if not .Global }}, zone string{{ end }}{{ with params . }}, {{ . }}{{ end }}{{ end }}
+{{- define "args" }}{{ if .Global }}ctx{{ else }}v2.WithZone(ctx, zone){{ end }}{{ with args . }}, {{ . }}{{ end }}{{ end }}`))
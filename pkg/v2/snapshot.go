@@ -0,0 +1,62 @@
+package v2
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UnmarshalJSON unmarshals a Snapshot structure into a temporary structure whose "CreatedAt" field of type
+// string to be able to parse the original timestamp (ISO 8601) into a time.Time object, since json.Unmarshal()
+// only supports RFC 3339 format.
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var raw = struct {
+		CreatedAt   string    `json:"created-at,omitempty"`
+		Description *string   `json:"description,omitempty"`
+		Id          *string   `json:"id,omitempty"` // nolint:golint
+		Instance    *Instance `json:"instance,omitempty"`
+		Name        *string   `json:"name,omitempty"`
+		State       *string   `json:"state,omitempty"`
+	}{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	createdAt, err := time.Parse(iso8601Format, raw.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	s.CreatedAt = &createdAt
+	s.Description = raw.Description
+	s.Id = raw.Id
+	s.Instance = raw.Instance
+	s.Name = raw.Name
+	s.State = raw.State
+
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of a Snapshot structure after having formatted the CreatedAt field
+// in the original timestamp (ISO 8601), since time.MarshalJSON() only supports RFC 3339 format.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	var raw = struct {
+		CreatedAt   string    `json:"created-at,omitempty"`
+		Description *string   `json:"description,omitempty"`
+		Id          *string   `json:"id,omitempty"` // nolint:golint
+		Instance    *Instance `json:"instance,omitempty"`
+		Name        *string   `json:"name,omitempty"`
+		State       *string   `json:"state,omitempty"`
+	}{}
+
+	if s.CreatedAt != nil {
+		raw.CreatedAt = s.CreatedAt.Format(iso8601Format)
+	}
+	raw.Description = s.Description
+	raw.Id = s.Id
+	raw.Instance = s.Instance
+	raw.Name = s.Name
+	raw.State = s.State
+
+	return json.Marshal(raw)
+}
@@ -0,0 +1,85 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_UnmarshalJSON(t *testing.T) {
+	var (
+		testID           = "c0f306e7-21aa-4b0b-bafd-b86ed31bc2b8"
+		testName         = "test-snapshot"
+		testCreatedAt, _ = time.Parse(iso8601Format, "2020-08-12T11:12:36Z")
+		testInstanceID   = "8ec00b67-e7ff-4ce5-b6b9-85fc1e24d878"
+		testInstanceName = "test-instance"
+		testState        = "exported"
+		expected         = Snapshot{
+			CreatedAt: &testCreatedAt,
+			Id:        &testID,
+			Instance: &Instance{
+				Id:   &testInstanceID,
+				Name: &testInstanceName,
+			},
+			Name:  &testName,
+			State: &testState,
+		}
+
+		actual Snapshot
+
+		jsonSnapshot = `{
+  "id": "` + testID + `",
+  "name": "` + testName + `",
+  "created-at": "` + testCreatedAt.Format(iso8601Format) + `",
+  "instance": {
+    "id": "` + testInstanceID + `",
+    "name": "` + testInstanceName + `"
+  },
+  "state": "` + testState + `"
+}`
+	)
+
+	require.NoError(t, json.Unmarshal([]byte(jsonSnapshot), &actual))
+	require.Equal(t, expected, actual)
+}
+
+func TestSnapshot_MarshalJSON(t *testing.T) {
+	var (
+		testID           = "c0f306e7-21aa-4b0b-bafd-b86ed31bc2b8"
+		testName         = "test-snapshot"
+		testCreatedAt, _ = time.Parse(iso8601Format, "2020-08-12T11:12:36Z")
+		testInstanceID   = "8ec00b67-e7ff-4ce5-b6b9-85fc1e24d878"
+		testInstanceName = "test-instance"
+		testState        = "exported"
+
+		expected = []byte(`{` +
+			`"created-at":"` + testCreatedAt.Format(iso8601Format) + `",` +
+			`"id":"` + testID + `",` +
+			`"instance":{"id":"` + testInstanceID + `","name":"` + testInstanceName + `"},` +
+			`"name":"` + testName + `",` +
+			`"state":"` + testState + `"` +
+			`}`)
+	)
+
+	snapshot := Snapshot{
+		CreatedAt: &testCreatedAt,
+		Id:        &testID,
+		Instance: &Instance{
+			Id:   &testInstanceID,
+			Name: &testInstanceName,
+		},
+		Name:  &testName,
+		State: &testState,
+	}
+
+	actual, err := json.Marshal(&snapshot)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// A snapshot without creation date must be marshaled without it
+	actual, err = json.Marshal(&Snapshot{Id: &testID})
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"id":"`+testID+`"}`), actual)
+}
@@ -1,3 +1,4 @@
 package v2
 
 //go:generate oapi-codegen -generate types,client -package v2 -o v2.gen.go ../../public-api.openapi.json
+//go:generate go run generate/main.go -o api.gen.go v2.gen.go
@@ -0,0 +1,395 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// DefaultAdminPorts lists the TCP/UDP ports considered as administrative by the security exposure
+// analysis if none are specified: SSH and RDP.
+var DefaultAdminPorts = []uint16{22, 3389}
+
+// privateNetworks lists the IP ranges not reachable from the Internet.
+var privateNetworks = []*CIDR{
+	MustParseCIDR("10.0.0.0/8"),
+	MustParseCIDR("100.64.0.0/10"),
+	MustParseCIDR("127.0.0.0/8"),
+	MustParseCIDR("169.254.0.0/16"),
+	MustParseCIDR("172.16.0.0/12"),
+	MustParseCIDR("192.168.0.0/16"),
+	MustParseCIDR("::1/128"),
+	MustParseCIDR("fc00::/7"),
+	MustParseCIDR("fe80::/10"),
+}
+
+// SecurityExposureOptions represents the options of the security exposure analysis.
+type SecurityExposureOptions struct {
+	// AdminPorts lists the TCP/UDP ports flagged when open to the world (default: DefaultAdminPorts)
+	AdminPorts []uint16
+}
+
+// SecurityExposureReport represents the inbound exposure of the virtual machines of an account,
+// as allowed by their security groups.
+type SecurityExposureReport struct {
+	VirtualMachines []VirtualMachineExposure `json:"virtualmachine"`
+	// Findings lists the administrative ports open to the world
+	Findings []SecurityExposureFinding `json:"finding"`
+}
+
+// Public returns the virtual machines reachable from the Internet, and only their exposures
+// reachable from the Internet.
+func (r *SecurityExposureReport) Public() []VirtualMachineExposure {
+	public := make([]VirtualMachineExposure, 0)
+	for _, vm := range r.VirtualMachines {
+		inbound := make([]PortExposure, 0)
+		for _, e := range vm.Inbound {
+			if e.Public {
+				inbound = append(inbound, e)
+			}
+		}
+
+		if len(inbound) > 0 {
+			vm.Inbound = inbound
+			public = append(public, vm)
+		}
+	}
+
+	return public
+}
+
+// VirtualMachineExposure represents the inbound exposure of a virtual machine.
+type VirtualMachineExposure struct {
+	ID             *UUID          `json:"id"`
+	Name           string         `json:"name"`
+	IPAddresses    []net.IP       `json:"ipaddress,omitempty"`
+	SecurityGroups []string       `json:"securitygroup,omitempty"`
+	Inbound        []PortExposure `json:"inbound,omitempty"`
+}
+
+// PortExposure represents the sources allowed to reach a port range (TCP/UDP), an ICMP type and
+// code (ICMP/ICMPv6) or a whole protocol of a virtual machine.
+type PortExposure struct {
+	Protocol  string `json:"protocol"`
+	StartPort uint16 `json:"startport,omitempty"`
+	EndPort   uint16 `json:"endport,omitempty"`
+	IcmpType  uint8  `json:"icmptype,omitempty"`
+	IcmpCode  uint8  `json:"icmpcode,omitempty"`
+
+	// CIDRs lists the networks allowed
+	CIDRs []CIDR `json:"cidr,omitempty"`
+	// Peers lists the virtual machines allowed through a security group reference
+	Peers []SecurityGroupPeer `json:"peer,omitempty"`
+	// SecurityGroups lists the security groups granting the access
+	SecurityGroups []string `json:"securitygroup"`
+
+	// Public is true if any of the networks allowed is reachable from the Internet
+	Public bool `json:"public"`
+	// WorldOpen is true if the whole Internet is allowed, i.e. if the networks allowed cover
+	// 0.0.0.0/0 or ::/0
+	WorldOpen bool `json:"worldopen"`
+}
+
+// String returns the text notation of the protocol and ports exposed (e.g. "tcp/22").
+func (e PortExposure) String() string {
+	return securityGroupRuleProtocol(e.rule())
+}
+
+// Covers returns true if the exposure includes the specified TCP or UDP port. An empty protocol
+// stands for "tcp", the API default.
+func (e PortExposure) Covers(protocol string, port uint16) bool {
+	exposed := strings.ToLower(e.Protocol)
+	if exposed == "" {
+		exposed = "tcp"
+	}
+
+	switch exposed {
+	case "all":
+		return true
+	case strings.ToLower(protocol):
+		return e.StartPort <= port && port <= e.EndPort
+	}
+
+	return false
+}
+
+func (e PortExposure) rule() IngressRule {
+	return IngressRule{
+		Protocol:  e.Protocol,
+		StartPort: e.StartPort,
+		EndPort:   e.EndPort,
+		IcmpType:  e.IcmpType,
+		IcmpCode:  e.IcmpCode,
+	}
+}
+
+// SecurityGroupPeer represents a virtual machine allowed to reach another one as a member of a
+// security group referenced by a rule.
+type SecurityGroupPeer struct {
+	SecurityGroup      string   `json:"securitygroup"`
+	VirtualMachineID   *UUID    `json:"virtualmachineid"`
+	VirtualMachineName string   `json:"virtualmachinename"`
+	IPAddresses        []net.IP `json:"ipaddress,omitempty"`
+}
+
+// SecurityExposureFinding represents an administrative port of a virtual machine open to the world.
+type SecurityExposureFinding struct {
+	VirtualMachineID   *UUID    `json:"virtualmachineid"`
+	VirtualMachineName string   `json:"virtualmachinename"`
+	Protocol           string   `json:"protocol"`
+	Port               uint16   `json:"port"`
+	SecurityGroups     []string `json:"securitygroup"`
+}
+
+// String returns a human-readable description of the finding.
+func (f SecurityExposureFinding) String() string {
+	return fmt.Sprintf("%s: %s/%d open to the world (security groups: %s)",
+		f.VirtualMachineName, f.Protocol, f.Port, strings.Join(f.SecurityGroups, ", "))
+}
+
+// SecurityExposure lists the virtual machines and security groups of the account, and returns the
+// resulting inbound exposure analysis (see AnalyzeSecurityExposure).
+func (client *Client) SecurityExposure(ctx context.Context, opts SecurityExposureOptions) (*SecurityExposureReport, error) {
+	vms, err := client.ListWithContext(ctx, &VirtualMachine{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list virtual machines: %s", err)
+	}
+
+	sgs, err := client.ListWithContext(ctx, &SecurityGroup{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list security groups: %s", err)
+	}
+
+	virtualMachines := make([]VirtualMachine, len(vms))
+	for i := range vms {
+		virtualMachines[i] = *vms[i].(*VirtualMachine)
+	}
+
+	securityGroups := make([]SecurityGroup, len(sgs))
+	for i := range sgs {
+		securityGroups[i] = *sgs[i].(*SecurityGroup)
+	}
+
+	return AnalyzeSecurityExposure(virtualMachines, securityGroups, opts), nil
+}
+
+// AnalyzeSecurityExposure computes the inbound exposure of the virtual machines from the ingress
+// rules of the security groups they belong to. The security groups of the virtual machines are
+// matched by ID (or by name if unset) against the security groups specified, which carry the
+// rules. Rules referencing a security group are resolved into the virtual machines member of
+// that group, and rules sharing the same protocol and ports are merged into a single exposure.
+func AnalyzeSecurityExposure(vms []VirtualMachine, sgs []SecurityGroup, opts SecurityExposureOptions) *SecurityExposureReport {
+	adminPorts := opts.AdminPorts
+	if len(adminPorts) == 0 {
+		adminPorts = DefaultAdminPorts
+	}
+
+	byID := make(map[string]*SecurityGroup)
+	byName := make(map[string]*SecurityGroup)
+	for i := range sgs {
+		if sgs[i].ID != nil {
+			byID[sgs[i].ID.String()] = &sgs[i]
+		}
+		byName[sgs[i].Name] = &sgs[i]
+	}
+
+	lookup := func(sg SecurityGroup) *SecurityGroup {
+		if sg.ID != nil {
+			if found, ok := byID[sg.ID.String()]; ok {
+				return found
+			}
+		}
+		if found, ok := byName[sg.Name]; ok {
+			return found
+		}
+		return &sg
+	}
+
+	// Members of each security group, by name as referenced in the rules.
+	members := make(map[string][]SecurityGroupPeer)
+	for _, vm := range vms {
+		for _, sg := range vm.SecurityGroup {
+			name := lookup(sg).Name
+			members[name] = append(members[name], SecurityGroupPeer{
+				SecurityGroup:      name,
+				VirtualMachineID:   vm.ID,
+				VirtualMachineName: vm.Name,
+				IPAddresses:        virtualMachineIPAddresses(vm),
+			})
+		}
+	}
+
+	report := SecurityExposureReport{
+		VirtualMachines: make([]VirtualMachineExposure, 0, len(vms)),
+		Findings:        make([]SecurityExposureFinding, 0),
+	}
+
+	for _, vm := range vms {
+		exposure := VirtualMachineExposure{
+			ID:          vm.ID,
+			Name:        vm.Name,
+			IPAddresses: virtualMachineIPAddresses(vm),
+			Inbound:     make([]PortExposure, 0),
+		}
+
+		inbound := make(map[string]*PortExposure)
+		keys := make([]string, 0)
+
+		for _, s := range vm.SecurityGroup {
+			sg := lookup(s)
+			exposure.SecurityGroups = append(exposure.SecurityGroups, sg.Name)
+
+			for _, rule := range sg.IngressRule {
+				if rule.EndPort == 0 {
+					rule.EndPort = rule.StartPort
+				}
+
+				protocol := strings.ToLower(rule.Protocol)
+				if protocol == "" {
+					protocol = "tcp"
+				}
+
+				e := PortExposure{
+					Protocol:  protocol,
+					StartPort: rule.StartPort,
+					EndPort:   rule.EndPort,
+					IcmpType:  rule.IcmpType,
+					IcmpCode:  rule.IcmpCode,
+				}
+				key := e.String()
+
+				if _, ok := inbound[key]; !ok {
+					inbound[key] = &e
+					keys = append(keys, key)
+				}
+				inbound[key].add(rule, sg.Name, members[rule.SecurityGroupName], vm.ID)
+			}
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := inbound[keys[i]], inbound[keys[j]]
+			if a.Protocol != b.Protocol {
+				return a.Protocol < b.Protocol
+			}
+			if a.StartPort != b.StartPort {
+				return a.StartPort < b.StartPort
+			}
+			if a.EndPort != b.EndPort {
+				return a.EndPort < b.EndPort
+			}
+			if a.IcmpType != b.IcmpType {
+				return a.IcmpType < b.IcmpType
+			}
+			return a.IcmpCode < b.IcmpCode
+		})
+
+		for _, key := range keys {
+			e := inbound[key]
+			exposure.Inbound = append(exposure.Inbound, *e)
+
+			if !e.WorldOpen {
+				continue
+			}
+
+			for _, protocol := range []string{"tcp", "udp"} {
+				for _, port := range adminPorts {
+					if e.Covers(protocol, port) {
+						report.Findings = append(report.Findings, SecurityExposureFinding{
+							VirtualMachineID:   vm.ID,
+							VirtualMachineName: vm.Name,
+							Protocol:           protocol,
+							Port:               port,
+							SecurityGroups:     e.SecurityGroups,
+						})
+					}
+				}
+			}
+		}
+
+		report.VirtualMachines = append(report.VirtualMachines, exposure)
+	}
+
+	return &report
+}
+
+// add merges an ingress rule granted by the specified security group into the exposure. Rules
+// referencing a security group add its members as peers, the virtual machine itself excluded.
+func (e *PortExposure) add(rule IngressRule, sg string, members []SecurityGroupPeer, self *UUID) {
+	found := false
+	for _, name := range e.SecurityGroups {
+		if name == sg {
+			found = true
+			break
+		}
+	}
+	if !found {
+		e.SecurityGroups = append(e.SecurityGroups, sg)
+	}
+
+	if rule.SecurityGroupName != "" {
+	next:
+		for _, peer := range members {
+			if self != nil && peer.VirtualMachineID != nil && peer.VirtualMachineID.Equal(*self) {
+				continue
+			}
+			for _, p := range e.Peers {
+				if p.SecurityGroup == peer.SecurityGroup && p.VirtualMachineID.Equal(*peer.VirtualMachineID) {
+					continue next
+				}
+			}
+			e.Peers = append(e.Peers, peer)
+		}
+		return
+	}
+
+	if rule.CIDR == nil {
+		return
+	}
+
+	for _, cidr := range e.CIDRs {
+		if cidr.Equal(*rule.CIDR) {
+			return
+		}
+	}
+	e.CIDRs = append(e.CIDRs, *rule.CIDR)
+
+	for _, cidr := range NewCIDRSet(e.CIDRs...).CIDRs() {
+		if ones, _ := cidr.Mask.Size(); ones == 0 {
+			e.WorldOpen = true
+		}
+	}
+	if !isPrivateCIDR(*rule.CIDR) {
+		e.Public = true
+	}
+}
+
+// isPrivateCIDR returns true if the network is entirely included in a range not reachable from
+// the Internet.
+func isPrivateCIDR(cidr CIDR) bool {
+	ones, bits := cidr.Mask.Size()
+	for _, private := range privateNetworks {
+		pOnes, pBits := private.Mask.Size()
+		if pBits == bits && pOnes <= ones && private.Contains(cidr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// virtualMachineIPAddresses returns the IPv4 and IPv6 addresses of the NICs of a virtual machine.
+func virtualMachineIPAddresses(vm VirtualMachine) []net.IP {
+	ips := make([]net.IP, 0)
+	for _, nic := range vm.Nic {
+		if nic.IPAddress != nil {
+			ips = append(ips, nic.IPAddress)
+		}
+		if nic.IP6Address != nil {
+			ips = append(ips, nic.IP6Address)
+		}
+	}
+
+	return ips
+}
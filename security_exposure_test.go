@@ -0,0 +1,153 @@
+package egoscale
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSecurityExposure(t *testing.T) {
+	var (
+		webID = MustParseUUID("4bfe1073-a6d4-48bd-8f24-2ab586674092")
+		dbID  = MustParseUUID("4bfe1073-a6d4-48bd-8f24-2ab586674093")
+	)
+
+	sgs := []SecurityGroup{
+		{ID: webID, Name: "web", IngressRule: []IngressRule{
+			{Protocol: "tcp", StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("0.0.0.0/0")},
+			{Protocol: "tcp", StartPort: 80, EndPort: 80, CIDR: MustParseCIDR("0.0.0.0/0")},
+			{Protocol: "tcp", StartPort: 80, EndPort: 80, CIDR: MustParseCIDR("::/0")},
+			{Protocol: "icmp", IcmpType: 8, CIDR: MustParseCIDR("10.0.0.0/8")},
+		}},
+		{ID: dbID, Name: "db", IngressRule: []IngressRule{
+			{Protocol: "tcp", StartPort: 5432, EndPort: 5432, SecurityGroupName: "web"},
+			{Protocol: "tcp", StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("203.0.113.0/24")},
+			{Protocol: "tcp", StartPort: 3000, EndPort: 4000, CIDR: MustParseCIDR("0.0.0.0/0")},
+		}},
+	}
+
+	vms := []VirtualMachine{
+		{
+			ID:            MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01"),
+			Name:          "web1",
+			Nic:           []Nic{{IPAddress: net.ParseIP("198.51.100.1"), IP6Address: net.ParseIP("2001:db8::1")}},
+			SecurityGroup: []SecurityGroup{{ID: webID}},
+		},
+		{
+			ID:            MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a02"),
+			Name:          "db1",
+			Nic:           []Nic{{IPAddress: net.ParseIP("198.51.100.2")}},
+			SecurityGroup: []SecurityGroup{{Name: "db"}, {Name: "web"}},
+		},
+	}
+
+	report := AnalyzeSecurityExposure(vms, sgs, SecurityExposureOptions{})
+	require.Len(t, report.VirtualMachines, 2)
+
+	web1 := report.VirtualMachines[0]
+	require.Equal(t, []string{"web"}, web1.SecurityGroups)
+	require.Equal(t, []net.IP{net.ParseIP("198.51.100.1"), net.ParseIP("2001:db8::1")}, web1.IPAddresses)
+	require.Len(t, web1.Inbound, 3)
+	require.Equal(t, "icmp type 8 code 0", web1.Inbound[0].String())
+	require.False(t, web1.Inbound[0].Public)
+	require.Equal(t, "tcp/80", web1.Inbound[2].String())
+	require.Equal(t, []CIDR{*MustParseCIDR("0.0.0.0/0"), *MustParseCIDR("::/0")}, web1.Inbound[2].CIDRs)
+	require.True(t, web1.Inbound[2].WorldOpen)
+
+	db1 := report.VirtualMachines[1]
+	require.Equal(t, []string{"db", "web"}, db1.SecurityGroups)
+	actual := make([]string, len(db1.Inbound))
+	for i, e := range db1.Inbound {
+		actual[i] = e.String()
+	}
+	require.Equal(t, []string{"icmp type 8 code 0", "tcp/22", "tcp/80", "tcp/3000-4000", "tcp/5432"}, actual)
+
+	// tcp/22 is granted by both security groups
+	require.Equal(t, []string{"db", "web"}, db1.Inbound[1].SecurityGroups)
+	require.Len(t, db1.Inbound[1].CIDRs, 2)
+
+	// The group reference resolves into the members of "web", the VM itself excluded
+	require.Equal(t, []SecurityGroupPeer{{
+		SecurityGroup:      "web",
+		VirtualMachineID:   vms[0].ID,
+		VirtualMachineName: "web1",
+		IPAddresses:        []net.IP{net.ParseIP("198.51.100.1"), net.ParseIP("2001:db8::1")},
+	}}, db1.Inbound[4].Peers)
+	require.False(t, db1.Inbound[4].Public)
+
+	findings := make([]string, len(report.Findings))
+	for i, f := range report.Findings {
+		findings[i] = f.String()
+	}
+	require.Equal(t, []string{
+		"web1: tcp/22 open to the world (security groups: web)",
+		"db1: tcp/22 open to the world (security groups: db, web)",
+		"db1: tcp/3389 open to the world (security groups: db)",
+	}, findings)
+
+	public := report.Public()
+	require.Len(t, public, 2)
+	require.Len(t, public[0].Inbound, 2)
+
+	_, err := json.Marshal(report)
+	require.NoError(t, err)
+
+	// Custom administrative ports
+	report = AnalyzeSecurityExposure(vms, sgs, SecurityExposureOptions{AdminPorts: []uint16{80}})
+	require.Len(t, report.Findings, 2)
+
+	// Rules without protocol are TCP rules, and networks covering the whole Internet together
+	// are open to the world
+	report = AnalyzeSecurityExposure(vms[:1], []SecurityGroup{{ID: webID, Name: "web", IngressRule: []IngressRule{
+		{StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("0.0.0.0/1")},
+		{StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("128.0.0.0/1")},
+	}}}, SecurityExposureOptions{})
+	require.Len(t, report.VirtualMachines[0].Inbound, 1)
+	require.Equal(t, "tcp", report.VirtualMachines[0].Inbound[0].Protocol)
+	require.True(t, report.VirtualMachines[0].Inbound[0].WorldOpen)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, "web1: tcp/22 open to the world (security groups: web)", report.Findings[0].String())
+	require.True(t, PortExposure{StartPort: 22, EndPort: 22}.Covers("tcp", 22))
+}
+
+func TestClient_SecurityExposure(t *testing.T) {
+	ts := newServer(
+		response{200, jsonContentType, `
+{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{
+		"id": "f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01",
+		"name": "web1",
+		"nic": [{"ipaddress": "198.51.100.1", "isdefault": true}],
+		"securitygroup": [{"id": "4bfe1073-a6d4-48bd-8f24-2ab586674092", "name": "default"}]
+	}]
+}}`},
+		response{200, jsonContentType, `
+{"listsecuritygroupsresponse": {
+	"count": 1,
+	"securitygroup": [{
+		"id": "4bfe1073-a6d4-48bd-8f24-2ab586674092",
+		"name": "default",
+		"ingressrule": [{
+			"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab25",
+			"protocol": "tcp",
+			"startport": 3389,
+			"endport": 3389,
+			"cidr": "::/0"
+		}]
+	}]
+}}`},
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	report, err := client.SecurityExposure(context.Background(), SecurityExposureOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, uint16(3389), report.Findings[0].Port)
+	require.Equal(t, "web1", report.Findings[0].VirtualMachineName)
+}
@@ -0,0 +1,390 @@
+package egoscale
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Security group rules can be expressed in a compact text notation:
+//
+//	<protocol spec> from|to <CIDR | sg:<security group name>> ["<description>"]
+//
+// where the protocol spec is either "tcp/<port>" or "tcp/<start port>-<end port>" (same for
+// "udp"), "icmp type <type> code <code>" (same for "icmpv6"), or a protocol name for protocols
+// without ports (e.g. "esp", "gre"). Ingress rules use "from", egress rules use "to", e.g.:
+//
+//	tcp/22 from 203.0.113.0/24 "SSH"
+//	udp/1000-2000 from sg:web
+//	icmp type 8 code 0 to ::/0
+
+// SecurityGroupRuleSyntaxError represents an error in the text notation of a security group rule.
+type SecurityGroupRuleSyntaxError struct {
+	// Line is the number of the line containing the error (starting at 1) when parsing multiple
+	// rules, 0 otherwise
+	Line int
+	// Column is the position of the error in the rule (starting at 1)
+	Column int
+	// Reason describes the error
+	Reason string
+}
+
+// Error implements the error interface
+func (e *SecurityGroupRuleSyntaxError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Reason)
+	}
+
+	return fmt.Sprintf("column %d: %s", e.Column, e.Reason)
+}
+
+// String returns the text notation of the ingress rule (e.g. "tcp/22 from 0.0.0.0/0").
+func (r IngressRule) String() string {
+	return formatSecurityGroupRule(r, false)
+}
+
+// String returns the text notation of the egress rule (e.g. "udp/53 to 0.0.0.0/0").
+func (r EgressRule) String() string {
+	return formatSecurityGroupRule(IngressRule(r), true)
+}
+
+// FormatRules returns the text notation of the security group rules, one rule per line, ingress
+// rules first.
+func (sg SecurityGroup) FormatRules() string {
+	var b strings.Builder
+
+	for _, r := range sg.IngressRule {
+		b.WriteString(r.String())
+		b.WriteByte('\n')
+	}
+	for _, r := range sg.EgressRule {
+		b.WriteString(r.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// ParseIngressRule parses an ingress rule expressed in the text notation (e.g.
+// "tcp/22 from 203.0.113.0/24"). Errors are of type *SecurityGroupRuleSyntaxError.
+func ParseIngressRule(s string) (*IngressRule, error) {
+	rule, _, err := parseSecurityGroupRule(s, "from")
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// ParseEgressRule parses an egress rule expressed in the text notation (e.g. "udp/53 to 0.0.0.0/0").
+// Errors are of type *SecurityGroupRuleSyntaxError.
+func ParseEgressRule(s string) (*EgressRule, error) {
+	rule, _, err := parseSecurityGroupRule(s, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	r := EgressRule(rule)
+	return &r, nil
+}
+
+// ParseSecurityGroupRules parses a list of rules expressed in the text notation, one rule per
+// line; rules using "from" are ingress rules, rules using "to" are egress rules. Empty lines and
+// lines starting with "#" are ignored. Errors are of type *SecurityGroupRuleSyntaxError.
+func ParseSecurityGroupRules(text string) ([]IngressRule, []EgressRule, error) {
+	var (
+		ingress = make([]IngressRule, 0)
+		egress  = make([]EgressRule, 0)
+	)
+
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		rule, isEgress, err := parseSecurityGroupRule(line, "")
+		if err != nil {
+			err.(*SecurityGroupRuleSyntaxError).Line = i + 1
+			return nil, nil, err
+		}
+
+		if isEgress {
+			egress = append(egress, EgressRule(rule))
+		} else {
+			ingress = append(ingress, rule)
+		}
+	}
+
+	return ingress, egress, nil
+}
+
+// AuthorizeRequest returns the request authorizing the ingress rule in the specified security group.
+func (r IngressRule) AuthorizeRequest(securityGroupID *UUID) *AuthorizeSecurityGroupIngress {
+	req := AuthorizeSecurityGroupIngress{
+		SecurityGroupID: securityGroupID,
+		Description:     r.Description,
+		Protocol:        r.Protocol,
+		StartPort:       r.StartPort,
+		EndPort:         r.EndPort,
+		IcmpType:        r.IcmpType,
+		IcmpCode:        r.IcmpCode,
+	}
+
+	if r.SecurityGroupName != "" {
+		req.UserSecurityGroupList = []UserSecurityGroup{{Group: r.SecurityGroupName}}
+	} else if r.CIDR != nil {
+		req.CIDRList = []CIDR{*r.CIDR}
+	}
+
+	return &req
+}
+
+// AuthorizeRequest returns the request authorizing the egress rule in the specified security group.
+func (r EgressRule) AuthorizeRequest(securityGroupID *UUID) *AuthorizeSecurityGroupEgress {
+	return (*AuthorizeSecurityGroupEgress)(IngressRule(r).AuthorizeRequest(securityGroupID))
+}
+
+// Rules returns the ingress rules resulting from the request, one per CIDR and per user
+// security group.
+func (req AuthorizeSecurityGroupIngress) Rules() []IngressRule {
+	var rules = make([]IngressRule, 0, len(req.CIDRList)+len(req.UserSecurityGroupList))
+
+	rule := IngressRule{
+		Description: req.Description,
+		Protocol:    req.Protocol,
+		StartPort:   req.StartPort,
+		EndPort:     req.EndPort,
+		IcmpType:    req.IcmpType,
+		IcmpCode:    req.IcmpCode,
+	}
+
+	for i := range req.CIDRList {
+		r := rule
+		cidr := req.CIDRList[i]
+		r.CIDR = &cidr
+		rules = append(rules, r)
+	}
+
+	for _, usg := range req.UserSecurityGroupList {
+		r := rule
+		r.SecurityGroupName = usg.Group
+		rules = append(rules, r)
+	}
+
+	return rules
+}
+
+// Rules returns the egress rules resulting from the request, one per CIDR and per user
+// security group.
+func (req AuthorizeSecurityGroupEgress) Rules() []EgressRule {
+	ingress := AuthorizeSecurityGroupIngress(req).Rules()
+
+	rules := make([]EgressRule, len(ingress))
+	for i := range ingress {
+		rules[i] = EgressRule(ingress[i])
+	}
+
+	return rules
+}
+
+// formatSecurityGroupRule returns the text notation of a rule.
+func formatSecurityGroupRule(rule IngressRule, egress bool) string {
+	s := securityGroupRuleKey(rule, egress)
+	if rule.Description != "" {
+		s += " " + strconv.Quote(rule.Description)
+	}
+
+	return s
+}
+
+// ruleToken represents a word of a rule text notation, and its position in the text.
+type ruleToken struct {
+	text   string
+	column int
+}
+
+// parseSecurityGroupRule parses a rule expressed in the text notation, and returns true if it is
+// an egress rule. If direction is not empty, the rule must use the specified direction keyword
+// ("from" or "to").
+func parseSecurityGroupRule(s, direction string) (IngressRule, bool, error) {
+	var (
+		rule   IngressRule
+		egress bool
+		tokens []ruleToken
+		pos    int
+	)
+
+	syntaxError := func(column int, format string, a ...interface{}) error {
+		return &SecurityGroupRuleSyntaxError{Column: column, Reason: fmt.Sprintf(format, a...)}
+	}
+
+	// The description, if any, is the quoted string ending the rule.
+	description := -1
+	for i, r := range s {
+		if r == '"' {
+			description = i
+			break
+		}
+	}
+	words := s
+	if description >= 0 {
+		desc, err := strconv.Unquote(strings.TrimRightFunc(s[description:], unicode.IsSpace))
+		if err != nil {
+			return rule, false, syntaxError(description+1, "invalid description: %s", err)
+		}
+		rule.Description = desc
+		words = s[:description]
+	}
+
+	for _, f := range strings.Fields(words) {
+		i := strings.Index(words[pos:], f) + pos
+		tokens = append(tokens, ruleToken{text: f, column: i + 1})
+		pos = i + len(f)
+	}
+
+	next := func(expected string) (ruleToken, error) {
+		if len(tokens) == 0 {
+			return ruleToken{}, syntaxError(len(strings.TrimRightFunc(words, unicode.IsSpace))+1,
+				"missing %s", expected)
+		}
+		t := tokens[0]
+		tokens = tokens[1:]
+		return t, nil
+	}
+
+	parseUint := func(t ruleToken, s string, bits int, what string) (uint64, error) {
+		v, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return 0, syntaxError(t.column, "invalid %s %q", what, s)
+		}
+		return v, nil
+	}
+
+	t, err := next("protocol")
+	if err != nil {
+		return rule, false, err
+	}
+
+	protocol, ports := t.text, ""
+	if i := strings.Index(t.text, "/"); i >= 0 {
+		protocol, ports = t.text[:i], t.text[i+1:]
+	}
+	rule.Protocol = strings.ToLower(protocol)
+
+	switch rule.Protocol {
+	case "tcp", "udp":
+		if ports == "" {
+			return rule, false, syntaxError(t.column+len(protocol), "missing port after %q", protocol)
+		}
+
+		start, end := ports, ports
+		if i := strings.Index(ports, "-"); i >= 0 {
+			start, end = ports[:i], ports[i+1:]
+		}
+
+		startPort, err := parseUint(t, start, 16, "port")
+		if err != nil {
+			return rule, false, err
+		}
+		endPort, err := parseUint(t, end, 16, "port")
+		if err != nil {
+			return rule, false, err
+		}
+		if endPort < startPort {
+			return rule, false, syntaxError(t.column+len(protocol)+1, "invalid port range %q", ports)
+		}
+		rule.StartPort, rule.EndPort = uint16(startPort), uint16(endPort)
+
+	case "icmp", "icmpv6":
+		if ports != "" {
+			return rule, false, syntaxError(t.column+len(protocol), "%s rules have no ports", protocol)
+		}
+
+		for _, field := range []struct {
+			name  string
+			value *uint8
+		}{
+			{"type", &rule.IcmpType},
+			{"code", &rule.IcmpCode},
+		} {
+			kw, err := next(fmt.Sprintf("ICMP %s", field.name))
+			if err != nil {
+				return rule, false, err
+			}
+			if kw.text != field.name {
+				return rule, false, syntaxError(kw.column, "expected %q, got %q", field.name, kw.text)
+			}
+
+			t, err := next(fmt.Sprintf("ICMP %s value", field.name))
+			if err != nil {
+				return rule, false, err
+			}
+			v, err := parseUint(t, t.text, 8, "ICMP "+field.name)
+			if err != nil {
+				return rule, false, err
+			}
+			*field.value = uint8(v)
+		}
+
+	default:
+		if ports != "" {
+			return rule, false, syntaxError(t.column+len(protocol), "%s rules have no ports", protocol)
+		}
+		for _, r := range protocol {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return rule, false, syntaxError(t.column, "invalid protocol %q", protocol)
+			}
+		}
+	}
+
+	t, err = next(`"from" or "to"`)
+	if err != nil {
+		return rule, false, err
+	}
+	switch {
+	case direction != "" && t.text != direction:
+		return rule, false, syntaxError(t.column, "expected %q, got %q", direction, t.text)
+	case t.text == "from":
+	case t.text == "to":
+		egress = true
+	default:
+		return rule, false, syntaxError(t.column, `expected "from" or "to", got %q`, t.text)
+	}
+
+	t, err = next("CIDR or security group")
+	if err != nil {
+		return rule, false, err
+	}
+	if strings.HasPrefix(t.text, "sg:") {
+		if rule.SecurityGroupName = strings.TrimPrefix(t.text, "sg:"); rule.SecurityGroupName == "" {
+			return rule, false, syntaxError(t.column+3, "missing security group name")
+		}
+	} else {
+		target := t.text
+		if !strings.Contains(target, "/") {
+			// A single IP address
+			if ip := net.ParseIP(target); ip != nil {
+				if ip.To4() != nil {
+					target += "/32"
+				} else {
+					target += "/128"
+				}
+			}
+		}
+
+		cidr, err := ParseCIDR(target)
+		if err != nil {
+			return rule, false, syntaxError(t.column, "invalid CIDR %q", t.text)
+		}
+		rule.CIDR = cidr
+	}
+
+	if len(tokens) > 0 {
+		return rule, false, syntaxError(tokens[0].column, "unexpected %q", tokens[0].text)
+	}
+
+	return rule, egress, nil
+}
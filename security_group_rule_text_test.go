@@ -0,0 +1,137 @@
+package egoscale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIngressRule(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected IngressRule
+		// canonical is the expected text notation of the rule parsed, if different from text
+		canonical string
+	}{
+		{
+			text:     "tcp/22 from 203.0.113.0/24",
+			expected: IngressRule{Protocol: "tcp", StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("203.0.113.0/24")},
+		},
+		{
+			text:     `udp/1000-2000 from sg:web "game servers"`,
+			expected: IngressRule{Protocol: "udp", StartPort: 1000, EndPort: 2000, SecurityGroupName: "web", Description: "game servers"},
+		},
+		{
+			text:     "icmp type 8 code 0 from ::/0",
+			expected: IngressRule{Protocol: "icmp", IcmpType: 8, IcmpCode: 0, CIDR: MustParseCIDR("::/0")},
+		},
+		{
+			text:     "esp from 0.0.0.0/0",
+			expected: IngressRule{Protocol: "esp", CIDR: MustParseCIDR("0.0.0.0/0")},
+		},
+		{
+			text:      "  TCP/443   from 198.51.100.7  ",
+			expected:  IngressRule{Protocol: "tcp", StartPort: 443, EndPort: 443, CIDR: MustParseCIDR("198.51.100.7/32")},
+			canonical: "tcp/443 from 198.51.100.7/32",
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseIngressRule(test.text)
+		require.NoError(t, err, test.text)
+		require.Equal(t, test.expected, *actual, test.text)
+
+		canonical := test.canonical
+		if canonical == "" {
+			canonical = test.text
+		}
+		require.Equal(t, canonical, actual.String())
+	}
+}
+
+func TestParseIngressRule_Errors(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"", "column 1: missing protocol"},
+		{"tcp from 0.0.0.0/0", `column 4: missing port after "tcp"`},
+		{"tcp/http from 0.0.0.0/0", `column 1: invalid port "http"`},
+		{"tcp/2000-1000 from 0.0.0.0/0", `column 5: invalid port range "2000-1000"`},
+		{"icmp type 8 from ::/0", `column 13: expected "code", got "from"`},
+		{"icmp type 300 code 0 from ::/0", `column 11: invalid ICMP type "300"`},
+		{"tcp/22 to 0.0.0.0/0", `column 8: expected "from", got "to"`},
+		{"tcp/22 from", `column 12: missing CIDR or security group`},
+		{"tcp/22 from 0.0.0.0/33", `column 13: invalid CIDR "0.0.0.0/33"`},
+		{"tcp/22 from sg:", `column 16: missing security group name`},
+		{"tcp/22 from sg:web yolo", `column 20: unexpected "yolo"`},
+		{`tcp/22 from sg:web "SSH`, `column 20: invalid description: invalid syntax`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseIngressRule(test.text)
+		require.IsType(t, &SecurityGroupRuleSyntaxError{}, err, test.text)
+		require.Equal(t, test.expected, err.Error(), test.text)
+	}
+}
+
+func TestParseEgressRule(t *testing.T) {
+	actual, err := ParseEgressRule("udp/53 to 0.0.0.0/0")
+	require.NoError(t, err)
+	require.Equal(t, EgressRule{Protocol: "udp", StartPort: 53, EndPort: 53, CIDR: MustParseCIDR("0.0.0.0/0")}, *actual)
+	require.Equal(t, "udp/53 to 0.0.0.0/0", actual.String())
+
+	_, err = ParseEgressRule("udp/53 from 0.0.0.0/0")
+	require.EqualError(t, err, `column 8: expected "to", got "from"`)
+}
+
+func TestParseSecurityGroupRules(t *testing.T) {
+	ingress, egress, err := ParseSecurityGroupRules(`
+# Administration
+tcp/22 from 203.0.113.0/24 "SSH"
+
+tcp/80 from 0.0.0.0/0
+udp/53 to 0.0.0.0/0
+`)
+	require.NoError(t, err)
+	require.Len(t, ingress, 2)
+	require.Len(t, egress, 1)
+
+	sg := SecurityGroup{IngressRule: ingress, EgressRule: egress}
+	require.Equal(t, `tcp/22 from 203.0.113.0/24 "SSH"
+tcp/80 from 0.0.0.0/0
+udp/53 to 0.0.0.0/0
+`, sg.FormatRules())
+
+	_, _, err = ParseSecurityGroupRules("tcp/22 from 0.0.0.0/0\n\ntcp/80 form 0.0.0.0/0\n")
+	require.EqualError(t, err, `line 3, column 8: expected "from" or "to", got "form"`)
+}
+
+func TestIngressRule_AuthorizeRequest(t *testing.T) {
+	id := MustParseUUID("4bfe1073-a6d4-48bd-8f24-2ab586674092")
+
+	rule, err := ParseIngressRule(`tcp/8000-8080 from 203.0.113.0/24 "web"`)
+	require.NoError(t, err)
+
+	req := rule.AuthorizeRequest(id)
+	require.Equal(t, &AuthorizeSecurityGroupIngress{
+		SecurityGroupID: id,
+		Description:     "web",
+		Protocol:        "tcp",
+		StartPort:       8000,
+		EndPort:         8080,
+		CIDRList:        []CIDR{*MustParseCIDR("203.0.113.0/24")},
+	}, req)
+	require.Equal(t, []IngressRule{*rule}, req.Rules())
+
+	egress, err := ParseEgressRule("udp/53 to sg:dns")
+	require.NoError(t, err)
+	require.Equal(t, []UserSecurityGroup{{Group: "dns"}}, egress.AuthorizeRequest(id).UserSecurityGroupList)
+	require.Equal(t, []EgressRule{*egress}, egress.AuthorizeRequest(id).Rules())
+
+	// A request with several sources must result in one rule per source
+	req.UserSecurityGroupList = []UserSecurityGroup{{Group: "lb"}}
+	rules := req.Rules()
+	require.Len(t, rules, 2)
+	require.Equal(t, "tcp/8000-8080 from sg:lb \"web\"", rules[1].String())
+}
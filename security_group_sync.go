@@ -0,0 +1,374 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// defaultSecurityGroupSyncConcurrency is the default maximum number of security group rules changes
+// applied concurrently.
+const defaultSecurityGroupSyncConcurrency = 4
+
+// SecurityGroupRuleAction represents an action of a security group rules synchronisation.
+type SecurityGroupRuleAction string
+
+const (
+	// SecurityGroupRuleAuthorize represents the authorization of a missing rule.
+	SecurityGroupRuleAuthorize SecurityGroupRuleAction = "authorize"
+	// SecurityGroupRuleRevoke represents the revocation of an unwanted rule.
+	SecurityGroupRuleRevoke SecurityGroupRuleAction = "revoke"
+)
+
+// SecurityGroupRuleChange represents a change of a security group rules synchronisation.
+type SecurityGroupRuleChange struct {
+	Action SecurityGroupRuleAction
+	// Egress is true if the rule is an egress rule, false if it is an ingress rule
+	Egress bool
+	// Rule is the rule affected by the change: the desired rule for authorizations, the existing
+	// rule for revocations
+	Rule IngressRule
+
+	// Applied is true if the change has been applied successfully
+	Applied bool
+	// Err is the error that occurred while applying the change, if any
+	Err error
+}
+
+// String returns a human-readable description of the change.
+func (c *SecurityGroupRuleChange) String() string {
+	sign := "+"
+	if c.Action == SecurityGroupRuleRevoke {
+		sign = "-"
+	}
+
+	direction := "ingress"
+	if c.Egress {
+		direction = "egress"
+	}
+
+	return fmt.Sprintf("%s %s %s", sign, direction, securityGroupRuleKey(c.Rule, c.Egress))
+}
+
+// SecurityGroupRulesSyncReport represents the result of a security group rules synchronisation.
+type SecurityGroupRulesSyncReport struct {
+	// SecurityGroup is the state of the security group after the synchronisation (before, in
+	// dry-run mode)
+	SecurityGroup *SecurityGroup
+	// Changes lists the changes required, authorizations first
+	Changes []*SecurityGroupRuleChange
+	// Unchanged is the number of existing rules left untouched
+	Unchanged int
+}
+
+// Empty returns true if the security group rules are already in the desired state.
+func (r *SecurityGroupRulesSyncReport) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// String returns a human-readable description of the changes, one change per line.
+func (r *SecurityGroupRulesSyncReport) String() string {
+	if r.Empty() {
+		return "no changes\n"
+	}
+
+	var b strings.Builder
+	for _, c := range r.Changes {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// SyncSecurityGroupRulesOptions represents the options of SyncSecurityGroupRules.
+type SyncSecurityGroupRulesOptions struct {
+	// DryRun computes the changes without applying them
+	DryRun bool
+	// PlanOutput receives the human-readable description of the changes before they are applied, if set
+	PlanOutput io.Writer
+	// Concurrency is the maximum number of changes applied concurrently (default: 4)
+	Concurrency int
+}
+
+// SyncSecurityGroupRules converges the rules of the specified security group (looked up by ID if
+// set, otherwise by name) to the desired ingress and egress rules: missing rules are authorized
+// and unwanted ones are revoked, existing rules matching a desired one are left untouched. Rules
+// are matched on their protocol, port range (TCP/UDP), ICMP type and code (ICMP/ICMPv6) and their
+// source/destination, either a CIDR or another security group (SecurityGroupName); the rules
+// descriptions are not taken into account.
+//
+// Authorizations are applied before revocations so that traffic is never interrupted while a rule
+// is being replaced, and revocations are skipped if any authorization failed. The report returned
+// lists the changes and their result.
+func (client *Client) SyncSecurityGroupRules(ctx context.Context, sg *SecurityGroup, desiredIngress []IngressRule,
+	desiredEgress []EgressRule, opts SyncSecurityGroupRulesOptions) (*SecurityGroupRulesSyncReport, error) {
+	for i := range desiredIngress {
+		if err := validateSecurityGroupRule(desiredIngress[i]); err != nil {
+			return nil, fmt.Errorf("ingress rule #%d: %s", i, err)
+		}
+	}
+	for i := range desiredEgress {
+		if err := validateSecurityGroupRule(IngressRule(desiredEgress[i])); err != nil {
+			return nil, fmt.Errorf("egress rule #%d: %s", i, err)
+		}
+	}
+
+	resp, err := client.GetWithContext(ctx, &SecurityGroup{ID: sg.ID, Name: sg.Name})
+	if err != nil {
+		return nil, err
+	}
+	current := resp.(*SecurityGroup)
+
+	egress := make([]IngressRule, len(desiredEgress))
+	for i := range desiredEgress {
+		egress[i] = IngressRule(desiredEgress[i])
+	}
+	currentEgress := make([]IngressRule, len(current.EgressRule))
+	for i := range current.EgressRule {
+		currentEgress[i] = IngressRule(current.EgressRule[i])
+	}
+
+	report := SecurityGroupRulesSyncReport{SecurityGroup: current}
+	ingressChanges, ingressUnchanged := planSecurityGroupRules(current.IngressRule, desiredIngress, false)
+	egressChanges, egressUnchanged := planSecurityGroupRules(currentEgress, egress, true)
+	report.Unchanged = ingressUnchanged + egressUnchanged
+
+	// Authorizations first, then revocations.
+	changes := append(ingressChanges, egressChanges...)
+	for _, action := range []SecurityGroupRuleAction{SecurityGroupRuleAuthorize, SecurityGroupRuleRevoke} {
+		for _, c := range changes {
+			if c.Action == action {
+				report.Changes = append(report.Changes, c)
+			}
+		}
+	}
+
+	if opts.PlanOutput != nil {
+		if _, err := io.WriteString(opts.PlanOutput, report.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.DryRun || report.Empty() {
+		return &report, nil
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultSecurityGroupSyncConcurrency
+	}
+
+	var failed int
+	for _, action := range []SecurityGroupRuleAction{SecurityGroupRuleAuthorize, SecurityGroupRuleRevoke} {
+		if failed > 0 {
+			break
+		}
+
+		changes := make([]*SecurityGroupRuleChange, 0)
+		for _, c := range report.Changes {
+			if c.Action == action {
+				changes = append(changes, c)
+			}
+		}
+
+		failed += client.applySecurityGroupRuleChanges(ctx, current, changes, concurrency)
+	}
+
+	if resp, err = client.GetWithContext(ctx, &SecurityGroup{ID: current.ID}); err != nil {
+		return &report, err
+	}
+	report.SecurityGroup = resp.(*SecurityGroup)
+
+	if failed > 0 {
+		return &report, fmt.Errorf("unable to apply %d security group rule change(s)", failed)
+	}
+
+	return &report, nil
+}
+
+// applySecurityGroupRuleChanges applies the specified changes to the security group, at most
+// concurrency at a time, and returns the number of changes that failed.
+func (client *Client) applySecurityGroupRuleChanges(ctx context.Context, sg *SecurityGroup,
+	changes []*SecurityGroupRuleChange, concurrency int) int {
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, concurrency)
+		failed int
+		mu     sync.Mutex
+	)
+
+	for _, c := range changes {
+		c := c
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			c.Err = ctx.Err()
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if c.Err = client.applySecurityGroupRuleChange(ctx, sg, c); c.Err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return
+			}
+			c.Applied = true
+		}()
+	}
+	wg.Wait()
+
+	return failed
+}
+
+func (client *Client) applySecurityGroupRuleChange(ctx context.Context, sg *SecurityGroup,
+	c *SecurityGroupRuleChange) error {
+	if c.Action == SecurityGroupRuleRevoke {
+		if c.Egress {
+			return client.BooleanRequestWithContext(ctx, &RevokeSecurityGroupEgress{ID: c.Rule.RuleID})
+		}
+		return client.BooleanRequestWithContext(ctx, &RevokeSecurityGroupIngress{ID: c.Rule.RuleID})
+	}
+
+	req := AuthorizeSecurityGroupIngress{
+		SecurityGroupID: sg.ID,
+		Description:     c.Rule.Description,
+		Protocol:        c.Rule.Protocol,
+		StartPort:       c.Rule.StartPort,
+		EndPort:         c.Rule.EndPort,
+		IcmpType:        c.Rule.IcmpType,
+		IcmpCode:        c.Rule.IcmpCode,
+	}
+	if c.Rule.SecurityGroupName != "" {
+		req.UserSecurityGroupList = []UserSecurityGroup{{Group: c.Rule.SecurityGroupName}}
+	} else {
+		req.CIDRList = []CIDR{*c.Rule.CIDR}
+	}
+
+	var err error
+	if c.Egress {
+		_, err = client.RequestWithContext(ctx, (*AuthorizeSecurityGroupEgress)(&req))
+	} else {
+		_, err = client.RequestWithContext(ctx, &req)
+	}
+
+	return err
+}
+
+// planSecurityGroupRules returns the changes required to converge the current rules to the desired
+// ones, and the number of current rules left untouched. Duplicate desired rules are authorized
+// once, and duplicate current rules are revoked but one.
+func planSecurityGroupRules(current, desired []IngressRule, egress bool) ([]*SecurityGroupRuleChange, int) {
+	var (
+		changes   = make([]*SecurityGroupRuleChange, 0)
+		existing  = make(map[string]bool)
+		wanted    = make(map[string]bool)
+		unchanged int
+	)
+
+	for _, rule := range desired {
+		wanted[securityGroupRuleKey(rule, egress)] = true
+	}
+
+	for _, rule := range current {
+		key := securityGroupRuleKey(rule, egress)
+		if wanted[key] && !existing[key] {
+			existing[key] = true
+			unchanged++
+			continue
+		}
+
+		changes = append(changes, &SecurityGroupRuleChange{
+			Action: SecurityGroupRuleRevoke,
+			Egress: egress,
+			Rule:   rule,
+		})
+	}
+
+	for _, rule := range desired {
+		key := securityGroupRuleKey(rule, egress)
+		if existing[key] {
+			continue
+		}
+		existing[key] = true
+
+		changes = append(changes, &SecurityGroupRuleChange{
+			Action: SecurityGroupRuleAuthorize,
+			Egress: egress,
+			Rule:   rule,
+		})
+	}
+
+	return changes, unchanged
+}
+
+// securityGroupRuleKey returns the identity of a security group rule, made of the properties
+// relevant to its protocol, in a human-readable form (e.g. "tcp/22 from 0.0.0.0/0"). The CIDR is
+// masked to its network address, so that 10.0.0.1/24 and 10.0.0.0/24 are the same target.
+func securityGroupRuleKey(rule IngressRule, egress bool) string {
+	target := ""
+	if rule.SecurityGroupName != "" {
+		target = "sg:" + rule.SecurityGroupName
+	} else if rule.CIDR != nil {
+		target = (&net.IPNet{IP: rule.CIDR.IP.Mask(rule.CIDR.Mask), Mask: rule.CIDR.Mask}).String()
+	}
+
+	if egress {
+		return securityGroupRuleProtocol(rule) + " to " + target
+	}
+
+	return securityGroupRuleProtocol(rule) + " from " + target
+}
+
+// securityGroupRuleProtocol returns the protocol part of a security group rule identity (e.g.
+// "tcp/22" or "icmp type 8 code 0").
+func securityGroupRuleProtocol(rule IngressRule) string {
+	protocol := strings.ToLower(rule.Protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	switch protocol {
+	case "tcp", "udp":
+		start, end := rule.StartPort, rule.EndPort
+		if end == 0 {
+			end = start
+		}
+		if end != start {
+			return fmt.Sprintf("%s/%d-%d", protocol, start, end)
+		}
+		return fmt.Sprintf("%s/%d", protocol, start)
+
+	case "icmp", "icmpv6":
+		return fmt.Sprintf("%s type %d code %d", protocol, rule.IcmpType, rule.IcmpCode)
+	}
+
+	return protocol
+}
+
+// validateSecurityGroupRule checks that a desired security group rule can be authorized.
+func validateSecurityGroupRule(rule IngressRule) error {
+	if (rule.CIDR == nil) == (rule.SecurityGroupName == "") {
+		return &ValidationError{Field: "CIDR", Reason: "exactly one of CIDR or SecurityGroupName must be set"}
+	}
+
+	if rule.EndPort != 0 && rule.EndPort < rule.StartPort {
+		return &ValidationError{Field: "EndPort", Reason: "must be greater than or equal to StartPort"}
+	}
+
+	return nil
+}
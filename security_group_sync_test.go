@@ -0,0 +1,191 @@
+package egoscale
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanSecurityGroupRules(t *testing.T) {
+	current := []IngressRule{
+		{RuleID: MustParseUUID("fc03b5b1-1d15-4933-99c3-afa0b8f2ab25"), Protocol: "tcp",
+			StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("0.0.0.0/0"), Description: "SSH"},
+		{RuleID: MustParseUUID("fc03b5b1-1d15-4933-99c3-afa0b8f2ab26"), Protocol: "tcp",
+			StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("0.0.0.0/0")},
+		{RuleID: MustParseUUID("fc03b5b1-1d15-4933-99c3-afa0b8f2ab27"), Protocol: "udp",
+			StartPort: 1000, EndPort: 2000, SecurityGroupName: "web"},
+		{RuleID: MustParseUUID("fc03b5b1-1d15-4933-99c3-afa0b8f2ab28"), Protocol: "icmp",
+			IcmpType: 8, IcmpCode: 0, CIDR: MustParseCIDR("0.0.0.0/0")},
+	}
+
+	desired := []IngressRule{
+		// Same rule with a different description
+		{Protocol: "TCP", StartPort: 22, CIDR: MustParseCIDR("0.0.0.0/0")},
+		// Same port range, different source security group
+		{Protocol: "udp", StartPort: 1000, EndPort: 2000, SecurityGroupName: "db"},
+		// Same ICMP type, different code
+		{Protocol: "icmp", IcmpType: 8, IcmpCode: 1, CIDR: MustParseCIDR("0.0.0.0/0")},
+		{Protocol: "icmp", IcmpType: 8, IcmpCode: 1, CIDR: MustParseCIDR("0.0.0.0/0")},
+	}
+
+	changes, unchanged := planSecurityGroupRules(current, desired, false)
+	require.Equal(t, 1, unchanged)
+	require.Len(t, changes, 5)
+
+	actual := make([]string, len(changes))
+	for i, c := range changes {
+		actual[i] = c.String()
+	}
+	require.Equal(t, []string{
+		"- ingress tcp/22 from 0.0.0.0/0",
+		"- ingress udp/1000-2000 from sg:web",
+		"- ingress icmp type 8 code 0 from 0.0.0.0/0",
+		"+ ingress udp/1000-2000 from sg:db",
+		"+ ingress icmp type 8 code 1 from 0.0.0.0/0",
+	}, actual)
+	require.Equal(t, "fc03b5b1-1d15-4933-99c3-afa0b8f2ab26", changes[0].Rule.RuleID.String())
+
+	// CIDRs are compared by network address
+	changes, unchanged = planSecurityGroupRules(
+		[]IngressRule{{Protocol: "tcp", StartPort: 22, CIDR: MustParseCIDR("10.0.0.0/24")}},
+		[]IngressRule{{Protocol: "tcp", StartPort: 22, CIDR: &CIDR{net.IPNet{
+			IP:   net.ParseIP("10.0.0.1"),
+			Mask: net.CIDRMask(24, 32),
+		}}}},
+		false)
+	require.Empty(t, changes)
+	require.Equal(t, 1, unchanged)
+}
+
+func TestClient_SyncSecurityGroupRules(t *testing.T) {
+	current := response{200, jsonContentType, `
+{"listsecuritygroupsresponse": {
+	"count": 1,
+	"securitygroup": [{
+		"id": "4bfe1073-a6d4-48bd-8f24-2ab586674092",
+		"name": "web",
+		"ingressrule": [
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab25", "protocol": "tcp", "startport": 22, "endport": 22, "cidr": "0.0.0.0/0"},
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab26", "protocol": "tcp", "startport": 80, "endport": 80, "cidr": "0.0.0.0/0"}
+		],
+		"egressrule": [
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab27", "protocol": "udp", "startport": 53, "endport": 53, "cidr": "0.0.0.0/0"}
+		]
+	}]
+}}`}
+
+	synced := response{200, jsonContentType, `
+{"listsecuritygroupsresponse": {
+	"count": 1,
+	"securitygroup": [{
+		"id": "4bfe1073-a6d4-48bd-8f24-2ab586674092",
+		"name": "web",
+		"ingressrule": [
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab26", "protocol": "tcp", "startport": 80, "endport": 80, "cidr": "0.0.0.0/0"},
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab28", "protocol": "tcp", "startport": 22, "endport": 22, "cidr": "203.0.113.0/24"},
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab29", "protocol": "tcp", "startport": 8000, "endport": 8080, "securitygroupname": "lb"}
+		],
+		"egressrule": [
+			{"ruleid": "fc03b5b1-1d15-4933-99c3-afa0b8f2ab27", "protocol": "udp", "startport": 53, "endport": 53, "cidr": "0.0.0.0/0"}
+		]
+	}]
+}}`}
+
+	authorized := response{200, jsonContentType, `
+{"authorizesecuritygroupingressresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"securitygroup": {}},
+	"jobstatus": 1
+}}`}
+
+	ts := newServer(
+		// Dry-run
+		current,
+		// Synchronisation
+		current,
+		authorized,
+		authorized,
+		response{200, jsonContentType, `
+{"revokesecuritygroupingressresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880ce",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`},
+		synced,
+		// No-op synchronisation
+		synced,
+		// Failed authorization
+		synced,
+		response{200, jsonContentType, `
+{"authorizesecuritygroupegressresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cf",
+	"jobresult": {"errorcode": 431, "errortext": "o noes"},
+	"jobstatus": 2
+}}`},
+		synced,
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	desiredIngress := []IngressRule{
+		{Protocol: "tcp", StartPort: 22, EndPort: 22, CIDR: MustParseCIDR("203.0.113.0/24")},
+		{Protocol: "tcp", StartPort: 80, EndPort: 80, CIDR: MustParseCIDR("0.0.0.0/0")},
+		{Protocol: "tcp", StartPort: 8000, EndPort: 8080, SecurityGroupName: "lb"},
+	}
+	desiredEgress := []EgressRule{
+		{Protocol: "udp", StartPort: 53, EndPort: 53, CIDR: MustParseCIDR("0.0.0.0/0")},
+	}
+
+	// In dry-run mode the changes must only be reported
+	var output bytes.Buffer
+	report, err := client.SyncSecurityGroupRules(context.Background(), &SecurityGroup{Name: "web"},
+		desiredIngress, desiredEgress, SyncSecurityGroupRulesOptions{DryRun: true, PlanOutput: &output})
+	require.NoError(t, err)
+	require.Equal(t, `+ ingress tcp/22 from 203.0.113.0/24
++ ingress tcp/8000-8080 from sg:lb
+- ingress tcp/22 from 0.0.0.0/0
+`, output.String())
+	require.Equal(t, 2, report.Unchanged)
+	require.Equal(t, 1, ts.lastResponse)
+
+	// The changes are applied one at a time, in order
+	report, err = client.SyncSecurityGroupRules(context.Background(), &SecurityGroup{Name: "web"},
+		desiredIngress, desiredEgress, SyncSecurityGroupRulesOptions{Concurrency: 1})
+	require.NoError(t, err)
+	for _, c := range report.Changes {
+		require.True(t, c.Applied)
+		require.NoError(t, c.Err)
+	}
+	require.Len(t, report.SecurityGroup.IngressRule, 3)
+	require.Len(t, report.SecurityGroup.EgressRule, 1)
+	require.Equal(t, 6, ts.lastResponse)
+
+	// Synchronising again must be a no-op
+	report, err = client.SyncSecurityGroupRules(context.Background(), &SecurityGroup{Name: "web"},
+		desiredIngress, desiredEgress, SyncSecurityGroupRulesOptions{})
+	require.NoError(t, err)
+	require.True(t, report.Empty())
+	require.Equal(t, 7, ts.lastResponse)
+
+	// Revocations must be skipped if an authorization fails
+	report, err = client.SyncSecurityGroupRules(context.Background(), &SecurityGroup{Name: "web"},
+		nil, []EgressRule{{Protocol: "tcp", StartPort: 443, CIDR: MustParseCIDR("0.0.0.0/0")}},
+		SyncSecurityGroupRulesOptions{})
+	require.Error(t, err)
+	require.Len(t, report.Changes, 5)
+	require.Error(t, report.Changes[0].Err)
+	for _, c := range report.Changes[1:] {
+		require.False(t, c.Applied)
+	}
+	require.Equal(t, 10, ts.lastResponse)
+
+	// Invalid rules must be rejected
+	_, err = client.SyncSecurityGroupRules(context.Background(), &SecurityGroup{Name: "web"},
+		[]IngressRule{{Protocol: "tcp", StartPort: 22}}, nil, SyncSecurityGroupRulesOptions{})
+	require.Error(t, err)
+	require.Equal(t, 10, ts.lastResponse)
+}
@@ -0,0 +1,305 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotRetentionPeriod represents a retention period of a snapshot retention policy.
+type snapshotRetentionPeriod struct {
+	name   string
+	field  string
+	bucket func(time.Time) string
+}
+
+var snapshotRetentionPeriods = []snapshotRetentionPeriod{
+	{"hourly", "Hourly", func(t time.Time) string { return t.Format("2006-01-02T15") }},
+	{"daily", "Daily", func(t time.Time) string { return t.Format("2006-01-02") }},
+	{"weekly", "Weekly", func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}},
+	{"monthly", "Monthly", func(t time.Time) string { return t.Format("2006-01") }},
+	{"yearly", "Yearly", func(t time.Time) string { return t.Format("2006") }},
+}
+
+// SnapshotRetentionPolicy represents a rolling retention policy for the snapshots of a volume, e.g.
+// "keep 7 daily, 4 weekly and 12 monthly snapshots": for each period, the most recent snapshot of
+// each of the last N periods having a snapshot is kept.
+type SnapshotRetentionPolicy struct {
+	// Tag identifies the snapshots managed by the policy: only the snapshots carrying this tag are
+	// considered, and the snapshots created are tagged with it
+	Tag ResourceTag
+	// Hourly, Daily, Weekly, Monthly and Yearly are the numbers of snapshots to keep per period
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+	// Location is the time zone in which periods are computed (default: UTC)
+	Location *time.Location
+}
+
+func (p SnapshotRetentionPolicy) keep(period string) int {
+	switch period {
+	case "hourly":
+		return p.Hourly
+	case "daily":
+		return p.Daily
+	case "weekly":
+		return p.Weekly
+	case "monthly":
+		return p.Monthly
+	case "yearly":
+		return p.Yearly
+	}
+
+	return 0
+}
+
+// Validate checks that the policy is applicable.
+func (p SnapshotRetentionPolicy) Validate() error {
+	if p.Tag.Key == "" {
+		return &ValidationError{Field: "Tag", Reason: "a tag key is required to identify the snapshots managed"}
+	}
+
+	total := 0
+	for _, period := range snapshotRetentionPeriods {
+		n := p.keep(period.name)
+		if n < 0 {
+			return &ValidationError{Field: period.field, Reason: "must be positive"}
+		}
+		total += n
+	}
+	if total == 0 {
+		return &ValidationError{Field: "Daily", Reason: "at least one period must retain snapshots"}
+	}
+
+	return nil
+}
+
+// manages returns true if the snapshot carries the policy tag.
+func (p SnapshotRetentionPolicy) manages(snapshot Snapshot) bool {
+	for _, tag := range snapshot.Tags {
+		if tag.Key == p.Tag.Key && tag.Value == p.Tag.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetainedSnapshot represents a snapshot kept by a retention policy.
+type RetainedSnapshot struct {
+	Snapshot Snapshot
+	// Periods lists the periods retaining the snapshot (e.g. "daily", "weekly")
+	Periods []string
+}
+
+// SnapshotRetentionPlan represents the result of a snapshot retention policy evaluation for a volume.
+type SnapshotRetentionPlan struct {
+	VolumeID *UUID
+	// Create is true if a new snapshot is due, no snapshot having been taken in the current
+	// shortest period of the policy
+	Create bool
+	// Keep lists the snapshots retained, most recent first
+	Keep []RetainedSnapshot
+	// Prune lists the snapshots to delete, most recent first
+	Prune []Snapshot
+	// Ignored lists the snapshots left untouched as not BackedUp (or without creation date)
+	Ignored []Snapshot
+
+	// Created is the snapshot created, if any
+	Created *Snapshot
+	// Pruned lists the snapshots actually deleted
+	Pruned []Snapshot
+}
+
+// String returns a human-readable description of the plan, one action per line.
+func (p *SnapshotRetentionPlan) String() string {
+	var b strings.Builder
+
+	if p.Create {
+		fmt.Fprintf(&b, "+ create snapshot of volume %s\n", p.VolumeID)
+	}
+	for _, r := range p.Keep {
+		fmt.Fprintf(&b, "= keep %s (%s): %s\n", r.Snapshot.ID, r.Snapshot.Created, strings.Join(r.Periods, ", "))
+	}
+	for _, ss := range p.Prune {
+		fmt.Fprintf(&b, "- prune %s (%s)\n", ss.ID, ss.Created)
+	}
+	for _, ss := range p.Ignored {
+		fmt.Fprintf(&b, "? ignore %s (%s): %s\n", ss.ID, ss.Created, ss.State)
+	}
+
+	return b.String()
+}
+
+// Plan evaluates the policy against the snapshots of a volume at the specified time. Snapshots not
+// carrying the policy tag are disregarded; snapshots not in the BackedUp state are never pruned
+// nor counted as retained.
+func (p SnapshotRetentionPolicy) Plan(volumeID *UUID, snapshots []Snapshot, now time.Time) (*SnapshotRetentionPlan, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	location := p.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	type datedSnapshot struct {
+		Snapshot
+		created time.Time
+	}
+
+	plan := SnapshotRetentionPlan{VolumeID: volumeID}
+	candidates := make([]datedSnapshot, 0)
+	var latest time.Time
+
+	for _, ss := range snapshots {
+		if !p.manages(ss) {
+			continue
+		}
+
+		created, err := ss.CreatedAt()
+		if err != nil {
+			plan.Ignored = append(plan.Ignored, ss)
+			continue
+		}
+		created = created.In(location)
+
+		if ss.State != string(Error) && created.After(latest) {
+			latest = created
+		}
+
+		if ss.State != string(BackedUp) {
+			plan.Ignored = append(plan.Ignored, ss)
+			continue
+		}
+
+		candidates = append(candidates, datedSnapshot{ss, created})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].created.After(candidates[j].created)
+	})
+
+	// A snapshot is due if none was taken during the current shortest period.
+	now = now.In(location)
+	for _, period := range snapshotRetentionPeriods {
+		if p.keep(period.name) == 0 {
+			continue
+		}
+		plan.Create = latest.IsZero() || period.bucket(latest) != period.bucket(now)
+		break
+	}
+
+	retained := make(map[int][]string)
+	for _, period := range snapshotRetentionPeriods {
+		n := p.keep(period.name)
+		seen := make(map[string]bool)
+
+		for i, ss := range candidates {
+			if len(seen) >= n {
+				break
+			}
+
+			bucket := period.bucket(ss.created)
+			if seen[bucket] {
+				continue
+			}
+			seen[bucket] = true
+			retained[i] = append(retained[i], period.name)
+		}
+	}
+
+	for i, ss := range candidates {
+		if periods, ok := retained[i]; ok {
+			plan.Keep = append(plan.Keep, RetainedSnapshot{Snapshot: ss.Snapshot, Periods: periods})
+			continue
+		}
+		plan.Prune = append(plan.Prune, ss.Snapshot)
+	}
+
+	return &plan, nil
+}
+
+// SnapshotRetentionOptions represents the options of ApplySnapshotRetentionPolicy.
+type SnapshotRetentionOptions struct {
+	// DryRun computes the plan without creating nor deleting snapshots
+	DryRun bool
+	// Now returns the current time (default: time.Now)
+	Now func() time.Time
+}
+
+// ApplySnapshotRetentionPolicy evaluates the retention policy for the specified volume (see
+// SnapshotRetentionPolicy.Plan), then creates a new tagged snapshot if one is due and deletes the
+// snapshots no longer retained. It performs a single pass, so that it can be run periodically
+// (e.g. from a cron job or a long-running loop). Pruning is skipped if the snapshot creation fails.
+func (client *Client) ApplySnapshotRetentionPolicy(ctx context.Context, volumeID *UUID, policy SnapshotRetentionPolicy,
+	opts SnapshotRetentionOptions) (*SnapshotRetentionPlan, error) {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+
+	items, err := client.ListWithContext(ctx, &Snapshot{VolumeID: volumeID, Tags: []ResourceTag{policy.Tag}})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, len(items))
+	for i := range items {
+		snapshots[i] = *items[i].(*Snapshot)
+	}
+
+	plan, err := policy.Plan(volumeID, snapshots, now())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	if plan.Create {
+		resp, err := client.RequestWithContext(ctx, &CreateSnapshot{VolumeID: volumeID})
+		if err != nil {
+			return plan, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+		plan.Created = resp.(*Snapshot)
+
+		if err := client.BooleanRequestWithContext(ctx, &CreateTags{
+			ResourceIDs:  []UUID{*plan.Created.ID},
+			ResourceType: plan.Created.ResourceType(),
+			Tags:         []ResourceTag{{Key: policy.Tag.Key, Value: policy.Tag.Value}},
+		}); err != nil {
+			// An untagged snapshot is never selected by the policy, so it would never be pruned
+			id := plan.Created.ID
+			if delErr := client.BooleanRequestWithContext(ctx, &DeleteSnapshot{ID: id}); delErr != nil {
+				return plan, fmt.Errorf("unable to tag snapshot %s: %s, and unable to delete it: %s", id, err, delErr)
+			}
+			plan.Created = nil
+			return plan, fmt.Errorf("unable to tag snapshot %s, deleted: %s", id, err)
+		}
+	}
+
+	var failed []string
+	for _, ss := range plan.Prune {
+		if err := client.BooleanRequestWithContext(ctx, &DeleteSnapshot{ID: ss.ID}); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", ss.ID, err))
+			continue
+		}
+		plan.Pruned = append(plan.Pruned, ss)
+	}
+
+	if len(failed) > 0 {
+		return plan, fmt.Errorf("unable to prune %d snapshot(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,196 @@
+package egoscale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSnapshotRetentionTag = ResourceTag{Key: "retention", Value: "default"}
+
+func testSnapshotRetentionSnapshot(i int, created time.Time, state SnapshotState, tagged bool) Snapshot {
+	ss := Snapshot{
+		ID:      MustParseUUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", i)),
+		Created: created.Format(createdFormat),
+		State:   string(state),
+	}
+	if tagged {
+		ss.Tags = []ResourceTag{testSnapshotRetentionTag}
+	}
+	return ss
+}
+
+func TestSnapshotRetentionPolicy_Plan(t *testing.T) {
+	var (
+		volumeID  = MustParseUUID("6b6a6f6e-2d6d-4d1b-9e5b-5b9d3f3d1a01")
+		now       = time.Date(2020, 3, 31, 12, 0, 0, 0, time.UTC)
+		snapshots []Snapshot
+	)
+
+	day := time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 90; i++ {
+		snapshots = append(snapshots, testSnapshotRetentionSnapshot(i, day.AddDate(0, 0, i), BackedUp, true))
+	}
+	snapshots = append(snapshots,
+		// Not managed by the policy
+		testSnapshotRetentionSnapshot(100, day.AddDate(-1, 0, 0), BackedUp, false),
+		// Not backed up, thus never pruned
+		testSnapshotRetentionSnapshot(101, day.AddDate(-1, 0, 0), BackingUp, true),
+		testSnapshotRetentionSnapshot(102, day.AddDate(-1, 0, 0), Error, true),
+	)
+
+	policy := SnapshotRetentionPolicy{Tag: testSnapshotRetentionTag, Daily: 7, Weekly: 4, Monthly: 3}
+	plan, err := policy.Plan(volumeID, snapshots, now)
+	require.NoError(t, err)
+	require.True(t, plan.Create)
+
+	kept := make([]string, len(plan.Keep))
+	for i, r := range plan.Keep {
+		created, err := r.Snapshot.CreatedAt()
+		require.NoError(t, err)
+		kept[i] = fmt.Sprintf("%s %s", created.Format("01-02"), strings.Join(r.Periods, ","))
+	}
+	require.Equal(t, []string{
+		"03-30 daily,weekly,monthly",
+		"03-29 daily,weekly",
+		"03-28 daily",
+		"03-27 daily",
+		"03-26 daily",
+		"03-25 daily",
+		"03-24 daily",
+		"03-22 weekly",
+		"03-15 weekly",
+		"02-29 monthly",
+		"01-31 monthly",
+	}, kept)
+	require.Len(t, plan.Prune, 79)
+	require.Len(t, plan.Ignored, 2)
+	for _, ss := range plan.Prune {
+		require.Equal(t, string(BackedUp), ss.State)
+	}
+
+	// A snapshot has already been taken today
+	plan, err = policy.Plan(volumeID, snapshots, time.Date(2020, 3, 30, 23, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.False(t, plan.Create)
+
+	// Periods are computed in the policy time zone
+	policy.Location = time.FixedZone("UTC+5", 5*3600)
+	plan, err = policy.Plan(volumeID, snapshots, time.Date(2020, 3, 30, 23, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, plan.Create)
+
+	_, err = SnapshotRetentionPolicy{Daily: 7}.Plan(volumeID, snapshots, now)
+	require.Error(t, err)
+	_, err = SnapshotRetentionPolicy{Tag: testSnapshotRetentionTag}.Plan(volumeID, snapshots, now)
+	require.Error(t, err)
+	_, err = SnapshotRetentionPolicy{Tag: testSnapshotRetentionTag, Daily: 7, Weekly: -1}.Plan(volumeID, snapshots, now)
+	require.EqualError(t, err, "invalid Weekly: must be positive")
+}
+
+func testListSnapshotsResponse(t *testing.T, snapshots ...Snapshot) response {
+	b, err := json.Marshal(ListSnapshotsResponse{Count: len(snapshots), Snapshot: snapshots})
+	require.NoError(t, err)
+	return response{200, jsonContentType, `{"listsnapshotsresponse": ` + string(b) + `}`}
+}
+
+func TestClient_ApplySnapshotRetentionPolicy(t *testing.T) {
+	var (
+		volumeID  = MustParseUUID("6b6a6f6e-2d6d-4d1b-9e5b-5b9d3f3d1a01")
+		now       = time.Date(2020, 3, 31, 12, 0, 0, 0, time.UTC)
+		snapshots []Snapshot
+	)
+
+	for i := 0; i < 5; i++ {
+		snapshots = append(snapshots, testSnapshotRetentionSnapshot(i, now.AddDate(0, 0, i-5), BackedUp, true))
+	}
+	created := testSnapshotRetentionSnapshot(1000, now, BackedUp, true)
+
+	deleted := response{200, jsonContentType, `
+{"deletesnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`}
+
+	ts := newServer(
+		// Dry-run
+		testListSnapshotsResponse(t, snapshots...),
+		// Snapshot creation and pruning
+		testListSnapshotsResponse(t, snapshots...),
+		response{200, jsonContentType, `
+{"createsnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880ce",
+	"jobresult": {"snapshot": {"id": "00000000-0000-0000-0000-000000001000", "created": "2020-03-31T12:00:00+0000", "state": "BackedUp"}},
+	"jobstatus": 1
+}}`},
+		response{200, jsonContentType, `
+{"createtagsresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cf",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`},
+		deleted,
+		deleted,
+		// Same day, pruning only
+		testListSnapshotsResponse(t, append(snapshots[2:], created)...),
+		deleted,
+		// Tagging failure
+		testListSnapshotsResponse(t, append(snapshots[3:], created)...),
+		response{200, jsonContentType, `
+{"createsnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d0",
+	"jobresult": {"snapshot": {"id": "00000000-0000-0000-0000-000000001001", "created": "2020-04-01T12:00:00+0000", "state": "BackedUp"}},
+	"jobstatus": 1
+}}`},
+		response{200, jsonContentType, `
+{"createtagsresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d1",
+	"jobresult": {"errorcode": 431, "errortext": "o noes"},
+	"jobstatus": 2
+}}`},
+		deleted,
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+	policy := SnapshotRetentionPolicy{Tag: testSnapshotRetentionTag, Daily: 3}
+	opts := SnapshotRetentionOptions{Now: func() time.Time { return now }}
+
+	// In dry-run mode the plan must only be reported
+	plan, err := client.ApplySnapshotRetentionPolicy(context.Background(), volumeID, policy,
+		SnapshotRetentionOptions{DryRun: true, Now: opts.Now})
+	require.NoError(t, err)
+	require.True(t, plan.Create)
+	require.Len(t, plan.Prune, 2)
+	require.Contains(t, plan.String(), "- prune 00000000-0000-0000-0000-000000000000 (2020-03-26T12:00:00+0000)\n")
+	require.Equal(t, 1, ts.lastResponse)
+
+	plan, err = client.ApplySnapshotRetentionPolicy(context.Background(), volumeID, policy, opts)
+	require.NoError(t, err)
+	require.NotNil(t, plan.Created)
+	require.Equal(t, created.ID.String(), plan.Created.ID.String())
+	require.Len(t, plan.Pruned, 2)
+	require.Equal(t, 6, ts.lastResponse)
+
+	// Running again within the same day must prune the oldest snapshot only
+	plan, err = client.ApplySnapshotRetentionPolicy(context.Background(), volumeID, policy, opts)
+	require.NoError(t, err)
+	require.False(t, plan.Create)
+	require.Len(t, plan.Pruned, 1)
+	require.Equal(t, snapshots[2].ID.String(), plan.Pruned[0].ID.String())
+	require.Equal(t, 8, ts.lastResponse)
+
+	// A snapshot which cannot be tagged is deleted
+	opts.Now = func() time.Time { return now.AddDate(0, 0, 1) }
+	plan, err = client.ApplySnapshotRetentionPolicy(context.Background(), volumeID, policy, opts)
+	require.Error(t, err)
+	require.Nil(t, plan.Created)
+	require.Empty(t, plan.Pruned)
+	require.Equal(t, 12, ts.lastResponse)
+}
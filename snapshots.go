@@ -1,5 +1,10 @@
 package egoscale
 
+import "time"
+
+// createdFormat is the format of the Created field of the API V1 resources.
+const createdFormat = "2006-01-02T15:04:05-0700"
+
 // SnapshotState represents the Snapshot.State enum
 //
 // See: https://github.com/apache/cloudstack/blob/master/api/src/main/java/com/cloud/storage/Snapshot.java
@@ -51,6 +56,11 @@ func (Snapshot) ResourceType() string {
 	return "Snapshot"
 }
 
+// CreatedAt returns the creation date of the snapshot.
+func (ss Snapshot) CreatedAt() (time.Time, error) {
+	return time.Parse(createdFormat, ss.Created)
+}
+
 // CreateSnapshot (Async) creates an instant snapshot of a volume
 type CreateSnapshot struct {
 	VolumeID  *UUID `json:"volumeid" doc:"The ID of the disk volume"`
@@ -77,7 +87,7 @@ func (ss Snapshot) ListRequest() (ListCommand, error) {
 		VolumeID:     ss.VolumeID,
 		SnapshotType: ss.SnapshotType,
 		ZoneID:       ss.ZoneID,
-		// TODO: tags
+		Tags:         ss.Tags,
 	}
 
 	return req, nil
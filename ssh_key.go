@@ -0,0 +1,217 @@
+package egoscale
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultSSHKeyRSABits is the default size of the RSA SSH keys generated.
+const defaultSSHKeyRSABits = 4096
+
+// SSHKeyType represents the type of an SSH key.
+type SSHKeyType string
+
+const (
+	// SSHKeyTypeRSA represents an RSA SSH key
+	SSHKeyTypeRSA SSHKeyType = "ssh-rsa"
+	// SSHKeyTypeEd25519 represents an Ed25519 SSH key
+	SSHKeyTypeEd25519 SSHKeyType = "ssh-ed25519"
+)
+
+// SSHKey represents a locally generated SSH key, whose private half never leaves the host.
+type SSHKey struct {
+	Type SSHKeyType
+	// PrivateKey is either an *rsa.PrivateKey or an ed25519.PrivateKey
+	PrivateKey interface{}
+	// PublicKey is the public key in the OpenSSH authorized_keys format
+	PublicKey string
+}
+
+// GenerateSSHKey generates an SSH key of the specified type locally. The size in bits applies to
+// RSA keys only (default: 4096); comment is appended to the public key if not empty.
+func GenerateSSHKey(keyType SSHKeyType, bits int, comment string) (*SSHKey, error) {
+	var (
+		key       = SSHKey{Type: keyType}
+		publicKey crypto.PublicKey
+	)
+
+	switch keyType {
+	case SSHKeyTypeRSA:
+		if bits == 0 {
+			bits = defaultSSHKeyRSABits
+		}
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+		publicKey = &privateKey.PublicKey
+
+	case SSHKeyTypeEd25519:
+		edPublicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+		publicKey = edPublicKey
+
+	default:
+		return nil, fmt.Errorf("unsupported SSH key type %q", keyType)
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey)))
+	if comment != "" {
+		key.PublicKey += " " + comment
+	}
+
+	return &key, nil
+}
+
+// Fingerprint returns the fingerprint of the key, in the format used by the API.
+func (key *SSHKey) Fingerprint() (string, error) {
+	return SSHKeyFingerprint(key.PublicKey)
+}
+
+// PrivateKeyPEM returns the unencrypted private key, PEM encoded in the format expected by
+// OpenSSH: PKCS#1 for RSA keys, OpenSSH format for Ed25519 keys.
+func (key *SSHKey) PrivateKeyPEM() ([]byte, error) {
+	switch privateKey := key.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}), nil
+
+	case ed25519.PrivateKey:
+		block, err := ssh.MarshalPrivateKey(privateKey, key.comment())
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(block), nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %T", key.PrivateKey)
+}
+
+func (key *SSHKey) comment() string {
+	if fields := strings.Fields(key.PublicKey); len(fields) > 2 {
+		return strings.Join(fields[2:], " ")
+	}
+	return ""
+}
+
+// SSHKeyFingerprint returns the fingerprint of a public key in the OpenSSH authorized_keys format,
+// in the format used by the API: the colon-separated hexadecimal MD5 sum of the key.
+func SSHKeyFingerprint(publicKey string) (string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)) // nolint: dogsled
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH public key: %s", err)
+	}
+	if strings.Fields(publicKey)[0] != key.Type() {
+		return "", errors.New("invalid SSH public key: key type mismatch")
+	}
+
+	return ssh.FingerprintLegacyMD5(key), nil
+}
+
+// RegisterSSHPublicKey registers the public key (in the OpenSSH authorized_keys format) under the
+// specified name, and checks that the fingerprint computed by the API matches the local one. On
+// mismatch the registered key pair is deleted before the error is returned.
+func (client *Client) RegisterSSHPublicKey(ctx context.Context, name, publicKey string) (*SSHKeyPair, error) {
+	fingerprint, err := SSHKeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.RequestWithContext(ctx, &RegisterSSHKeyPair{
+		Name:      name,
+		PublicKey: publicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair := resp.(*SSHKeyPair)
+	if !strings.EqualFold(keyPair.Fingerprint, fingerprint) {
+		err := fmt.Errorf("SSH key pair %q registered with fingerprint %s, expected %s",
+			name, keyPair.Fingerprint, fingerprint)
+		if delErr := client.BooleanRequestWithContext(ctx, &DeleteSSHKeyPair{Name: name}); delErr != nil {
+			return nil, fmt.Errorf("%s, and unable to delete it: %s", err, delErr)
+		}
+		return nil, err
+	}
+
+	return keyPair, nil
+}
+
+// FindSSHKeyPairByKeyFile returns the SSH key pair registered with the key stored in the specified
+// local file, whatever its name, or ErrNotFound. The file can be a public key in the OpenSSH
+// authorized_keys format, an unencrypted PEM encoded private key, or an OpenSSH private key
+// (possibly passphrase-protected, as its public half is stored in clear).
+func (client *Client) FindSSHKeyPairByKeyFile(ctx context.Context, path string) (*SSHKeyPair, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := readSSHKeyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	fingerprint := ssh.FingerprintLegacyMD5(publicKey)
+
+	keyPairs, err := client.ListWithContext(ctx, &SSHKeyPair{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range keyPairs {
+		keyPair := item.(*SSHKeyPair)
+		if strings.EqualFold(keyPair.Fingerprint, fingerprint) {
+			return keyPair, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// readSSHKeyFile returns the public key of an SSH key file.
+func readSSHKeyFile(data []byte) (ssh.PublicKey, error) {
+	if block, _ := pem.Decode(data); block == nil {
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data) // nolint: dogsled
+		return publicKey, err
+	}
+
+	privateKey, err := ssh.ParseRawPrivateKey(data)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) && missing.PublicKey != nil {
+			return missing.PublicKey, nil
+		}
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	return ssh.NewPublicKey(signer.Public())
+}
@@ -0,0 +1,252 @@
+package egoscale
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// defaultTemplateBootMode is the boot mode of the templates registered if none is specified.
+const defaultTemplateBootMode = "legacy"
+
+// TemplateImageUploader uploads a template image to a location the platform can download it
+// from, typically an object storage bucket.
+type TemplateImageUploader interface {
+	// Upload uploads the image content of the specified size, and returns its download URL. The
+	// content must be read entirely, as the image checksum is computed while it is read.
+	Upload(ctx context.Context, name string, content io.Reader, size int64) (string, error)
+}
+
+// PresignedURLUploader is a TemplateImageUploader uploading the image with an HTTP PUT request
+// to a pre-signed URL (e.g. generated for an object storage bucket).
+type PresignedURLUploader struct {
+	// PutURL is the pre-signed URL the image is uploaded to
+	PutURL string
+	// GetURL is the URL the platform downloads the image from
+	GetURL string
+	// HTTPClient is the HTTP client used to upload the image (default: http.DefaultClient)
+	HTTPClient *http.Client
+}
+
+// Upload implements the TemplateImageUploader interface.
+func (u *PresignedURLUploader) Upload(ctx context.Context, _ string, content io.Reader, size int64) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, u.PutURL, content)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+
+	httpClient := u.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unable to upload image: %s", resp.Status)
+	}
+
+	return u.GetURL, nil
+}
+
+// TemplateImage represents the image of a custom template: either a local file, or an image
+// already uploaded.
+type TemplateImage struct {
+	// Path is the path of a local image file, uploaded using RegisterTemplateOptions.Uploader
+	Path string
+	// URL is the URL of an already uploaded image
+	URL string
+	// Checksum is the MD5 checksum of the image, computed by streaming the image if empty
+	Checksum string
+}
+
+// RegisterTemplateOptions represents the options of RegisterTemplate.
+type RegisterTemplateOptions struct {
+	Name        string
+	DisplayText string
+	ZoneID      *UUID
+	// BootMode is the template boot mode, "legacy" or "uefi" (default: "legacy")
+	BootMode string
+	// PasswordEnabled indicates whether the template supports password reset (default: true)
+	PasswordEnabled *bool
+	// SSHKeyEnabled indicates whether the template supports SSH key injection (default: true)
+	SSHKeyEnabled *bool
+	Details       map[string]string
+	TemplateTag   string
+
+	// Uploader uploads local images, required for TemplateImage.Path
+	Uploader TemplateImageUploader
+	// Wait represents the options of the wait for the template to become ready
+	Wait WaitOptions
+}
+
+// TemplateRegistrationError represents the failure of a custom template registration.
+type TemplateRegistrationError struct {
+	// Template is the template registered, nil if the registration request failed
+	Template *Template
+	// Reason is the failure reason reported by the API
+	Reason string
+}
+
+// Error implements the error interface
+func (e *TemplateRegistrationError) Error() string {
+	if e.Template != nil {
+		return fmt.Sprintf("template %q (%s) registration failed: %s", e.Template.Name, e.Template.ID, e.Reason)
+	}
+
+	return fmt.Sprintf("template registration failed: %s", e.Reason)
+}
+
+// RegisterTemplate registers a custom template from a local image, uploaded first, or from an
+// already uploaded image. The image MD5 checksum is computed while streaming it, unless provided.
+// The template is registered with the options defaults, then RegisterTemplate waits for it to be
+// ready; a *TemplateRegistrationError carrying the reason reported by the API is returned if the
+// registration fails.
+func (client *Client) RegisterTemplate(ctx context.Context, image TemplateImage, opts RegisterTemplateOptions) (*Template, error) {
+	if opts.Name == "" {
+		return nil, &ValidationError{Field: "Name", Reason: "required"}
+	}
+	if opts.ZoneID == nil {
+		return nil, &ValidationError{Field: "ZoneID", Reason: "required"}
+	}
+	if (image.Path == "") == (image.URL == "") {
+		return nil, &ValidationError{Field: "Path", Reason: "exactly one of Path or URL must be set"}
+	}
+
+	url, checksum := image.URL, image.Checksum
+	switch {
+	case image.Path != "":
+		var err error
+		if url, checksum, err = client.uploadTemplateImage(ctx, image.Path, opts.Uploader); err != nil {
+			return nil, err
+		}
+		if image.Checksum != "" && image.Checksum != checksum {
+			return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", image.Checksum, checksum)
+		}
+
+	case checksum == "":
+		var err error
+		if checksum, err = client.templateImageChecksum(ctx, url); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &RegisterCustomTemplate{
+		Name:            opts.Name,
+		Displaytext:     opts.DisplayText,
+		ZoneID:          opts.ZoneID,
+		URL:             url,
+		Checksum:        checksum,
+		BootMode:        opts.BootMode,
+		PasswordEnabled: opts.PasswordEnabled,
+		SSHKeyEnabled:   opts.SSHKeyEnabled,
+		Details:         opts.Details,
+		TemplateTag:     opts.TemplateTag,
+	}
+	if req.Displaytext == "" {
+		req.Displaytext = opts.Name
+	}
+	if req.BootMode == "" {
+		req.BootMode = defaultTemplateBootMode
+	}
+	enabled := true
+	if req.PasswordEnabled == nil {
+		req.PasswordEnabled = &enabled
+	}
+	if req.SSHKeyEnabled == nil {
+		req.SSHKeyEnabled = &enabled
+	}
+
+	resp, err := client.RequestWithContext(ctx, req)
+	if err != nil {
+		if e, ok := err.(*ErrorResponse); ok {
+			return nil, &TemplateRegistrationError{Reason: e.ErrorText}
+		}
+		return nil, err
+	}
+
+	templates := *resp.(*[]Template)
+	if len(templates) == 0 {
+		return nil, &TemplateRegistrationError{Reason: "no template returned"}
+	}
+	template := &templates[0]
+
+	res, err := client.WaitFor(ctx, &ListTemplates{TemplateFilter: "self", ID: template.ID, ZoneID: opts.ZoneID},
+		TemplateIsReady(), opts.Wait)
+	if err != nil {
+		if current, ok := res.(*Template); ok && current != nil {
+			if _, ok := err.(*WaitTimeoutError); !ok {
+				return current, &TemplateRegistrationError{Template: current, Reason: current.Status}
+			}
+		}
+		return template, err
+	}
+
+	return res.(*Template), nil
+}
+
+// uploadTemplateImage uploads a local image, and returns its URL and MD5 checksum.
+func (client *Client) uploadTemplateImage(ctx context.Context, path string, uploader TemplateImageUploader) (string, string, error) {
+	if uploader == nil {
+		return "", "", &ValidationError{Field: "Uploader", Reason: "required to register a local image"}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close() // nolint: errcheck
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", "", err
+	}
+
+	h := md5.New()
+	url, err := uploader.Upload(ctx, filepath.Base(path), io.TeeReader(f, h), info.Size())
+	if err != nil {
+		return "", "", fmt.Errorf("unable to upload %s: %s", path, err)
+	}
+	if url == "" {
+		return "", "", errors.New("image uploader returned no URL")
+	}
+
+	return url, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// templateImageChecksum downloads an image, and returns its MD5 checksum.
+func (client *Client) templateImageChecksum(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("unable to download %s: %s", url, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
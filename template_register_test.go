@@ -0,0 +1,157 @@
+package egoscale
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testListTemplatesResponse(status string, ready bool) response {
+	isReady := "false"
+	if ready {
+		isReady = "true"
+	}
+
+	return response{200, jsonContentType, `
+{"listtemplatesresponse": {
+	"count": 1,
+	"template": [{
+		"id": "4c0732a0-3df0-4f66-8d16-009f91cf05d6",
+		"name": "test",
+		"zoneid": "1128bd56-b4d9-4ac6-a7b9-c715b187ce11",
+		"status": "` + status + `",
+		"isready": ` + isReady + `
+	}]
+}}`}
+}
+
+// newTemplateImageServer returns a server storing the image uploaded with PUT, and serving it with GET.
+func newTemplateImageServer(image *[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			*image, _ = ioutil.ReadAll(r.Body)
+		case http.MethodGet:
+			_, _ = w.Write(*image)
+		}
+	}))
+}
+
+func TestClient_RegisterTemplate(t *testing.T) {
+	var image []byte
+	images := newTemplateImageServer(&image)
+	defer images.Close()
+
+	registered := response{200, jsonContentType, `
+{"registercustomtemplateresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"template": [{
+		"id": "4c0732a0-3df0-4f66-8d16-009f91cf05d6",
+		"name": "test",
+		"zoneid": "1128bd56-b4d9-4ac6-a7b9-c715b187ce11"
+	}]},
+	"jobstatus": 1
+}}`}
+
+	ts := newServer(
+		// Local image
+		registered,
+		testListTemplatesResponse("", false),
+		testListTemplatesResponse("12% Downloaded", false),
+		testListTemplatesResponse("Download Complete", true),
+		// Already uploaded image
+		registered,
+		testListTemplatesResponse("Failed post download script: checksum mismatch", false),
+		// Registration request failure
+		response{200, jsonContentType, `
+{"registercustomtemplateresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880ce",
+	"jobresult": {"errorcode": 431, "errortext": "Unsupported image format"},
+	"jobstatus": 2
+}}`},
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+	zoneID := MustParseUUID("1128bd56-b4d9-4ac6-a7b9-c715b187ce11")
+	uploader := &PresignedURLUploader{PutURL: images.URL, GetURL: images.URL}
+
+	dir, err := ioutil.TempDir("", "egoscale")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	path := filepath.Join(dir, "image.qcow2")
+	require.NoError(t, ioutil.WriteFile(path, []byte("lolnope"), 0600))
+
+	template, err := client.RegisterTemplate(context.Background(), TemplateImage{Path: path}, RegisterTemplateOptions{
+		Name:     "test",
+		ZoneID:   zoneID,
+		Uploader: uploader,
+		Wait:     WaitOptions{Interval: time.Millisecond},
+	})
+	require.NoError(t, err)
+	require.True(t, template.IsReady)
+	require.Equal(t, []byte("lolnope"), image)
+	require.Equal(t, 4, ts.lastResponse)
+
+	// Already uploaded image: the checksum is computed by downloading it
+	_, err = client.RegisterTemplate(context.Background(), TemplateImage{URL: images.URL}, RegisterTemplateOptions{
+		Name:     "test",
+		ZoneID:   zoneID,
+		BootMode: "uefi",
+		Wait:     WaitOptions{Interval: time.Millisecond},
+	})
+	require.IsType(t, &TemplateRegistrationError{}, err)
+	require.Equal(t, "Failed post download script: checksum mismatch", err.(*TemplateRegistrationError).Reason)
+	require.Equal(t, 6, ts.lastResponse)
+
+	// Registration request failure
+	_, err = client.RegisterTemplate(context.Background(), TemplateImage{URL: images.URL, Checksum: "x"},
+		RegisterTemplateOptions{Name: "fail", ZoneID: zoneID})
+	require.EqualError(t, err, "template registration failed: Unsupported image format")
+	require.Equal(t, 7, ts.lastResponse)
+
+	_, err = client.RegisterTemplate(context.Background(), TemplateImage{Path: path},
+		RegisterTemplateOptions{Name: "test", ZoneID: zoneID})
+	require.IsType(t, &ValidationError{}, err)
+
+	_, err = client.RegisterTemplate(context.Background(), TemplateImage{}, RegisterTemplateOptions{Name: "test", ZoneID: zoneID})
+	require.IsType(t, &ValidationError{}, err)
+	require.Equal(t, 7, ts.lastResponse)
+}
+
+func TestClient_TemplateImageChecksum(t *testing.T) {
+	var image []byte
+	images := newTemplateImageServer(&image)
+	defer images.Close()
+
+	client := NewClient(images.URL, "KEY", "SECRET")
+
+	dir, err := ioutil.TempDir("", "egoscale")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	path := filepath.Join(dir, "image.qcow2")
+	require.NoError(t, ioutil.WriteFile(path, []byte("lolnope"), 0600))
+
+	// The checksum of a local image is computed while uploading it
+	url, checksum, err := client.uploadTemplateImage(context.Background(), path,
+		&PresignedURLUploader{PutURL: images.URL, GetURL: images.URL + "/image.qcow2"})
+	require.NoError(t, err)
+	require.Equal(t, images.URL+"/image.qcow2", url)
+	require.Equal(t, "3788a19bb69e254ceb11d63742789558", checksum)
+
+	checksum, err = client.templateImageChecksum(context.Background(), images.URL)
+	require.NoError(t, err)
+	require.Equal(t, "3788a19bb69e254ceb11d63742789558", checksum)
+
+	_, _, err = client.uploadTemplateImage(context.Background(), path, nil)
+	require.IsType(t, &ValidationError{}, err)
+}
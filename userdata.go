@@ -0,0 +1,235 @@
+package egoscale
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"text/template"
+)
+
+// UserDataMaxSize is the maximum size of the base64 encoded user-data accepted by the platform.
+const UserDataMaxSize = 32768
+
+// User-data parts content types, as understood by cloud-init.
+const (
+	// UserDataCloudConfig is the content type of cloud-config parts
+	UserDataCloudConfig = "text/cloud-config"
+	// UserDataShellScript is the content type of shell script parts, run once at the first boot
+	UserDataShellScript = "text/x-shellscript"
+	// UserDataIncludeURL is the content type of include file parts, listing URLs of user-data to fetch
+	UserDataIncludeURL = "text/x-include-url"
+	// UserDataBoothook is the content type of boothook parts, run at every boot
+	UserDataBoothook = "text/cloud-boothook"
+)
+
+// UserDataPart represents a part of a multipart user-data.
+type UserDataPart struct {
+	ContentType string
+	Filename    string
+	Content     string
+}
+
+// UserDataBuilder composes cloud-init user-data made of several parts (cloud-config, shell
+// scripts, include files...) into a multipart/mixed MIME document, optionally gzipped.
+//
+// The parts content can be Go templates (text/template), rendered with per-VM variables when the
+// user-data is built: this allows to deploy several virtual machines from the same builder.
+type UserDataBuilder struct {
+	parts []UserDataPart
+	gzip  bool
+}
+
+// NewUserDataBuilder returns an empty user-data builder.
+func NewUserDataBuilder() *UserDataBuilder {
+	return &UserDataBuilder{}
+}
+
+// WithGzip sets whether the user-data is gzipped, which roughly triples the amount of content
+// fitting within the platform size limit.
+func (b *UserDataBuilder) WithGzip(enabled bool) *UserDataBuilder {
+	b.gzip = enabled
+	return b
+}
+
+// AddPart appends a part of the specified content type to the user-data.
+func (b *UserDataBuilder) AddPart(contentType, filename, content string) *UserDataBuilder {
+	b.parts = append(b.parts, UserDataPart{
+		ContentType: contentType,
+		Filename:    filename,
+		Content:     content,
+	})
+	return b
+}
+
+// AddCloudConfig appends a cloud-config part to the user-data.
+func (b *UserDataBuilder) AddCloudConfig(content string) *UserDataBuilder {
+	if !strings.HasPrefix(content, "#cloud-config") {
+		content = "#cloud-config\n" + content
+	}
+	return b.AddPart(UserDataCloudConfig, fmt.Sprintf("cloud-config-%d.yaml", len(b.parts)), content)
+}
+
+// AddShellScript appends a shell script part to the user-data, executed once at the first boot.
+func (b *UserDataBuilder) AddShellScript(filename, content string) *UserDataBuilder {
+	if !strings.HasPrefix(content, "#!") {
+		content = "#!/bin/sh\n" + content
+	}
+	return b.AddPart(UserDataShellScript, filename, content)
+}
+
+// AddIncludeURLs appends an include file part to the user-data, instructing cloud-init to fetch
+// and process the user-data located at the specified URLs.
+func (b *UserDataBuilder) AddIncludeURLs(urls ...string) *UserDataBuilder {
+	return b.AddPart(UserDataIncludeURL, fmt.Sprintf("include-%d.txt", len(b.parts)),
+		"#include\n"+strings.Join(urls, "\n")+"\n")
+}
+
+// Build returns the user-data MIME document, gzipped if requested. If vars is not nil, the parts
+// content is rendered as a Go template with vars as data; referencing a missing map key is an
+// error.
+func (b *UserDataBuilder) Build(vars interface{}) ([]byte, error) {
+	if len(b.parts) == 0 {
+		return nil, errors.New("user-data has no parts")
+	}
+
+	parts := make([]UserDataPart, len(b.parts))
+	for i, part := range b.parts {
+		parts[i] = part
+
+		if vars == nil {
+			continue
+		}
+
+		tpl, err := template.New(part.Filename).Option("missingkey=error").Parse(part.Content)
+		if err != nil {
+			return nil, fmt.Errorf("user-data part %q: %s", part.Filename, err)
+		}
+
+		var content bytes.Buffer
+		if err := tpl.Execute(&content, vars); err != nil {
+			return nil, fmt.Errorf("user-data part %q: %s", part.Filename, err)
+		}
+		parts[i].Content = content.String()
+	}
+
+	// The boundary is derived from the parts content so that the same input always produces the
+	// same user-data, and cannot appear within the content.
+	h := sha1.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", part.ContentType, part.Filename, part.Content)
+	}
+	boundary := fmt.Sprintf("==%x==", h.Sum(nil))
+
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "Content-Type: multipart/mixed; boundary=%q\r\nMIME-Version: 1.0\r\n\r\n", boundary)
+
+	mw := multipart.NewWriter(&doc)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", fmt.Sprintf("%s; charset=%q", part.ContentType, "utf-8"))
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "8bit")
+		if part.Filename != "" {
+			header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", part.Filename))
+		}
+
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.Content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	if !b.gzip {
+		return doc.Bytes(), nil
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(doc.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return gz.Bytes(), nil
+}
+
+// Encode returns the user-data built (see Build) base64 encoded, as expected by the API. An error
+// is returned if the result exceeds the platform size limit (UserDataMaxSize).
+func (b *UserDataBuilder) Encode(vars interface{}) (string, error) {
+	data, err := b.Build(vars)
+	if err != nil {
+		return "", err
+	}
+
+	userData := base64.StdEncoding.EncodeToString(data)
+	if err := validateUserDataSize(userData); err != nil {
+		return "", err
+	}
+
+	return userData, nil
+}
+
+// SetUserData sets the request user-data from the builder, rendered with the specified variables.
+func (req *DeployVirtualMachine) SetUserData(b *UserDataBuilder, vars interface{}) error {
+	userData, err := b.Encode(vars)
+	if err != nil {
+		return err
+	}
+
+	req.UserData = userData
+	return nil
+}
+
+// SetUserData sets the request user-data from the builder, rendered with the specified variables.
+func (req *UpdateVirtualMachine) SetUserData(b *UserDataBuilder, vars interface{}) error {
+	userData, err := b.Encode(vars)
+	if err != nil {
+		return err
+	}
+
+	req.UserData = userData
+	return nil
+}
+
+// SetUserData sets the request user-data from the builder, rendered with the specified variables.
+// The user-data is shared by all the members of the Instance Pool.
+func (req *CreateInstancePool) SetUserData(b *UserDataBuilder, vars interface{}) error {
+	userData, err := b.Encode(vars)
+	if err != nil {
+		return err
+	}
+
+	req.UserData = userData
+	return nil
+}
+
+// validateUserDataSize checks that the base64 encoded user-data fits within the platform limit.
+func validateUserDataSize(userData string) error {
+	if len(userData) > UserDataMaxSize {
+		return &ValidationError{
+			Field:  "UserData",
+			Reason: fmt.Sprintf("encoded size of %d bytes exceeds the %d bytes limit", len(userData), UserDataMaxSize),
+		}
+	}
+
+	return nil
+}
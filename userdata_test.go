@@ -0,0 +1,93 @@
+package egoscale
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserDataBuilder(t *testing.T) {
+	b := NewUserDataBuilder().
+		AddCloudConfig("hostname: {{ .Name }}\n").
+		AddShellScript("setup.sh", "echo {{ .Role }} > /etc/role\n").
+		AddIncludeURLs("https://example.net/common.yaml")
+
+	data, err := b.Build(map[string]string{"Name": "web1", "Role": "web"})
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	var (
+		types    []string
+		contents []string
+	)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		content, err := ioutil.ReadAll(part)
+		require.NoError(t, err)
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+		contents = append(contents, string(content))
+	}
+	require.Equal(t, []string{UserDataCloudConfig, UserDataShellScript, UserDataIncludeURL}, types)
+	require.Equal(t, []string{
+		"#cloud-config\nhostname: web1\n",
+		"#!/bin/sh\necho web > /etc/role\n",
+		"#include\nhttps://example.net/common.yaml\n",
+	}, contents)
+
+	// The same input must always produce the same output
+	again, err := b.Build(map[string]string{"Name": "web1", "Role": "web"})
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+
+	// Missing template variables must be reported
+	_, err = b.Build(map[string]string{"Name": "web1"})
+	require.Error(t, err)
+
+	// Without variables the content is left as is
+	data, err = b.Build(nil)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "hostname: {{ .Name }}")
+
+	_, err = NewUserDataBuilder().Build(nil)
+	require.Error(t, err)
+}
+
+func TestUserDataBuilder_Encode(t *testing.T) {
+	b := NewUserDataBuilder().WithGzip(true).AddCloudConfig("hostname: {{ .Name }}\n")
+
+	req := &DeployVirtualMachine{}
+	require.NoError(t, req.SetUserData(b, map[string]string{"Name": "web1"}))
+
+	decoded, err := VirtualMachineUserData{UserData: req.UserData}.Decode()
+	require.NoError(t, err)
+	require.Contains(t, decoded, "#cloud-config\nhostname: web1\n")
+
+	// Once base64 encoded, the content exceeds the size limit
+	b = NewUserDataBuilder().AddShellScript("large.sh", strings.Repeat("a", UserDataMaxSize))
+
+	pool := &CreateInstancePool{}
+	err = pool.SetUserData(b, nil)
+	require.IsType(t, &ValidationError{}, err)
+	require.Empty(t, pool.UserData)
+
+	// The size limit must also be enforced before sending
+	_, err = NewClient("http://localhost", "KEY", "SECRET").
+		Request(&UpdateVirtualMachine{ID: MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01"),
+			UserData: strings.Repeat("A", UserDataMaxSize+4)})
+	require.IsType(t, &ValidationError{}, err)
+}
@@ -0,0 +1,339 @@
+package egoscale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CloneVirtualMachineStep represents a step of a virtual machine cloning.
+type CloneVirtualMachineStep string
+
+const (
+	// CloneStepSnapshot creates a snapshot of the source virtual machine ROOT volume
+	CloneStepSnapshot CloneVirtualMachineStep = "snapshot"
+	// CloneStepExport exports the snapshot
+	CloneStepExport CloneVirtualMachineStep = "export"
+	// CloneStepTemplate registers a template from the exported snapshot in the target zone
+	CloneStepTemplate CloneVirtualMachineStep = "template"
+	// CloneStepDeploy deploys the clone from the template
+	CloneStepDeploy CloneVirtualMachineStep = "deploy"
+	// CloneStepCleanup deletes the intermediate artefacts
+	CloneStepCleanup CloneVirtualMachineStep = "cleanup"
+)
+
+// CloneVirtualMachineCheckpoint represents the progress of a virtual machine cloning: the
+// artefacts produced by the steps completed. It can be persisted (e.g. as JSON) to resume an
+// interrupted cloning using CloneVirtualMachineOptions.Checkpoint.
+type CloneVirtualMachineCheckpoint struct {
+	SourceID         *UUID  `json:"sourceid"`
+	ZoneID           *UUID  `json:"zoneid"`
+	SnapshotID       *UUID  `json:"snapshotid,omitempty"`
+	ImageURL         string `json:"imageurl,omitempty"`
+	Checksum         string `json:"checksum,omitempty"`
+	TemplateID       *UUID  `json:"templateid,omitempty"`
+	VirtualMachineID *UUID  `json:"virtualmachineid,omitempty"`
+}
+
+// Step returns the last step completed, or an empty string if none.
+func (c CloneVirtualMachineCheckpoint) Step() CloneVirtualMachineStep {
+	switch {
+	case c.VirtualMachineID != nil:
+		return CloneStepDeploy
+	case c.TemplateID != nil:
+		return CloneStepTemplate
+	case c.ImageURL != "":
+		return CloneStepExport
+	case c.SnapshotID != nil:
+		return CloneStepSnapshot
+	}
+
+	return ""
+}
+
+// CloneVirtualMachineProgress represents a progress notification of a virtual machine cloning.
+type CloneVirtualMachineProgress struct {
+	Step CloneVirtualMachineStep
+	// Done is false when the step starts, true once it is completed
+	Done       bool
+	Checkpoint CloneVirtualMachineCheckpoint
+}
+
+// CloneVirtualMachineOptions represents the options of CloneVirtualMachine. The clone settings
+// default to the ones of the source virtual machine.
+type CloneVirtualMachineOptions struct {
+	Name              string
+	DisplayName       string
+	ServiceOfferingID *UUID
+	SecurityGroupIDs  []UUID
+	KeyPair           string
+	StartVM           *bool
+	// TemplateName is the name of the intermediate template (default: "<source name>-clone")
+	TemplateName string
+
+	// Checkpoint resumes a cloning from the steps already completed
+	Checkpoint *CloneVirtualMachineCheckpoint
+	// OnProgress is called when each step starts and completes
+	OnProgress func(CloneVirtualMachineProgress)
+	// KeepSnapshot keeps the intermediate snapshot once the clone is deployed
+	KeepSnapshot bool
+	// KeepOnFailure leaves the intermediate artefacts in place on failure, so that the cloning
+	// can be resumed from the checkpoint
+	KeepOnFailure bool
+	// Wait represents the options of the waits for the snapshot and the template to be ready
+	Wait WaitOptions
+}
+
+// CloneVirtualMachineError represents the failure of a virtual machine cloning.
+type CloneVirtualMachineError struct {
+	// Step is the step which failed
+	Step CloneVirtualMachineStep
+	// Checkpoint is the progress at the time of the failure, after the cleanup
+	Checkpoint CloneVirtualMachineCheckpoint
+	Err        error
+	// CleanupErrors lists the errors encountered deleting the intermediate artefacts
+	CleanupErrors []error
+}
+
+// Error implements the error interface
+func (e *CloneVirtualMachineError) Error() string {
+	msg := fmt.Sprintf("virtual machine %s cloning failed at step %s: %s", e.Checkpoint.SourceID, e.Step, e.Err)
+	if len(e.CleanupErrors) > 0 {
+		errs := make([]string, len(e.CleanupErrors))
+		for i := range e.CleanupErrors {
+			errs[i] = e.CleanupErrors[i].Error()
+		}
+		msg += fmt.Sprintf(" (cleanup failed: %s)", strings.Join(errs, "; "))
+	}
+
+	return msg
+}
+
+// Unwrap returns the error of the failed step
+func (e *CloneVirtualMachineError) Unwrap() error {
+	return e.Err
+}
+
+// CloneVirtualMachine clones a virtual machine to a zone, possibly the one of the source: its
+// ROOT volume is snapshotted, the snapshot is exported then registered as a template in the target
+// zone, from which the clone is deployed with the settings of the source virtual machine.
+//
+// On failure, a *CloneVirtualMachineError is returned, and the intermediate snapshot and template
+// are deleted unless opts.KeepOnFailure is set. Once the clone is deployed the snapshot is deleted
+// unless opts.KeepSnapshot is set, whereas the template is kept as the clone is based on it; a
+// failure to delete the snapshot is reported along with the clone.
+func (client *Client) CloneVirtualMachine(ctx context.Context, vmID, targetZoneID *UUID,
+	opts CloneVirtualMachineOptions) (*VirtualMachine, error) {
+	if vmID == nil {
+		return nil, &ValidationError{Field: "ID", Reason: "required"}
+	}
+	if targetZoneID == nil {
+		return nil, &ValidationError{Field: "ZoneID", Reason: "required"}
+	}
+
+	checkpoint := CloneVirtualMachineCheckpoint{SourceID: vmID, ZoneID: targetZoneID}
+	if opts.Checkpoint != nil {
+		if opts.Checkpoint.SourceID == nil || opts.Checkpoint.ZoneID == nil ||
+			!vmID.Equal(*opts.Checkpoint.SourceID) || !targetZoneID.Equal(*opts.Checkpoint.ZoneID) {
+			return nil, &ValidationError{Field: "Checkpoint", Reason: "source or target zone mismatch"}
+		}
+		checkpoint = *opts.Checkpoint
+	}
+
+	progress := func(step CloneVirtualMachineStep, done bool) {
+		if opts.OnProgress != nil {
+			opts.OnProgress(CloneVirtualMachineProgress{Step: step, Done: done, Checkpoint: checkpoint})
+		}
+	}
+
+	fail := func(step CloneVirtualMachineStep, err error) error {
+		cerr := &CloneVirtualMachineError{Step: step, Err: err}
+		if !opts.KeepOnFailure {
+			progress(CloneStepCleanup, false)
+			cerr.CleanupErrors = client.cleanupClone(&checkpoint, true)
+			progress(CloneStepCleanup, true)
+		}
+		cerr.Checkpoint = checkpoint
+		return cerr
+	}
+
+	resp, err := client.GetWithContext(ctx, &VirtualMachine{ID: vmID})
+	if err != nil {
+		return nil, err
+	}
+	source := resp.(*VirtualMachine)
+
+	// The clone has already been deployed
+	if checkpoint.VirtualMachineID != nil {
+		resp, err := client.GetWithContext(ctx, &VirtualMachine{ID: checkpoint.VirtualMachineID})
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*VirtualMachine), nil
+	}
+
+	if checkpoint.Step() == "" {
+		progress(CloneStepSnapshot, false)
+		if err := client.cloneSnapshot(ctx, source, &checkpoint, opts.Wait); err != nil {
+			return nil, fail(CloneStepSnapshot, err)
+		}
+		progress(CloneStepSnapshot, true)
+	}
+
+	if checkpoint.Step() == CloneStepSnapshot {
+		progress(CloneStepExport, false)
+		resp, err := client.RequestWithContext(ctx, &ExportSnapshot{ID: checkpoint.SnapshotID})
+		if err != nil {
+			return nil, fail(CloneStepExport, err)
+		}
+		export := resp.(*ExportSnapshotResponse)
+		checkpoint.ImageURL, checkpoint.Checksum = export.PresignedURL, export.MD5sum
+		progress(CloneStepExport, true)
+	}
+
+	if checkpoint.Step() == CloneStepExport {
+		progress(CloneStepTemplate, false)
+		if err := client.cloneTemplate(ctx, source, &checkpoint, opts); err != nil {
+			return nil, fail(CloneStepTemplate, err)
+		}
+		progress(CloneStepTemplate, true)
+	}
+
+	progress(CloneStepDeploy, false)
+	req := &DeployVirtualMachine{
+		Name:              opts.Name,
+		DisplayName:       opts.DisplayName,
+		ServiceOfferingID: opts.ServiceOfferingID,
+		SecurityGroupIDs:  opts.SecurityGroupIDs,
+		KeyPair:           opts.KeyPair,
+		StartVM:           opts.StartVM,
+		TemplateID:        checkpoint.TemplateID,
+		ZoneID:            targetZoneID,
+	}
+	if req.Name == "" {
+		req.Name = source.Name
+	}
+	if req.ServiceOfferingID == nil {
+		req.ServiceOfferingID = source.ServiceOfferingID
+	}
+	if req.SecurityGroupIDs == nil {
+		for _, sg := range source.SecurityGroup {
+			if sg.ID != nil {
+				req.SecurityGroupIDs = append(req.SecurityGroupIDs, *sg.ID)
+			}
+		}
+	}
+	if req.KeyPair == "" {
+		req.KeyPair = source.KeyPair
+	}
+
+	resp, err = client.RequestWithContext(ctx, req)
+	if err != nil {
+		return nil, fail(CloneStepDeploy, err)
+	}
+	vm := resp.(*VirtualMachine)
+	checkpoint.VirtualMachineID = vm.ID
+	progress(CloneStepDeploy, true)
+
+	if !opts.KeepSnapshot {
+		progress(CloneStepCleanup, false)
+		errs := client.cleanupClone(&checkpoint, false)
+		progress(CloneStepCleanup, true)
+		if len(errs) > 0 {
+			return vm, errs[0]
+		}
+	}
+
+	return vm, nil
+}
+
+// cloneSnapshot snapshots the ROOT volume of the source virtual machine, and waits for the
+// snapshot to be backed up.
+func (client *Client) cloneSnapshot(ctx context.Context, source *VirtualMachine, checkpoint *CloneVirtualMachineCheckpoint,
+	wait WaitOptions) error {
+	resp, err := client.GetWithContext(ctx, &Volume{VirtualMachineID: source.ID, Type: "ROOT"})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve ROOT volume: %s", err)
+	}
+
+	resp, err = client.RequestWithContext(ctx, &CreateSnapshot{VolumeID: resp.(*Volume).ID})
+	if err != nil {
+		return err
+	}
+	snapshot := resp.(*Snapshot)
+	checkpoint.SnapshotID = snapshot.ID
+
+	if snapshot.State != string(BackedUp) {
+		if _, err := client.WaitFor(ctx, &Snapshot{ID: snapshot.ID}, SnapshotStateIs(BackedUp), wait); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloneTemplate registers the exported snapshot as a template in the target zone, with the boot
+// settings of the template of the source virtual machine if it is still available.
+func (client *Client) cloneTemplate(ctx context.Context, source *VirtualMachine, checkpoint *CloneVirtualMachineCheckpoint,
+	opts CloneVirtualMachineOptions) error {
+	register := RegisterTemplateOptions{
+		Name:   opts.TemplateName,
+		ZoneID: checkpoint.ZoneID,
+		Wait:   opts.Wait,
+	}
+	if register.Name == "" {
+		register.Name = source.Name + "-clone"
+	}
+
+	resp, err := client.GetWithContext(ctx, &ListTemplates{
+		TemplateFilter: "executable",
+		ID:             source.TemplateID,
+		ZoneID:         source.ZoneID,
+	})
+	switch err {
+	case nil:
+		template := resp.(*Template)
+		register.BootMode = template.BootMode
+		register.PasswordEnabled = &template.PasswordEnabled
+		register.SSHKeyEnabled = &template.SSHKeyEnabled
+	case ErrNotFound:
+	default:
+		return fmt.Errorf("unable to retrieve source template: %s", err)
+	}
+
+	template, err := client.RegisterTemplate(ctx, TemplateImage{URL: checkpoint.ImageURL, Checksum: checkpoint.Checksum}, register)
+	if template != nil {
+		checkpoint.TemplateID = template.ID
+	}
+
+	return err
+}
+
+// cleanupClone deletes the intermediate snapshot, and the template if requested, removing them
+// from the checkpoint. The cleanup is performed with a context of its own, so that it happens
+// even if the cloning context is over.
+func (client *Client) cleanupClone(checkpoint *CloneVirtualMachineCheckpoint, template bool) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+
+	var errs []error
+
+	if template && checkpoint.TemplateID != nil {
+		if err := client.BooleanRequestWithContext(ctx, &DeleteTemplate{ID: checkpoint.TemplateID}); err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete template %s: %s", checkpoint.TemplateID, err))
+		} else {
+			checkpoint.TemplateID = nil
+		}
+	}
+
+	if checkpoint.SnapshotID != nil {
+		if err := client.BooleanRequestWithContext(ctx, &DeleteSnapshot{ID: checkpoint.SnapshotID}); err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete snapshot %s: %s", checkpoint.SnapshotID, err))
+		} else {
+			checkpoint.SnapshotID = nil
+			checkpoint.ImageURL, checkpoint.Checksum = "", ""
+		}
+	}
+
+	return errs
+}
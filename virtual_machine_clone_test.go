@@ -0,0 +1,183 @@
+package egoscale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCloneSourceID   = MustParseUUID("5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab001")
+	testCloneSnapshotID = MustParseUUID("5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab002")
+	testCloneTemplateID = MustParseUUID("5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab003")
+	testCloneVMID       = MustParseUUID("5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab004")
+	testCloneZoneID     = MustParseUUID("5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab005")
+)
+
+// testCloneResponses returns the API V1 responses of a virtual machine cloning, up to the
+// template registration included.
+func testCloneResponses() []response {
+	return []response{
+		{200, jsonContentType, `
+{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{
+		"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab001",
+		"name": "source",
+		"keypair": "me",
+		"serviceofferingid": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab006",
+		"securitygroup": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab007"}, {"name": "default"}],
+		"templateid": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab008"
+	}]
+}}`},
+		{200, jsonContentType, `
+{"listvolumesresponse": {
+	"count": 1,
+	"volume": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab009", "virtualmachineid": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab001"}]
+}}`},
+		{200, jsonContentType, `
+{"createsnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cd",
+	"jobresult": {"snapshot": {"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab002", "state": "BackingUp"}},
+	"jobstatus": 1
+}}`},
+		{200, jsonContentType, `
+{"listsnapshotsresponse": {
+	"count": 1,
+	"snapshot": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab002", "state": "BackedUp"}]
+}}`},
+		{200, jsonContentType, `
+{"exportsnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880ce",
+	"jobresult": {"snapshot": {"presignedurl": "https://sos.example.net/snapshot.qcow2", "md5sum": "3788a19bb69e254ceb11d63742789558"}},
+	"jobstatus": 1
+}}`},
+		{200, jsonContentType, `
+{"listtemplatesresponse": {
+	"count": 1,
+	"template": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab008", "bootmode": "uefi", "passwordenabled": true}]
+}}`},
+		{200, jsonContentType, `
+{"registercustomtemplateresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880cf",
+	"jobresult": {"template": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab003"}]},
+	"jobstatus": 1
+}}`},
+		{200, jsonContentType, `
+{"listtemplatesresponse": {
+	"count": 1,
+	"template": [{"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab003", "status": "Download Complete", "isready": true}]
+}}`},
+	}
+}
+
+func TestClient_CloneVirtualMachine(t *testing.T) {
+	ts := newServer(testCloneResponses()...)
+	ts.addResponse(
+		response{200, jsonContentType, `
+{"deployvirtualmachineresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d0",
+	"jobresult": {"virtualmachine": {"id": "5e7c6c6e-4b8a-4c3b-9b55-0f3d4f0ab004", "name": "clone"}},
+	"jobstatus": 1
+}}`},
+		response{200, jsonContentType, `
+{"deletesnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d1",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`},
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	var (
+		steps      []string
+		checkpoint CloneVirtualMachineCheckpoint
+	)
+	vm, err := client.CloneVirtualMachine(context.Background(), testCloneSourceID, testCloneZoneID, CloneVirtualMachineOptions{
+		Name: "clone",
+		Wait: WaitOptions{Interval: time.Millisecond},
+		OnProgress: func(p CloneVirtualMachineProgress) {
+			if p.Done {
+				steps = append(steps, string(p.Step))
+			}
+			if p.Step == CloneStepDeploy && p.Done {
+				checkpoint = p.Checkpoint
+			}
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, testCloneVMID, vm.ID)
+	require.Equal(t, []string{"snapshot", "export", "template", "deploy", "cleanup"}, steps)
+	require.Equal(t, 10, ts.lastResponse)
+
+	require.Equal(t, testCloneSnapshotID, checkpoint.SnapshotID)
+	require.Equal(t, "https://sos.example.net/snapshot.qcow2", checkpoint.ImageURL)
+	require.Equal(t, "3788a19bb69e254ceb11d63742789558", checkpoint.Checksum)
+	require.Equal(t, testCloneTemplateID, checkpoint.TemplateID)
+	require.Equal(t, testCloneVMID, checkpoint.VirtualMachineID)
+}
+
+func TestClient_CloneVirtualMachine_Failure(t *testing.T) {
+	deployFailed := response{200, jsonContentType, `
+{"deployvirtualmachineresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d0",
+	"jobresult": {"errorcode": 431, "errortext": "o noes"},
+	"jobstatus": 2
+}}`}
+
+	ts := newServer(testCloneResponses()...)
+	ts.addResponse(
+		deployFailed,
+		response{200, jsonContentType, `
+{"deletetemplateresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d1",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`},
+		response{200, jsonContentType, `
+{"deletesnapshotresponse": {
+	"jobid": "01ed7adc-8b81-4e33-a0f2-4f55a3b880d2",
+	"jobresult": {"success": true},
+	"jobstatus": 1
+}}`},
+		// Resuming from a checkpoint
+		testCloneResponses()[0],
+		deployFailed,
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	_, err := client.CloneVirtualMachine(context.Background(), testCloneSourceID, testCloneZoneID,
+		CloneVirtualMachineOptions{Wait: WaitOptions{Interval: time.Millisecond}})
+	require.IsType(t, &CloneVirtualMachineError{}, err)
+	cerr := err.(*CloneVirtualMachineError)
+	require.Equal(t, CloneStepDeploy, cerr.Step)
+	require.Empty(t, cerr.CleanupErrors)
+	require.Equal(t, CloneVirtualMachineStep(""), cerr.Checkpoint.Step())
+	require.Equal(t, 11, ts.lastResponse)
+
+	// Resuming from a checkpoint skips the steps completed, and the artefacts are kept on failure
+	checkpoint := CloneVirtualMachineCheckpoint{
+		SourceID:   testCloneSourceID,
+		ZoneID:     testCloneZoneID,
+		SnapshotID: testCloneSnapshotID,
+		ImageURL:   "https://sos.example.net/snapshot.qcow2",
+		Checksum:   "3788a19bb69e254ceb11d63742789558",
+		TemplateID: testCloneTemplateID,
+	}
+	_, err = client.CloneVirtualMachine(context.Background(), testCloneSourceID, testCloneZoneID,
+		CloneVirtualMachineOptions{Checkpoint: &checkpoint, KeepOnFailure: true})
+	require.IsType(t, &CloneVirtualMachineError{}, err)
+	require.Equal(t, checkpoint, err.(*CloneVirtualMachineError).Checkpoint)
+	require.Equal(t, 13, ts.lastResponse)
+
+	_, err = client.CloneVirtualMachine(context.Background(), testCloneSourceID, testCloneSourceID,
+		CloneVirtualMachineOptions{Checkpoint: &checkpoint})
+	require.IsType(t, &ValidationError{}, err)
+	require.Equal(t, 13, ts.lastResponse)
+}
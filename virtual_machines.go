@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/url"
+	"time"
 )
 
 // VirtualMachineState holds the state of the instance
@@ -145,6 +146,11 @@ func (vm VirtualMachine) ListRequest() (ListCommand, error) {
 	return req, nil
 }
 
+// CreatedAt returns the creation date of the virtual machine.
+func (vm VirtualMachine) CreatedAt() (time.Time, error) {
+	return time.Parse(createdFormat, vm.Created)
+}
+
 // DefaultNic returns the default nic
 func (vm VirtualMachine) DefaultNic() *Nic {
 	for i, nic := range vm.Nic {
@@ -225,9 +231,9 @@ type PCIDevice struct {
 	PCIDeviceName     string `json:"pcidevicename,omitempty" doc:"Device model name of pci card"`
 }
 
-// Password represents an encrypted password
+// Password represents an encrypted password, see Decrypt
 //
-// TODO: method to decrypt it, https://cwiki.apache.org/confluence/pages/viewpage.action?pageId=34014652
+// https://cwiki.apache.org/confluence/pages/viewpage.action?pageId=34014652
 type Password struct {
 	EncryptedPassword string `json:"encryptedpassword"`
 }
@@ -264,6 +270,7 @@ func (userdata VirtualMachineUserData) Decode() (string, error) {
 // DeployVirtualMachine (Async) represents the machine creation
 //
 // Regarding the UserData field, the client is responsible to base64 (and probably gzip) it. Doing it within this library would make the integration with other tools, e.g. Terraform harder.
+// SetUserData can be used to set it from a UserDataBuilder.
 type DeployVirtualMachine struct {
 	AffinityGroupIDs   []UUID            `json:"affinitygroupids,omitempty" doc:"comma separated list of affinity groups id that are going to be applied to the virtual machine. Mutually exclusive with affinitygroupnames parameter"`
 	AffinityGroupNames []string          `json:"affinitygroupnames,omitempty" doc:"comma separated list of affinity groups names that are going to be applied to the virtual machine.Mutually exclusive with affinitygroupids parameter"`
@@ -302,7 +309,7 @@ func (req DeployVirtualMachine) onBeforeSend(_ url.Values) error {
 		return fmt.Errorf("either SecurityGroupIDs or SecurityGroupNames must be set")
 	}
 
-	return nil
+	return validateUserDataSize(req.UserData)
 }
 
 // Response returns the struct to unmarshal
@@ -422,6 +429,10 @@ type UpdateVirtualMachine struct {
 	_                bool              `name:"updateVirtualMachine" description:"Updates properties of a virtual machine. The VM has to be stopped and restarted for the new properties to take effect. UpdateVirtualMachine does not first check whether the VM is stopped. Therefore, stop the VM manually before issuing this call."`
 }
 
+func (req UpdateVirtualMachine) onBeforeSend(_ url.Values) error {
+	return validateUserDataSize(req.UserData)
+}
+
 // Response returns the struct to unmarshal
 func (UpdateVirtualMachine) Response() interface{} {
 	return new(VirtualMachine)
@@ -0,0 +1,276 @@
+package egoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultWaitInterval is the default initial interval between two state checks.
+	defaultWaitInterval = 2 * time.Second
+	// defaultWaitMaxInterval is the default maximum interval between two state checks.
+	defaultWaitMaxInterval = 30 * time.Second
+	// defaultWaitMultiplier is the default growth factor of the interval between two state checks.
+	defaultWaitMultiplier = 2
+)
+
+// WaitPredicate checks the state of a resource waited for by WaitFor: it returns true if the
+// desired state is reached, the state observed for error reporting purposes, and a non-nil error
+// if the desired state can no longer be reached. The resource is nil if it could not be found.
+type WaitPredicate func(resource interface{}) (bool, string, error)
+
+// WaitOptions represents the options of WaitFor.
+type WaitOptions struct {
+	// Interval is the initial interval between two state checks (default: 2s)
+	Interval time.Duration
+	// MaxInterval is the maximum interval between two state checks (default: 30s)
+	MaxInterval time.Duration
+	// Multiplier is the growth factor of the interval after each check (default: 2)
+	Multiplier float64
+	// Timeout is the maximum duration to wait, in addition to the context deadline (default: none)
+	Timeout time.Duration
+}
+
+// WaitTimeoutError represents the failure to observe the desired state of a resource before the
+// context ended.
+type WaitTimeoutError struct {
+	// Resource is the type of the resource waited for
+	Resource string
+	// LastState is the last state observed, empty if the resource could not be found
+	LastState string
+	// Err is the context error
+	Err error
+}
+
+// Error implements the error interface
+func (e *WaitTimeoutError) Error() string {
+	state := e.LastState
+	if state == "" {
+		state = "not found"
+	}
+
+	return fmt.Sprintf("%s: gave up waiting for %s, last observed state: %s", e.Err, e.Resource, state)
+}
+
+// Unwrap returns the context error
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// WaitFor fetches the specified resource until the predicate is satisfied, backing off
+// exponentially between two checks, and returns the resource in its final state. The resource is
+// either a Listable identifying a single resource (e.g. &VirtualMachine{ID: id}), or an
+// *InstancePool with its ID and ZoneID set.
+//
+// A *WaitTimeoutError carrying the last observed state is returned if the context ends (or the
+// timeout expires) first, and the predicate error is returned as is if the desired state can no
+// longer be reached.
+func (client *Client) WaitFor(ctx context.Context, resource interface{}, predicate WaitPredicate, opts WaitOptions) (interface{}, error) {
+	fetch, err := client.waitFetcher(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWaitInterval
+	}
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = defaultWaitMaxInterval
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = defaultWaitMultiplier
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var (
+		interval  = opts.Interval
+		lastState string
+		name      = strings.TrimPrefix(fmt.Sprintf("%T", resource), "*egoscale.")
+	)
+
+	for {
+		current, err := fetch(ctx)
+		switch {
+		case err == ErrNotFound:
+			current = nil
+
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, &WaitTimeoutError{Resource: name, LastState: lastState, Err: ctx.Err()}
+			}
+			return nil, err
+		}
+
+		done, state, err := predicate(current)
+		lastState = state
+		if err != nil {
+			return current, err
+		}
+		if done {
+			return current, nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return current, &WaitTimeoutError{Resource: name, LastState: lastState, Err: ctx.Err()}
+		}
+
+		interval = time.Duration(float64(interval) * opts.Multiplier)
+		if interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// waitFetcher returns the function retrieving the current state of the resource waited for.
+func (client *Client) waitFetcher(resource interface{}) (func(context.Context) (interface{}, error), error) {
+	switch r := resource.(type) {
+	case *InstancePool:
+		if r.ID == nil || r.ZoneID == nil {
+			return nil, errors.New("the Instance Pool ID and ZoneID must be set")
+		}
+
+		return func(ctx context.Context) (interface{}, error) {
+			resp, err := client.RequestWithContext(ctx, &GetInstancePool{ID: r.ID, ZoneID: r.ZoneID})
+			if err != nil {
+				return nil, err
+			}
+
+			pools := resp.(*GetInstancePoolResponse).InstancePools
+			if len(pools) == 0 {
+				return nil, ErrNotFound
+			}
+
+			return &pools[0], nil
+		}, nil
+
+	case Listable:
+		return func(ctx context.Context) (interface{}, error) {
+			return client.GetWithContext(ctx, r)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unable to wait for a resource of type %T", resource)
+}
+
+// VirtualMachineStateIs returns a predicate satisfied when the virtual machine reaches one of the
+// specified states. Waiting fails if the virtual machine reaches the Error state.
+func VirtualMachineStateIs(states ...VirtualMachineState) WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		vm, ok := resource.(*VirtualMachine)
+		if !ok || vm == nil {
+			return false, "", nil
+		}
+
+		for _, state := range states {
+			if vm.State == string(state) {
+				return true, vm.State, nil
+			}
+		}
+
+		if vm.State == string(VirtualMachineError) {
+			return false, vm.State, fmt.Errorf("virtual machine %s is in state %s", vm.ID, vm.State)
+		}
+
+		return false, vm.State, nil
+	}
+}
+
+// SnapshotStateIs returns a predicate satisfied when the snapshot reaches one of the specified
+// states. Waiting fails if the snapshot reaches the Error state.
+func SnapshotStateIs(states ...SnapshotState) WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		snapshot, ok := resource.(*Snapshot)
+		if !ok || snapshot == nil {
+			return false, "", nil
+		}
+
+		for _, state := range states {
+			if snapshot.State == string(state) {
+				return true, snapshot.State, nil
+			}
+		}
+
+		if snapshot.State == string(Error) {
+			return false, snapshot.State, fmt.Errorf("snapshot %s is in state %s", snapshot.ID, snapshot.State)
+		}
+
+		return false, snapshot.State, nil
+	}
+}
+
+// TemplateIsReady returns a predicate satisfied when the template is ready to be deployed from.
+// The observed state is the template status as reported by the API; waiting fails if the status
+// reports an error (e.g. a failed download).
+func TemplateIsReady() WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		template, ok := resource.(*Template)
+		if !ok || template == nil {
+			return false, "", nil
+		}
+
+		if template.IsReady {
+			return true, "Ready", nil
+		}
+
+		state := template.Status
+		if state == "" {
+			state = "Not Ready"
+		}
+
+		status := strings.ToLower(template.Status)
+		if strings.Contains(status, "error") || strings.Contains(status, "fail") {
+			return false, state, fmt.Errorf("template %s is not ready: %s", template.ID, template.Status)
+		}
+
+		return false, state, nil
+	}
+}
+
+// InstancePoolStateIs returns a predicate satisfied when the Instance Pool reaches one of the
+// specified states.
+func InstancePoolStateIs(states ...InstancePoolState) WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		pool, ok := resource.(*InstancePool)
+		if !ok || pool == nil {
+			return false, "", nil
+		}
+
+		for _, state := range states {
+			if pool.State == state {
+				return true, string(pool.State), nil
+			}
+		}
+
+		return false, string(pool.State), nil
+	}
+}
+
+// InstancePoolStateIsNot returns a predicate satisfied when the Instance Pool is in none of the
+// specified states (e.g. once it is no longer scaling up).
+func InstancePoolStateIsNot(states ...InstancePoolState) WaitPredicate {
+	return func(resource interface{}) (bool, string, error) {
+		pool, ok := resource.(*InstancePool)
+		if !ok || pool == nil {
+			return false, "", nil
+		}
+
+		for _, state := range states {
+			if pool.State == state {
+				return false, string(pool.State), nil
+			}
+		}
+
+		return true, string(pool.State), nil
+	}
+}
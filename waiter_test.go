@@ -0,0 +1,137 @@
+package egoscale
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testWaitVirtualMachineResponse(state string) response {
+	return response{200, jsonContentType, `
+{"listvirtualmachinesresponse": {
+	"count": 1,
+	"virtualmachine": [{"id": "f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01", "state": "` + state + `"}]
+}}`}
+}
+
+func TestClient_WaitFor(t *testing.T) {
+	id := MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01")
+
+	ts := newServer(
+		response{200, jsonContentType, `{"listvirtualmachinesresponse": {"count": 0, "virtualmachine": []}}`},
+		testWaitVirtualMachineResponse("Starting"),
+		testWaitVirtualMachineResponse("Running"),
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	resource, err := client.WaitFor(context.Background(), &VirtualMachine{ID: id},
+		VirtualMachineStateIs(VirtualMachineRunning), WaitOptions{Interval: time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, "Running", resource.(*VirtualMachine).State)
+	require.Equal(t, 3, ts.lastResponse)
+
+	_, err = client.WaitFor(context.Background(), &InstancePool{}, InstancePoolStateIs(InstancePoolRunning), WaitOptions{})
+	require.Error(t, err)
+
+	_, err = client.WaitFor(context.Background(), "lolnope", InstancePoolStateIs(InstancePoolRunning), WaitOptions{})
+	require.Error(t, err)
+}
+
+func TestClient_WaitFor_Timeout(t *testing.T) {
+	id := MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01")
+
+	// The desired state is never reached
+	ts := newServer()
+	defer ts.Close()
+	for i := 0; i < 200; i++ {
+		ts.addResponse(testWaitVirtualMachineResponse("Running"))
+	}
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	_, err := client.WaitFor(context.Background(), &VirtualMachine{ID: id},
+		VirtualMachineStateIs(VirtualMachineStopped),
+		WaitOptions{Interval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Timeout: 50 * time.Millisecond})
+	require.IsType(t, &WaitTimeoutError{}, err)
+	require.Equal(t, "Running", err.(*WaitTimeoutError).LastState)
+	require.Equal(t, "VirtualMachine", err.(*WaitTimeoutError).Resource)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.EqualError(t, err, "context deadline exceeded: gave up waiting for VirtualMachine, last observed state: Running")
+}
+
+func TestClient_WaitFor_Error(t *testing.T) {
+	id := MustParseUUID("f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01")
+
+	// The desired state can no longer be reached
+	ts := newServer(testWaitVirtualMachineResponse("Error"))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	_, err := client.WaitFor(context.Background(), &VirtualMachine{ID: id},
+		VirtualMachineStateIs(VirtualMachineRunning), WaitOptions{Interval: time.Millisecond})
+	require.EqualError(t, err, "virtual machine f4fd8b09-5c6b-4b76-9ee4-6b1e9a5c8a01 is in state Error")
+}
+
+func TestClient_WaitFor_InstancePool(t *testing.T) {
+	var (
+		id     = MustParseUUID("8a1d5b4a-8f1d-4c6c-9c55-9f3e4c0a3c01")
+		zoneID = MustParseUUID("1128bd56-b4d9-4ac6-a7b9-c715b187ce11")
+	)
+
+	ts := newServer(
+		response{200, jsonContentType, `
+{"getinstancepoolresponse": {
+	"count": 1,
+	"instancepool": [{"id": "8a1d5b4a-8f1d-4c6c-9c55-9f3e4c0a3c01", "state": "scaling-up"}]
+}}`},
+		response{200, jsonContentType, `
+{"getinstancepoolresponse": {
+	"count": 1,
+	"instancepool": [{"id": "8a1d5b4a-8f1d-4c6c-9c55-9f3e4c0a3c01", "state": "running"}]
+}}`},
+	)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "KEY", "SECRET")
+
+	resource, err := client.WaitFor(context.Background(), &InstancePool{ID: id, ZoneID: zoneID},
+		InstancePoolStateIsNot(InstancePoolScalingUp), WaitOptions{Interval: time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, InstancePoolRunning, resource.(*InstancePool).State)
+}
+func TestWaitPredicates(t *testing.T) {
+	done, state, err := SnapshotStateIs(BackedUp)(&Snapshot{State: string(BackingUp)})
+	require.False(t, done)
+	require.Equal(t, "BackingUp", state)
+	require.NoError(t, err)
+
+	done, _, err = SnapshotStateIs(BackedUp)(&Snapshot{State: string(BackedUp)})
+	require.True(t, done)
+	require.NoError(t, err)
+
+	_, _, err = SnapshotStateIs(BackedUp)(&Snapshot{State: string(Error)})
+	require.Error(t, err)
+
+	done, state, err = TemplateIsReady()(&Template{Status: "Download In Progress"})
+	require.False(t, done)
+	require.Equal(t, "Download In Progress", state)
+	require.NoError(t, err)
+
+	done, _, _ = TemplateIsReady()(&Template{IsReady: true})
+	require.True(t, done)
+
+	_, state, err = TemplateIsReady()(&Template{Status: "Failed to download template: 404 Not Found"})
+	require.Equal(t, "Failed to download template: 404 Not Found", state)
+	require.Error(t, err)
+
+	done, state, err = InstancePoolStateIs(InstancePoolRunning)(nil)
+	require.False(t, done)
+	require.Empty(t, state)
+	require.NoError(t, err)
+}